@@ -27,6 +27,7 @@ require (
 	github.com/rs/zerolog v1.28.0
 	github.com/shirou/gopsutil v3.21.11+incompatible
 	github.com/stretchr/testify v1.8.2
+	github.com/ulikunitz/xz v0.5.17
 	github.com/yusufpapurcu/wmi v1.2.2
 	golang.org/x/sys v0.6.0
 )
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/google/jsonapi"
+	"github.com/gowebpki/jcs"
 	"github.com/immune-gmbh/agent/v3/pkg/typevisit"
 	"github.com/klauspost/compress/zstd"
 	"github.com/rs/zerolog"
@@ -76,8 +77,10 @@ type Client struct {
 	AgentVersion       string
 }
 
-func NewClient(base *url.URL, ca *x509.Certificate, agentVersion string) Client {
+// NewClient creates an API client, if proxy is nil requests are sent directly
+func NewClient(base *url.URL, ca *x509.Certificate, proxy *url.URL, agentVersion string) Client {
 	var tlsConfig *tls.Config
+	var proxyFunc func(*http.Request) (*url.URL, error)
 
 	if ca != nil {
 		pool := x509.NewCertPool()
@@ -87,8 +90,12 @@ func NewClient(base *url.URL, ca *x509.Certificate, agentVersion string) Client
 		}
 	}
 
+	if proxy != nil {
+		proxyFunc = http.ProxyURL(proxy)
+	}
+
 	return Client{
-		HTTP:               &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+		HTTP:               &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: proxyFunc}},
 		Base:               base,
 		HTTPRequestTimeout: time.Second * DefaultHTTPRequestTimeoutSec,
 		PostRequestTimeout: time.Second * DefaultPostRequestTimeoutSec,
@@ -235,6 +242,17 @@ func (c *Client) Attest(ctx context.Context, quoteCredential string, ev Evidence
 
 // Client.Configuration returns a nil Configuration when lastUpdate is not nil and the server tells us to use a cached configuration
 func (c *Client) Configuration(ctx context.Context, lastUpdate *time.Time) (*Configuration, error) {
+	signed, err := c.SignedConfiguration(ctx, lastUpdate)
+	if signed == nil || err != nil {
+		return nil, err
+	}
+
+	return &signed.Configuration, nil
+}
+
+// Client.SignedConfiguration works like Client.Configuration but also returns the canonical encoding of the
+// configuration and the detached signature the server sent in the resource meta, if any
+func (c *Client) SignedConfiguration(ctx context.Context, lastUpdate *time.Time) (*SignedConfiguration, error) {
 	c.Auth = ""
 
 	payload, err := c.Get(ctx, "configuration", lastUpdate)
@@ -255,10 +273,27 @@ func (c *Client) Configuration(ctx context.Context, lastUpdate *time.Time) (*Con
 	if err != nil {
 		return nil, err
 	}
-	var cfg Configuration
-	err = json.Unmarshal(buf, &cfg)
+	canonical, err := jcs.Transform(buf)
+	if err != nil {
+		return nil, FormatError
+	}
+
+	var signed SignedConfiguration
+	if err = json.Unmarshal(canonical, &signed.Configuration); err != nil {
+		return nil, err
+	}
+	signed.Canonical = canonical
+
+	if one.Data.Meta != nil {
+		if str, ok := (*one.Data.Meta)[ConfigurationSignatureMeta].(string); ok {
+			signed.Signature, err = base64.StdEncoding.DecodeString(str)
+			if err != nil {
+				return nil, FormatError
+			}
+		}
+	}
 
-	return &cfg, err
+	return &signed, nil
 }
 
 func (c *Client) Post(ctx context.Context, route string, doc interface{}, multiPartFiles map[string][]byte) (jsonapi.Payloader, error) {
@@ -3,6 +3,8 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/google/go-tpm/tpm2"
 	"github.com/google/jsonapi"
+	"github.com/gowebpki/jcs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -132,3 +135,45 @@ func TestClient_Configuration(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_SignedConfiguration(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	var cfg Configuration
+	cfg.Root.Public.Type = tpm2.AlgECC
+	cfg.Root.Public.ECCParameters = &tpm2.ECCParams{}
+	cfg.PCRBank = uint16(tpm2.AlgSHA256)
+	cfg.MSRs = []MSR{{MSR: 0x13a}}
+	tmp, err := jsonapi.Marshal(&cfg)
+	assert.NoError(t, err)
+	one := tmp.(*jsonapi.OnePayload)
+	attrs, err := json.Marshal(one.Data.Attributes)
+	assert.NoError(t, err)
+	canonical, err := jcs.Transform(attrs)
+	assert.NoError(t, err)
+	one.Data.Meta = &jsonapi.Meta{ConfigurationSignatureMeta: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, canonical))}
+	jsonCfg, err := json.Marshal(one)
+	assert.NoError(t, err)
+
+	c := &Client{
+		HTTP: NewTestClient(func(req *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBuffer(jsonCfg)),
+				Header:     make(http.Header),
+			}
+		}),
+		Base: baseURL,
+	}
+	signed, err := c.SignedConfiguration(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, cfg.MSRs, signed.Configuration.MSRs)
+	assert.NoError(t, VerifyConfiguration(pub, signed.Canonical, signed.Signature))
+
+	// tampering with the configuration must invalidate the signature
+	tampered := bytes.Replace(signed.Canonical, []byte("314"), []byte("315"), 1)
+	assert.NotEqual(t, signed.Canonical, Buffer(tampered))
+	assert.ErrorIs(t, VerifyConfiguration(pub, tampered, signed.Signature), ErrSignatureInvalid)
+	assert.ErrorIs(t, VerifyConfiguration(pub, signed.Canonical, nil), ErrSignatureMissing)
+}
@@ -15,6 +15,7 @@ const (
 	NoPermission   FirmwareError = "no-perm"
 	NoResponse     FirmwareError = "no-resp"
 	NotImplemented FirmwareError = "not-impl"
+	DeniedByPolicy FirmwareError = "denied-by-policy"
 )
 
 // /v2/info (apisrv)
@@ -44,6 +45,16 @@ type Configuration struct {
 	PCIConfigSpaces []PCIConfigSpace       `jsonapi:"attr,pci" json:"pci"`
 }
 
+// name of the resource meta member holding the base64 encoded configuration signature
+const ConfigurationSignatureMeta = "signature"
+
+// /v2/configuration (apisrv)
+type SignedConfiguration struct {
+	Configuration Configuration
+	Canonical     Buffer // JCS encoding of the configuration attributes, this is what gets signed
+	Signature     Buffer // detached Ed25519 signature over Canonical
+}
+
 // /v2/attest (apisrv)
 type FirmwareProperties struct {
 	UEFIVariables   []UEFIVariable     `json:"uefi,omitempty"`
@@ -70,13 +81,369 @@ type FirmwareProperties struct {
 	Agent           *Agent             `json:"agent,omitempty"`
 	Devices         *Devices           `json:"devices,omitempty"`
 	IMALog          *ErrorBuffer       `json:"ima_log,omitempty"`
+	IMAPolicy       *IMAPolicy         `json:"ima_policy,omitempty"`
 	EPPInfo         *EPPInfo           `json:"epp_info,omitempty"`
 	BootApps        *BootApps          `json:"boot_apps,omitempty"`
+	LinuxBoot       *LinuxBoot         `json:"linux_boot,omitempty"`
+	KernelSecurity  *KernelSecurity    `json:"kernel_security,omitempty"`
+	TDXEventLog     *HashBlob          `json:"tdx_event_log,omitempty"` // CCEL event log extending the TDX RTMRs
+	SGX             *SGXInfo           `json:"sgx,omitempty"`
+	BootGuard       *BootGuard         `json:"boot_guard,omitempty"`
+	FlashProtection *FlashProtection   `json:"flash_protection,omitempty"`
+	UEFIFirmware    *UEFIFirmware      `json:"uefi_firmware,omitempty"`
+	PCIDevices      *PCIDevices        `json:"pci_devices,omitempty"`
+	Peripherals     *Peripherals       `json:"peripherals,omitempty"`
+	Storage         *Storage           `json:"storage,omitempty"`
+	BMC             *BMC               `json:"bmc,omitempty"`
+	MEStatus        *MEStatus          `json:"me_status,omitempty"`
 }
 
 type BootApps struct {
-	Images    map[string]HashBlob `json:"images,omitempty"` // path -> pe file
-	ImagesErr FirmwareError       `json:"images_err,omitempty"`
+	Images        map[string]HashBlob `json:"images,omitempty"` // path -> pe file
+	ImagesErr     FirmwareError       `json:"images_err,omitempty"`
+	PartitionUUID string              `json:"partition_uuid,omitempty"` // GPT partition UUID of the ESP
+	PEImages      map[string]PEImage  `json:"pe_images,omitempty"`      // path -> Authenticode information, same keys as Images
+}
+
+// Authenticode digests are the values measured into PCR 4 when the image is started
+type PEImage struct {
+	AuthenticodeSHA1   Buffer         `json:"authenticode_sha1,omitempty"`
+	AuthenticodeSHA256 Buffer         `json:"authenticode_sha256,omitempty"`
+	AuthenticodeSHA384 Buffer         `json:"authenticode_sha384,omitempty"`
+	Certificates       []EFISignature `json:"certificates,omitempty"` // embedded in the signatures, signers and intermediates
+	Error              FirmwareError  `json:"error,omitempty"`
+}
+
+// Paths of files on the ESP are relative to its root and prefixed with "esp:", all others are absolute.
+type LinuxBoot struct {
+	Release   string              `json:"release,omitempty"`   // running kernel, uname -r
+	Cmdline   string              `json:"cmdline,omitempty"`   // /proc/cmdline
+	Kernels   map[string]HashBlob `json:"kernels,omitempty"`   // path -> vmlinuz or unified kernel image
+	Initramfs map[string]HashBlob `json:"initramfs,omitempty"` // path -> initramfs image
+	Error     FirmwareError       `json:"error,omitempty"`
+}
+
+// Linux only
+type IMAPolicy struct {
+	Files map[string]HashBlob `json:"files,omitempty"` // path -> custom policy loaded by the init system
+	Error FirmwareError       `json:"error,omitempty"`
+}
+
+// Linux only
+type KernelSecurity struct {
+	Lockdown            string            `json:"lockdown,omitempty"` // none, integrity or confidentiality
+	LockdownErr         FirmwareError     `json:"lockdown_err,omitempty"`
+	LSMs                []string          `json:"lsms,omitempty"` // in initialization order
+	LSMsErr             FirmwareError     `json:"lsms_err,omitempty"`
+	SELinux             string            `json:"selinux,omitempty"` // enforcing, permissive or disabled
+	SELinuxErr          FirmwareError     `json:"selinux_err,omitempty"`
+	AppArmor            string            `json:"apparmor,omitempty"`          // enabled or disabled
+	AppArmorProfiles    map[string]int    `json:"apparmor_profiles,omitempty"` // profile mode -> number of loaded profiles
+	AppArmorErr         FirmwareError     `json:"apparmor_err,omitempty"`
+	Vulnerabilities     map[string]string `json:"vulnerabilities,omitempty"` // name -> mitigation status
+	VulnerabilitiesErr  FirmwareError     `json:"vulnerabilities_err,omitempty"`
+	ModuleSigEnforce    *bool             `json:"module_sig_enforce,omitempty"`
+	ModuleSigEnforceErr FirmwareError     `json:"module_sig_enforce_err,omitempty"`
+	Taint               *uint64           `json:"taint,omitempty"` // /proc/sys/kernel/tainted bit field
+	TaintErr            FirmwareError     `json:"taint_err,omitempty"`
+	IOMMU               *bool             `json:"iommu,omitempty"` // DMA remapping hardware in use
+	IOMMUErr            FirmwareError     `json:"iommu_err,omitempty"`
+	DMAProtection       *bool             `json:"dma_protection,omitempty"` // pre-boot DMA protection for external ports
+	DMAProtectionErr    FirmwareError     `json:"dma_protection_err,omitempty"`
+}
+
+// Intel SGX capabilities decoded from CPUID leaf 0x12 and the SGX MSRs
+type SGXInfo struct {
+	SGX
+	FeatureControl    *uint64       `json:"feature_control,omitempty"` // IA32_FEATURE_CONTROL
+	FeatureControlErr FirmwareError `json:"feature_control_err,omitempty"`
+	LEPubKeyHash      Buffer        `json:"le_pubkey_hash,omitempty"` // IA32_SGXLEPUBKEYHASH0-3, FLC only
+	LEPubKeyHashErr   FirmwareError `json:"le_pubkey_hash_err,omitempty"`
+	EnclaveDevice     bool          `json:"enclave_device"`   // /dev/sgx_enclave
+	ProvisionDevice   bool          `json:"provision_device"` // /dev/sgx_provision
+	Error             FirmwareError `json:"error,omitempty"`
+}
+
+// Intel Boot Guard and BIOS Guard state decoded from BOOT_GUARD_SACM_INFO and the ME firmware status registers
+type BootGuard struct {
+	SACMInfo    *uint64       `json:"sacm_info,omitempty"` // MSR 0x13A
+	SACMInfoErr FirmwareError `json:"sacm_info_err,omitempty"`
+	HFSTS       []uint32      `json:"hfsts,omitempty"` // HFSTS1-6 from HECI config space
+	HFSTSErr    FirmwareError `json:"hfsts_err,omitempty"`
+
+	// provisioned policy, from HFSTS6
+	Profile           string `json:"profile,omitempty"` // 0, 3 (VM), 4 (FVE), 5 (FVME) or custom
+	ForceACM          bool   `json:"force_acm"`
+	VerifiedPolicy    bool   `json:"verified_policy"`
+	MeasuredPolicy    bool   `json:"measured_policy"`
+	EnforcementPolicy uint   `json:"enforcement_policy"` // 0 do nothing, 1 shutdown with timeout, 3 immediate shutdown
+	ACMSVN            uint   `json:"acm_svn"`
+	KMSVN             uint   `json:"km_svn"`
+	BPMSVN            uint   `json:"bpm_svn"`
+	KMID              uint   `json:"km_id"`
+	FPFLocked         bool   `json:"fpf_locked"`         // field programmable fuses committed
+	ManufacturingMode bool   `json:"manufacturing_mode"` // HFSTS1, FPFs can still be written
+
+	// boot outcome, from BOOT_GUARD_SACM_INFO and HFSTS6
+	Capable          bool `json:"capable"`
+	ACMExecuted      bool `json:"acm_executed"` // startup ACM set up no-eviction mode
+	VerifiedBoot     bool `json:"verified_boot"`
+	MeasuredBoot     bool `json:"measured_boot"`
+	ACMRevoked       bool `json:"acm_revoked"`
+	TPMSuccess       bool `json:"tpm_success"`
+	PolicyValid      bool `json:"policy_valid"` // boot policy manifest accepted by the ACM
+	BootGuardError   bool `json:"boot_guard_error"`
+	BootGuardDisable bool `json:"boot_guard_disabled"`
+
+	BIOSGuardCapable bool          `json:"bios_guard_capable"` // PLATFORM_INFO
+	BIOSGuardEnabled bool          `json:"bios_guard_enabled"` // PLAT_FRMW_PROT_CTRL
+	BIOSGuardLocked  bool          `json:"bios_guard_locked"`
+	BIOSGuardErr     FirmwareError `json:"bios_guard_err,omitempty"`
+	Error            FirmwareError `json:"error,omitempty"`
+}
+
+// Intel ME firmware version and status registers from the Linux mei driver, fallback for denied HECI commands
+type MEStatus struct {
+	Firmware          *ME           `json:"firmware,omitempty"` // Variant, versions and Manufacturer only
+	HFSTS             []uint32      `json:"hfsts,omitempty"`    // HFSTS1-6
+	TRC               *uint32       `json:"trc,omitempty"`      // trace register of CSME 16+
+	WorkingState      string        `json:"working_state,omitempty"`
+	OperationMode     string        `json:"operation_mode,omitempty"`
+	ErrorCode         uint          `json:"error_code"`
+	ManufacturingMode bool          `json:"manufacturing_mode"`
+	Error             FirmwareError `json:"error,omitempty"`
+}
+
+// Intel SPI flash layout and write protection
+type FlashProtection struct {
+	Descriptor          *FlashDescriptor      `json:"descriptor,omitempty"` // parsed from the flash dump
+	DescriptorErr       FirmwareError         `json:"descriptor_err,omitempty"`
+	Regions             []FlashRegion         `json:"regions,omitempty"` // FREGn and FRAP of the SPI controller
+	ProtectedRanges     []FlashProtectedRange `json:"protected_ranges,omitempty"`
+	HSFS                *uint16               `json:"hsfs,omitempty"`
+	BIOSControl         *uint8                `json:"bios_control,omitempty"` // BIOS_CNTL
+	BIOSWriteEnable     bool                  `json:"bios_write_enable"`      // BIOSWE
+	BIOSLockEnable      bool                  `json:"bios_lock_enable"`       // BLE
+	SMMBIOSWriteProtect bool                  `json:"smm_bios_write_protect"` // SMM_BWP
+	ConfigLockDown      bool                  `json:"config_lock_down"`       // FLOCKDN, PRx and FRAP are read-only
+	DescriptorOverride  bool                  `json:"descriptor_override"`    // FDOPSS cleared by a strap
+	BIOSWriteProtected  bool                  `json:"bios_write_protected"`   // the OS can't write the BIOS region
+	Error               FirmwareError         `json:"error,omitempty"`
+}
+
+type FlashDescriptor struct {
+	Version int           `json:"version"` // 1 up to Broadwell, 2 since Skylake
+	Regions []FlashRegion `json:"regions"`
+	Masters []FlashMaster `json:"masters"`
+}
+
+type FlashRegion struct {
+	Name      string `json:"name"`
+	Base      uint32 `json:"base"`
+	Limit     uint32 `json:"limit"`
+	BIOSRead  bool   `json:"bios_read"` // only set for regions read from the SPI controller
+	BIOSWrite bool   `json:"bios_write"`
+}
+
+type FlashMaster struct {
+	Name  string   `json:"name"`
+	Read  []string `json:"read"`  // regions
+	Write []string `json:"write"` // regions
+}
+
+type FlashProtectedRange struct {
+	Base         uint32 `json:"base"`
+	Limit        uint32 `json:"limit"`
+	ReadProtect  bool   `json:"read_protect"`
+	WriteProtect bool   `json:"write_protect"`
+}
+
+// Module inventory of the firmware volumes in the BIOS flash dump
+type UEFIFirmware struct {
+	Volumes []UEFIFirmwareVolume `json:"volumes,omitempty"`
+	Error   FirmwareError        `json:"error,omitempty"`
+}
+
+type UEFIFirmwareVolume struct {
+	Name       string             `json:"name,omitempty"` // FvName from the extended header
+	FileSystem string             `json:"file_system"`    // GUID
+	Offset     uint64             `json:"offset"`         // into the flash dump, zero for nested volumes
+	Size       uint64             `json:"size"`
+	Parent     string             `json:"parent,omitempty"` // GUID of the file containing a nested volume
+	Files      []UEFIFirmwareFile `json:"files,omitempty"`
+}
+
+type UEFIFirmwareFile struct {
+	GUID       string `json:"guid"`
+	Type       string `json:"type"`           // driver, peim, application, ...
+	Name       string `json:"name,omitempty"` // user interface section
+	Size       uint64 `json:"size"`
+	Sha256     Buffer `json:"sha256"`               // whole file including the header
+	Compressed bool   `json:"compressed,omitempty"` // contains sections the agent can't unpack
+}
+
+// Inventory of all PCI functions known to the OS
+type PCIDevices struct {
+	Devices []PCIDevice   `json:"devices,omitempty"`
+	Error   FirmwareError `json:"error,omitempty"`
+}
+
+type PCIDevice struct {
+	Address         string        `json:"address"` // domain:bus:device.function
+	Vendor          uint16        `json:"vendor"`
+	Device          uint16        `json:"device"`
+	SubsystemVendor uint16        `json:"subsystem_vendor"`
+	SubsystemDevice uint16        `json:"subsystem_device"`
+	Class           uint32        `json:"class"` // base class, sub class and programming interface
+	Revision        uint8         `json:"revision"`
+	Driver          string        `json:"driver,omitempty"`
+	IOMMUGroup      *int          `json:"iommu_group,omitempty"` // missing if the device is not behind an IOMMU
+	BusMaster       *bool         `json:"bus_master,omitempty"`  // DMA enabled in the command register
+	Removable       bool          `json:"removable,omitempty"`   // behind an external facing port like Thunderbolt
+	ROMSize         uint64        `json:"rom_size,omitempty"`
+	ROMSha256       Buffer        `json:"rom_sha256,omitempty"` // option ROM
+	ROMErr          FirmwareError `json:"rom_err,omitempty"`
+}
+
+// USB devices and Thunderbolt domains, Linux only
+type Peripherals struct {
+	USB            []USBDevice         `json:"usb,omitempty"`
+	USBErr         FirmwareError       `json:"usb_err,omitempty"`
+	Thunderbolt    []ThunderboltDomain `json:"thunderbolt,omitempty"`
+	ThunderboltErr FirmwareError       `json:"thunderbolt_err,omitempty"`
+}
+
+type USBDevice struct {
+	Path             string  `json:"path"` // bus and port chain, e.g. 1-2.3
+	Vendor           uint16  `json:"vendor"`
+	Product          uint16  `json:"product"`
+	Class            uint8   `json:"class"` // zero if defined per interface
+	InterfaceClasses []uint8 `json:"interface_classes,omitempty"`
+	Manufacturer     string  `json:"manufacturer,omitempty"`
+	ProductName      string  `json:"product_name,omitempty"`
+	Serial           string  `json:"serial,omitempty"`
+	Authorized       bool    `json:"authorized"`
+}
+
+type ThunderboltDomain struct {
+	Name          string              `json:"name"`
+	Security      string              `json:"security"`           // none, user, secure, dponly, usbonly or nopcie
+	BootACL       []string            `json:"boot_acl,omitempty"` // UUIDs of devices the firmware connects before the OS
+	DMAProtection *bool               `json:"dma_protection,omitempty"`
+	Devices       []ThunderboltDevice `json:"devices,omitempty"`
+}
+
+type ThunderboltDevice struct {
+	Name       string `json:"name"`
+	Vendor     uint16 `json:"vendor"`
+	Device     uint16 `json:"device"`
+	VendorName string `json:"vendor_name,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+	UniqueID   string `json:"unique_id,omitempty"`
+	Authorized int    `json:"authorized"` // 0 not authorized, 1 authorized, 2 authorized with a key
+}
+
+// Drive firmware and disk encryption, Linux only
+type Storage struct {
+	Drives    []StorageDrive `json:"drives,omitempty"`
+	DrivesErr FirmwareError  `json:"drives_err,omitempty"`
+	LUKS      []LUKSVolume   `json:"luks,omitempty"`
+	LUKSErr   FirmwareError  `json:"luks_err,omitempty"`
+}
+
+type StorageDrive struct {
+	Name      string          `json:"name"` // kernel name, e.g. nvme0n1 or sda
+	Transport string          `json:"transport"`
+	Model     string          `json:"model,omitempty"`
+	Serial    string          `json:"serial,omitempty"`
+	Firmware  string          `json:"firmware,omitempty"` // revision
+	Removable bool            `json:"removable,omitempty"`
+	NVMe      *NVMeController `json:"nvme,omitempty"`
+	NVMeErr   FirmwareError   `json:"nvme_err,omitempty"`
+	Opal      *OpalStatus     `json:"opal,omitempty"`
+	OpalErr   FirmwareError   `json:"opal_err,omitempty"`
+}
+
+// NVMe Identify Controller and Firmware Slot Information
+type NVMeController struct {
+	Vendor           uint16   `json:"vendor"`
+	SubsystemVendor  uint16   `json:"subsystem_vendor"`
+	FirmwareDownload bool     `json:"firmware_download"`        // Firmware Commit and Image Download supported
+	SecuritySendRecv bool     `json:"security_send_recv"`       // Security Send and Receive supported
+	FirmwareUpdates  uint8    `json:"firmware_updates"`         // FRMW
+	FirmwareSlots    []string `json:"firmware_slots,omitempty"` // revision per slot, empty if unused
+	ActiveSlot       uint8    `json:"active_slot"`
+	NextSlot         uint8    `json:"next_slot,omitempty"` // activated on the next reset
+}
+
+// TCG Storage Level 0 Discovery of self encrypting drives
+type OpalStatus struct {
+	SSC              string `json:"ssc,omitempty"` // opal1, opal2, enterprise, pyrite1, pyrite2 or ruby
+	LockingSupported bool   `json:"locking_supported"`
+	LockingEnabled   bool   `json:"locking_enabled"`
+	Locked           bool   `json:"locked"`
+	MediaEncryption  bool   `json:"media_encryption"`
+	MBREnabled       bool   `json:"mbr_enabled"`
+	MBRDone          bool   `json:"mbr_done"`
+}
+
+type LUKSVolume struct {
+	Device   string      `json:"device"`
+	Version  int         `json:"version"`
+	UUID     string      `json:"uuid"`
+	Label    string      `json:"label,omitempty"`
+	Cipher   string      `json:"cipher,omitempty"`
+	Keyslots int         `json:"keyslots"`
+	Tokens   []LUKSToken `json:"tokens,omitempty"` // LUKS2 only
+}
+
+type LUKSToken struct {
+	Type        string   `json:"type"` // systemd-tpm2, clevis, ...
+	Keyslots    []string `json:"keyslots,omitempty"`
+	TPM2PCRs    []int    `json:"tpm2_pcrs,omitempty"`
+	TPM2PCRBank string   `json:"tpm2_pcr_bank,omitempty"`
+	TPM2PIN     bool     `json:"tpm2_pin,omitempty"`
+}
+
+// Baseboard management controller queried over the IPMI system interface and described by SMBIOS
+type BMC struct {
+	DeviceID       *IPMIDeviceID      `json:"device_id,omitempty"`
+	DeviceIDErr    FirmwareError      `json:"device_id_err,omitempty"`
+	SystemGUID     string             `json:"system_guid,omitempty"`
+	SystemGUIDErr  FirmwareError      `json:"system_guid_err,omitempty"`
+	SelfTest       *IPMISelfTest      `json:"self_test,omitempty"`
+	SelfTestErr    FirmwareError      `json:"self_test_err,omitempty"`
+	Interfaces     []IPMIInterface    `json:"interfaces,omitempty"`      // SMBIOS type 38
+	HostInterfaces []BMCHostInterface `json:"host_interfaces,omitempty"` // SMBIOS type 42
+	SMBIOSErr      FirmwareError      `json:"smbios_err,omitempty"`
+}
+
+type IPMIDeviceID struct {
+	DeviceID         uint8  `json:"device_id"`
+	DeviceRevision   uint8  `json:"device_revision"`
+	FirmwareRevision string `json:"firmware_revision"` // major.minor
+	AuxFirmware      Buffer `json:"aux_firmware,omitempty"`
+	IPMIVersion      string `json:"ipmi_version"`
+	ManufacturerID   uint32 `json:"manufacturer_id"` // IANA enterprise number
+	Manufacturer     string `json:"manufacturer,omitempty"`
+	ProductID        uint16 `json:"product_id"`
+}
+
+type IPMISelfTest struct {
+	Passed bool  `json:"passed"`
+	Result uint8 `json:"result"` // 55h no error, 57h corrupted or inaccessible data, 58h fatal error
+	Detail uint8 `json:"detail"`
+}
+
+type IPMIInterface struct {
+	Type         string `json:"type"` // kcs, smic, bt or ssif
+	SpecRevision string `json:"spec_revision"`
+	BaseAddress  uint64 `json:"base_address"`
+}
+
+type BMCHostInterface struct {
+	Type      uint8   `json:"type"`                // 40h network host interface
+	Protocols []uint8 `json:"protocols,omitempty"` // 04h Redfish over IP
 }
 
 type EPPInfo struct {
@@ -84,7 +451,16 @@ type EPPInfo struct {
 	AntimalwareProcessesErr FirmwareError       `json:"antimalware_processes_err,omitempty"`
 	EarlyLaunchDrivers      map[string]HashBlob `json:"early_launch_drivers,omitempty"` // path -> sys file
 	EarlyLaunchDriversErr   FirmwareError       `json:"early_launch_drivers_err,omitempty"`
-	ESET                    *ESETConfig         `json:"eset,omitempty"` // Linux only
+	ESET                    *ESETConfig         `json:"eset,omitempty"`     // Linux only
+	Products                []EPPProduct        `json:"products,omitempty"` // Linux only
+}
+
+// installed Linux EPP/EDR product, the main binary's hash is in EPPInfo.AntimalwareProcesses
+type EPPProduct struct {
+	Name    string `json:"name"` // mdatp, crowdstrike, sentinelone, sophos, clamav or trellix
+	Running bool   `json:"running"`
+	Version string `json:"version,omitempty"`
+	Binary  string `json:"binary,omitempty"` // path of the main binary
 }
 
 type ESETConfig struct {
@@ -104,10 +480,33 @@ type Devices struct {
 	FWUPdVersion string                        `json:"fwupd_version"`
 	Topology     []FWUPdDevice                 `json:"topology"`
 	Releases     map[string][]FWUPdReleaseInfo `json:"releases,omitempty"`
+
+	HostSecurityID    string              `json:"host_security_id,omitempty"` // overall HSI level, e.g. "HSI:2! (v1.9.5)"
+	HostSecurityAttrs []FWUPdHSIAttribute `json:"host_security_attrs,omitempty"`
+	HostSecurityErr   FirmwareError       `json:"host_security_err,omitempty"`
+
+	Updates    []FWUPdUpdate `json:"updates,omitempty"`
+	UpdatesErr FirmwareError `json:"updates_err,omitempty"`
+
+	Error FirmwareError `json:"error,omitempty"` // only set if the whole collector is disabled
+}
+
+// firmware update known to fwupd, either from its history, the last results of a device or a device's current state
+type FWUPdUpdate struct {
+	Source     string `json:"source"` // history, results or device
+	DeviceID   string `json:"device_id"`
+	Name       string `json:"name,omitempty"`
+	Version    string `json:"version,omitempty"`     // device version when the entry was recorded
+	NewVersion string `json:"new_version,omitempty"` // version of the release being installed
+	State      string `json:"state"`                 // pending, success, failed, needs-reboot, failed-transient or unknown
+	Error      string `json:"error,omitempty"`
+	Created    uint64 `json:"created,omitempty"`  // unix time
+	Modified   uint64 `json:"modified,omitempty"` // unix time
 }
 
 type FWUPdDevice = map[string]interface{}
 type FWUPdReleaseInfo = map[string]interface{}
+type FWUPdHSIAttribute = map[string]interface{}
 
 type Agent struct {
 	Release   string      `json:"release"`
@@ -252,6 +651,25 @@ type Evidence struct {
 	AllPCRs   map[string]map[string]Buffer `jsonapi:"attr,allpcrs" json:"allpcrs"`
 	Firmware  FirmwareProperties           `jsonapi:"attr,firmware" json:"firmware"`
 	Cookie    string                       `jsonapi:"attr,cookie" json:"cookie"`
+	SEVSNP    *SEVSNPReport                `jsonapi:"attr,sev_snp,omitempty" json:"sev_snp,omitempty"`
+	TDX       *TDXReport                   `jsonapi:"attr,tdx,omitempty" json:"tdx,omitempty"`
+}
+
+// AMD SEV-SNP attestation report of a confidential VM, REPORT_DATA is the SHA-256 of the canonical firmware properties
+type SEVSNPReport struct {
+	Report       Buffer            `json:"report,omitempty"`       // ATTESTATION_REPORT structure signed by the VCEK
+	Certificates map[string]Buffer `json:"certificates,omitempty"` // vcek, vlek, ask or ark -> DER certificate, unknown entries use their GUID
+	Error        FirmwareError     `json:"error,omitempty"`
+}
+
+// Intel TDX attestation of a confidential VM, REPORTDATA is the SHA-256 of the canonical firmware properties
+type TDXReport struct {
+	Report   Buffer        `json:"report,omitempty"` // TDREPORT_STRUCT, MACed and only verifiable on the same platform
+	MRTD     Buffer        `json:"mrtd,omitempty"`   // initial TD contents, as in the report
+	RTMRs    []Buffer      `json:"rtmrs,omitempty"`  // RTMR[0-3], as in the report
+	Quote    Buffer        `json:"quote,omitempty"`  // TD quote from the quoting enclave via configfs-tsm
+	QuoteErr FirmwareError `json:"quote_err,omitempty"`
+	Error    FirmwareError `json:"error,omitempty"`
 }
 
 // /v2/enroll (apisrv)
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrSignatureMissing = errors.New("configuration not signed")
+	ErrSignatureInvalid = errors.New("configuration signature invalid")
+)
+
+// ParseConfigurationKey decodes a base64 encoded Ed25519 public key used to verify server configurations
+func ParseConfigurationKey(str string) (ed25519.PublicKey, error) {
+	buf, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key size")
+	}
+
+	return ed25519.PublicKey(buf), nil
+}
+
+// VerifyConfiguration checks the detached signature over the canonical configuration encoding
+func VerifyConfiguration(key ed25519.PublicKey, canonical, signature []byte) error {
+	if len(canonical) == 0 || len(signature) == 0 {
+		return ErrSignatureMissing
+	}
+	if !ed25519.Verify(key, canonical, signature) {
+		return ErrSignatureInvalid
+	}
+
+	return nil
+}
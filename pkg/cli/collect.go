@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
+	"runtime"
 	"strconv"
 
 	"github.com/immune-gmbh/agent/v3/pkg/api"
@@ -17,19 +19,41 @@ import (
 )
 
 type collectCmd struct {
+	Offline bool `name:"offline" help:"Collect offline from the root file system of a powered-off machine mounted at --root, the default if --root is set (Linux only)"`
+	Live    bool `name:"live" help:"Collect from the running machine even if --root is set, f.e. from a container with the host's root file system mounted (Linux only)"`
 }
 
-func doCollect(ctx context.Context, cfg *api.Configuration) error {
+func doCollect(ctx context.Context, cfg *api.Configuration, opts *firmware.Options) error {
 	var conn io.ReadWriteCloser
 
 	// collect firmware info
 	tui.SetUIState(tui.StCollectFirmwareInfo)
 	log.Info().Msg("Collecting firmware info")
-	fwProps := firmware.GatherFirmwareData(conn, cfg)
+	fwProps := firmware.GatherFirmwareData(conn, cfg, opts)
+
+	// bind the firmware properties to the confidential VM attestation reports like attest does
+	var snpReport *api.SEVSNPReport
+	var tdxReport *api.TDXReport
+	if opts.Offline {
+		snpReport = &api.SEVSNPReport{Error: api.NotImplemented}
+		tdxReport = &api.TDXReport{Error: api.NotImplemented}
+	} else {
+		hash, err := core.FirmwarePropertiesHash(&fwProps)
+		if err != nil {
+			return err
+		}
+		snpReport, tdxReport, _ = core.ReportConfidentialVM(hash, opts)
+	}
 
 	// fetch the runtime measurment log
 	fwProps.IMALog = new(api.ErrorBuffer)
-	ima.ReportIMALog(fwProps.IMALog)
+	if opts.Offline {
+		fwProps.IMALog.Error = api.NotImplemented
+	} else if opts.Enabled(firmware.CollectorIMA) {
+		ima.ReportIMALog(fwProps.IMALog, opts.Root)
+	} else {
+		fwProps.IMALog.Error = api.DeniedByPolicy
+	}
 
 	cookie, _ := api.Cookie(rand.Reader)
 	evidence := api.Evidence{
@@ -37,6 +61,8 @@ func doCollect(ctx context.Context, cfg *api.Configuration) error {
 		Algorithm: strconv.Itoa(int(cfg.PCRBank)),
 		Firmware:  fwProps,
 		Cookie:    cookie,
+		SEVSNP:    snpReport,
+		TDX:       tdxReport,
 	}
 
 	evidenceJSON, err := json.Marshal(evidence)
@@ -56,8 +82,25 @@ func doCollect(ctx context.Context, cfg *api.Configuration) error {
 func (collect *collectCmd) Run(glob *core.AttestationClient) error {
 	ctx := context.Background()
 	cfg := api.Configuration{}
+	opts := glob.Options
+
+	if collect.Offline && collect.Live {
+		return errors.New("--offline and --live are mutually exclusive")
+	}
+
+	// don't mix the hardware of this machine with the files of another one
+	if collect.Offline || (!opts.Root.IsHost() && !collect.Live) {
+		if runtime.GOOS != "linux" {
+			return errors.New("offline collection is only supported on Linux")
+		}
+		opts.Offline = true
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+		log.Info().Msgf("Collecting offline from %s", opts.Root)
+	}
 
-	err := doCollect(ctx, &cfg)
+	err := doCollect(ctx, &cfg, &opts)
 	if err != nil {
 		tui.SetUIState(tui.StAttestationFailed)
 		return err
@@ -9,6 +9,7 @@ import (
 
 	"github.com/immune-gmbh/agent/v3/pkg/core"
 	"github.com/immune-gmbh/agent/v3/pkg/ipc"
+	"github.com/immune-gmbh/agent/v3/pkg/state"
 	"github.com/immune-gmbh/agent/v3/pkg/tui"
 	"github.com/rs/zerolog/log"
 )
@@ -18,7 +19,7 @@ type enrollCmd struct {
 	NoAttest   bool     `help:"Don't attest after successful enrollment" default:"false"`
 	Token      string   `arg:"" required:"" name:"token" help:"Enrollment authentication token"`
 	Name       string   `arg:"" optional:"" name:"name hint" help:"Name to assign to the device. May get suffixed by a counter if already taken. Defaults to the hostname."`
-	TPM        string   `name:"tpm" default:"${tpm_default_path}" help:"TPM device: device path (${tpm_default_path}) or mssim, sgx, swtpm/net url (mssim://localhost, sgx://localhost, net://localhost:1234) or 'dummy' for dummy TPM"`
+	TPM        string   `name:"tpm" help:"TPM device: device path (${tpm_default_path}) or mssim, sgx, swtpm/net url (mssim://localhost, sgx://localhost, net://localhost:1234) or 'dummy' for dummy TPM"`
 	DummyTPM   bool     `name:"notpm" help:"Force using insecure dummy TPM if this device has no real TPM" default:"false"`
 	Standalone bool     `help:"Don't connect to windows service to run enroll"`
 }
@@ -38,6 +39,7 @@ func (enroll *enrollCmd) Run(agentCore *core.AttestationClient, stdLogOut *io.Wr
 		}
 		defer client.Shutdown()
 
+		// an empty TPM path lets the service fall back to its configured TPM
 		args := ipc.CmdArgsEnroll{Token: enroll.Token, DummyTPM: enroll.DummyTPM, TPMPath: enroll.TPM, Server: enroll.Server}
 		var reply *ipc.CmdArgsEnrollReply
 		if reply, err = client.Enroll(args); err != nil {
@@ -52,7 +54,11 @@ func (enroll *enrollCmd) Run(agentCore *core.AttestationClient, stdLogOut *io.Wr
 			agentCore.OverrideServerUrl(enroll.Server)
 		}
 
-		err = agentCore.Enroll(ctx, enroll.Token, enroll.DummyTPM, enroll.TPM)
+		tpm := enroll.TPM
+		if tpm == "" {
+			tpm = state.DefaultTPMDevice()
+		}
+		err = agentCore.Enroll(ctx, enroll.Token, enroll.DummyTPM, tpm)
 	}
 
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/mattn/go-colorable"
@@ -12,6 +13,9 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/immune-gmbh/agent/v3/pkg/core"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/settings"
 	"github.com/immune-gmbh/agent/v3/pkg/state"
 	"github.com/immune-gmbh/agent/v3/pkg/tui"
 	"github.com/immune-gmbh/agent/v3/pkg/util"
@@ -38,19 +42,25 @@ func (v traceFlag) BeforeApply() error {
 
 type rootCmd struct {
 	// Global options
-	StateDir string      `name:"state-dir" default:"${state_default_dir}" help:"Directory holding the cli state" type:"path"`
-	LogFlag  bool        `name:"log" help:"Force log output on and text UI off"`
-	Verbose  verboseFlag `help:"Enable verbose mode, implies log"`
-	Trace    traceFlag   `hidden:""`
-	Colors   bool        `help:"Force colors on for all console outputs (default: autodetect)"`
+	Config   kong.ConfigFlag `name:"config" placeholder:"PATH" help:"Agent configuration file (default: ${config_default_path})" type:"path"`
+	StateDir string          `name:"state-dir" default:"${state_default_dir}" help:"Directory holding the cli state" type:"path"`
+	Policy   string          `name:"policy" default:"${policy_default_path}" help:"Local policy restricting the hardware accessed on behalf of the server" type:"path"`
+	LogFlag  bool            `name:"log" help:"Force log output on and text UI off"`
+	Verbose  verboseFlag     `help:"Enable verbose mode, implies log"`
+	Trace    traceFlag       `hidden:""`
+	Colors   bool            `help:"Force colors on for all console outputs (default: autodetect)"`
+
+	// Options shared with the agent configuration file
+	settings.Settings `embed:""`
 
 	// Subcommands
-	Attest  attestCmd  `cmd:"" help:"Attests platform integrity of device"`
-	Enroll  enrollCmd  `cmd:"" help:"Enrolls device at the immune SaaS backend"`
-	Collect collectCmd `cmd:"" help:"Only collect firmware data"`
+	Attest     attestCmd     `cmd:"" help:"Attests platform integrity of device"`
+	Enroll     enrollCmd     `cmd:"" help:"Enrolls device at the immune SaaS backend"`
+	Collect    collectCmd    `cmd:"" help:"Only collect firmware data"`
+	SecureBoot secureBootCmd `cmd:"" name:"secureboot" help:"Show the UEFI Secure Boot configuration and whether dbx is current"`
 }
 
-func initUI(forceColors bool, forceLog bool) io.Writer {
+func initUI(forceColors bool, forceLog bool, jsonLog bool) io.Writer {
 	notty := os.Getenv("TERM") == "dumb" || (!isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()))
 
 	// honor NO_COLOR env var as per https://no-color.org/ like the colors library we use does, too
@@ -70,6 +80,12 @@ func initUI(forceColors bool, forceLog bool) io.Writer {
 		cw.Out = colorable.NewColorableStdout()
 	}
 
+	// structured logs go to stdout as-is and never mix with the tui
+	if jsonLog {
+		log.Logger = log.Output(os.Stdout)
+		return os.Stdout
+	}
+
 	// use tui instead of log as ui
 	if !forceLog && !notty {
 		tui.Init(cw.NoColor)
@@ -105,9 +121,14 @@ func RunCommandLineTool() int {
 		}),
 		kong.Vars{
 			// setting the TPM default path here is incompatible with future cross-platform client/server agent connections
-			"tpm_default_path":  state.DefaultTPMDevice(),
-			"state_default_dir": state.DefaultStateDir(),
+			"tpm_default_path":    state.DefaultTPMDevice(),
+			"state_default_dir":   state.DefaultStateDir(),
+			"policy_default_path": core.DefaultPolicyPath(),
+			"config_default_path": settings.DefaultPath(),
+			"collectors":          strings.Join(firmware.Collectors, ", "),
+			"redaction_rules":     strings.Join(firmware.RedactionRules, ", "),
 		},
+		kong.Configuration(kong.JSON, settings.DefaultPath()),
 	}
 	options = append(options, osSpecificCommands()...)
 
@@ -123,7 +144,17 @@ func RunCommandLineTool() int {
 	// init UI and determine a std log output for logging from remote agents
 	// when running as svc client we don't want tui b/c the tui states are not transmitted
 	runSvcClient := runtime.GOOS == "windows" && !cli.Attest.Standalone && !cli.Enroll.Standalone
-	stdLogOut := initUI(cli.Colors, cli.LogFlag || bool(cli.Verbose) || bool(cli.Trace) || runSvcClient)
+	jsonLog := cli.LogFormat == settings.LogFormatJSON
+	stdLogOut := initUI(cli.Colors, cli.LogFlag || bool(cli.Verbose) || bool(cli.Trace) || runSvcClient || jsonLog, jsonLog)
+
+	// explicit verbose or trace flags take precedence over the configured log level
+	if cli.LogLevel != "" && !bool(cli.Verbose) && !bool(cli.Trace) {
+		lvl, err := zerolog.ParseLevel(cli.LogLevel)
+		if err != nil {
+			ctx.FatalIfErrorf(err)
+		}
+		log.Logger = log.Level(lvl)
+	}
 
 	// tell who we are
 	log.Debug().Msg(desc)
@@ -139,12 +170,33 @@ func RunCommandLineTool() int {
 		return 1
 	}
 
+	proxy, err := cli.ProxyURL()
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid proxy URL")
+		tui.DumpErr()
+		return 1
+	}
+
+	// local restrictions on what is collected
+	agentCore.Options.Disabled = cli.Disable
+	agentCore.Options.Redact = cli.Redact
+	agentCore.Options.MountESP = cli.MountESP
+	agentCore.Options.Root = common.Root(cli.Root)
+	if err := agentCore.Options.Validate(); err != nil {
+		log.Error().Err(err).Msg("Invalid agent configuration")
+		tui.DumpErr()
+		return 1
+	}
+
 	// init agent core
-	if err := agentCore.Init(cli.StateDir, &log.Logger); err != nil {
+	if err := agentCore.Init(cli.StateDir, cli.Policy, &log.Logger); err != nil {
 		core.LogInitErrors(&log.Logger, err)
 		tui.DumpErr()
 		return 1
 	}
+	if proxy != nil {
+		agentCore.SetProxy(proxy)
+	}
 
 	// Run the selected subcommand
 	if err := ctx.Run(agentCore, &stdLogOut); err != nil {
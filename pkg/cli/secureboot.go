@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/core"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefivars"
+	"github.com/immune-gmbh/agent/v3/pkg/util"
+)
+
+type secureBootCmd struct {
+	JSON      bool   `name:"json" help:"Print the Secure Boot configuration as JSON"`
+	DbxUpdate string `name:"dbx-update" placeholder:"PATH" help:"Signed dbx update from uefi.org to compare dbx against (default: latest one installed by the distribution)" type:"path"`
+}
+
+type dbxStatus struct {
+	Reference string             `json:"reference,omitempty"` // path of the dbx update
+	Current   *bool              `json:"current,omitempty"`   // nil if no reference was found
+	Missing   []api.EFISignature `json:"missing,omitempty"`
+}
+
+type secureBootReport struct {
+	*uefivars.SecureBoot
+	Dbx dbxStatus `json:"dbx"`
+}
+
+func checkDbx(dbx *[]api.EFISignature, reference string) (dbxStatus, error) {
+	status := dbxStatus{Reference: reference}
+	if reference == "" {
+		return status, nil
+	}
+	update, err := uefivars.ReadDbxUpdate(reference)
+	if err != nil {
+		return status, err
+	}
+
+	var have []api.EFISignature
+	if dbx != nil {
+		have = *dbx
+	}
+	status.Missing = uefivars.MissingSignatures(have, update)
+	current := len(status.Missing) == 0
+	status.Current = &current
+	return status, nil
+}
+
+func printSignatures(w io.Writer, name string, sigs *[]api.EFISignature) {
+	if sigs == nil {
+		fmt.Fprintf(w, "%-12s not present\n", name+":")
+		return
+	}
+
+	var certs, hashes int
+	for _, sig := range *sigs {
+		if sig.Type == api.EFICertificate {
+			certs++
+		} else {
+			hashes++
+		}
+	}
+	fmt.Fprintf(w, "%-12s %d certificates, %d hashes\n", name+":", certs, hashes)
+	for _, sig := range *sigs {
+		if sig.Type != api.EFICertificate {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", *sig.Subject)
+		fmt.Fprintf(w, "    issuer:  %s\n", *sig.Issuer)
+		fmt.Fprintf(w, "    valid:   %s to %s\n", sig.NotBefore.Format("2006-01-02"), sig.NotAfter.Format("2006-01-02"))
+		fmt.Fprintf(w, "    sha256:  %s\n", sig.Fingerprint)
+	}
+}
+
+func printSecureBoot(w io.Writer, report *secureBootReport) {
+	state := "disabled"
+	if report.SecureBoot.SecureBoot {
+		state = "enabled"
+	}
+	fmt.Fprintf(w, "%-12s %s (%s mode)\n", "Secure Boot:", state, report.Mode)
+
+	printSignatures(w, "PK", report.PlatformKeys)
+	printSignatures(w, "KEK", report.ExchangeKeys)
+	printSignatures(w, "db", report.PermittedKeys)
+	printSignatures(w, "dbx", report.ForbiddenKeys)
+
+	switch {
+	case report.Dbx.Current == nil:
+		fmt.Fprintf(w, "%-12s unknown, no dbx update to compare against (use --dbx-update)\n", "dbx status:")
+	case *report.Dbx.Current:
+		fmt.Fprintf(w, "%-12s current (%s)\n", "dbx status:", report.Dbx.Reference)
+	default:
+		fmt.Fprintf(w, "%-12s outdated, %d revocations missing (%s)\n", "dbx status:", len(report.Dbx.Missing), report.Dbx.Reference)
+	}
+
+	if runtime.GOOS == "linux" {
+		printSignatures(w, "MokList", report.MokList)
+		printSignatures(w, "MokListX", report.MokListX)
+		if report.SbatLevel != "" {
+			fmt.Fprintf(w, "%-12s %s\n", "SbatLevel:", strings.Join(strings.Fields(report.SbatLevel), " "))
+		} else {
+			fmt.Fprintf(w, "%-12s not present\n", "SbatLevel:")
+		}
+	}
+
+	names := make([]string, 0, len(report.Errors))
+	for name := range report.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "Failed to read %s: %s\n", name, report.Errors[name])
+	}
+}
+
+func (sb *secureBootCmd) Run(glob *core.AttestationClient) error {
+	if err := util.WinAddTokenPrivilege("SeSystemEnvironmentPrivilege"); err != nil {
+		log.Debug().Err(err).Msg("util.WinAddTokenPrivilege()")
+	}
+
+	state, err := uefivars.ReadSecureBoot(glob.Options.Root)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read Secure Boot configuration")
+		return err
+	}
+	report := secureBootReport{SecureBoot: state}
+
+	reference := sb.DbxUpdate
+	if reference == "" {
+		reference = uefivars.FindDbxUpdate(glob.Options.Root)
+	}
+	report.Dbx, err = checkDbx(state.ForbiddenKeys, reference)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read dbx update")
+		return err
+	}
+
+	if sb.JSON {
+		buf, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, string(buf))
+		return nil
+	}
+	printSecureBoot(os.Stdout, &report)
+	return nil
+}
@@ -3,18 +3,17 @@ package core
 import (
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"io"
 	"strconv"
 
 	"github.com/google/go-tpm/tpm2"
-	"github.com/gowebpki/jcs"
-
 	"github.com/immune-gmbh/agent/v3/pkg/api"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/bootapps"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/ima"
+	"github.com/immune-gmbh/agent/v3/pkg/state"
 	"github.com/immune-gmbh/agent/v3/pkg/tcg"
 	"github.com/immune-gmbh/agent/v3/pkg/tui"
 )
@@ -65,8 +64,13 @@ func (ac *AttestationClient) Attest(ctx context.Context, dryRun bool) (*api.Evid
 		return nil, err
 	}
 
+	// confidential VMs can bind the evidence to their attestation report if there is no TPM
 	a, err := tcg.OpenTPM(ac.State.TPM, ac.State.StubState)
-	if err != nil {
+	if err != nil && IsConfidentialVM() {
+		ac.Log.Debug().Err(err).Msg("tcg.OpenTPM(ac.State.TPM, ac.State.StubState)")
+		ac.Log.Info().Msg("No TPM available, attesting with the confidential VM report only")
+		a = nil
+	} else if err != nil {
 		ac.Log.Debug().Err(err).Msg("tcg.OpenTPM(ac.State.TPM, ac.State.StubState)")
 
 		// XXX we should properly map errors of supporting packages to attestation client error codes in the future
@@ -78,8 +82,9 @@ func (ac *AttestationClient) Attest(ctx context.Context, dryRun bool) (*api.Evid
 		}
 
 		return nil, ErrOpenTrustAnchor
+	} else {
+		defer a.Close()
 	}
-	defer a.Close()
 
 	// if it is a real TPM get it's RWC for GatherFirmwareData
 	var conn io.ReadWriteCloser
@@ -87,115 +92,47 @@ func (ac *AttestationClient) Attest(ctx context.Context, dryRun bool) (*api.Evid
 		conn = anch.Conn
 	}
 
-	// collect firmware info
-	tui.SetUIState(tui.StCollectFirmwareInfo)
-	ac.Log.Info().Msg("Collecting firmware info")
-	fwProps := firmware.GatherFirmwareData(conn, &ac.State.Config)
-	fwProps.Agent.Release = *ac.ReleaseId
-
-	// compress and prepare hashblobs for out-of-band transfer (only include their hashes in fwPropsJSON and quoted JCS transform)
-	hashBlobs := api.ProcessFirmwarePropertiesHashBlobs(&fwProps)
-
-	// transform firmware info into json and crypto-safe canonical json representations
-	fwPropsJSON, err := json.Marshal(fwProps)
-	if err != nil {
-		ac.Log.Debug().Err(err).Msg("json.Marshal(FirmwareProperties)")
-		return nil, ErrEncodeJson
-	}
-	fwPropsJCS, err := jcs.Transform(fwPropsJSON)
-	if err != nil {
-		ac.Log.Debug().Err(err).Msg("jcs.Transform(FirmwareProperties)")
-		return nil, ErrEncodeJson
-	}
-	fwPropsHash := sha256.Sum256(fwPropsJCS)
-
-	toQuote, allPCRs, err := ac.readAllPCRBanks(ctx, a)
-	if err != nil {
-		ac.Log.Debug().Err(err).Msg("readAllPCRBanks()")
-		return nil, ErrReadPcr
-	}
-
-	// load Root key
-	tui.SetUIState(tui.StQuotePCR)
-	ac.Log.Info().Msg("Signing attestation data")
-	rootHandle, rootPub, err := a.CreateAndLoadRoot(ac.EndorsementAuth, ac.State.Root.Auth, &ac.State.Config.Root.Public)
-	if err != nil {
-		ac.Log.Debug().Err(err).Msg("tcg.CreateAndLoadRoot(..)")
-		return nil, ErrRootKey
+	// the config decides which hardware interfaces are probed, never use one that isn't signed
+	if err := ac.State.VerifyConfig(ac.ConfigKey); err != nil {
+		ac.Log.Debug().Err(err).Msg("State.VerifyConfig()")
+		return nil, ErrConfigSignature
 	}
-	defer rootHandle.Flush(a)
 
-	// make sure we're on the right TPM
-	rootName, err := api.ComputeName(rootPub)
-	if err != nil {
-		ac.Log.Debug().Err(err).Msg("Name(rootPub)")
-		return nil, ErrRootKey
-	}
-
-	// check the root name. this will change if the endorsement proof value is changed
-	if !api.EqualNames(&rootName, &ac.State.Root.Name) {
-		return nil, ErrRootKey
-	}
-
-	// load AIK
+	// the AIK credential authenticates us to the server even if there is no TPM to quote with
 	aik, ok := ac.State.Keys["aik"]
 	if !ok {
 		return nil, ErrAik
 	}
-	aikHandle, err := a.LoadDeviceKey(rootHandle, ac.State.Root.Auth, aik.Public, aik.Private)
-	if err != nil {
-		ac.Log.Debug().Err(err).Msg("LoadDeviceKey(..)")
-		return nil, ErrAik
-	}
-	defer aikHandle.Flush(a)
-	rootHandle.Flush(a)
 
-	// convert used PCR banks to tpm2.Algorithm selection for quote
-	var algs []tpm2.Algorithm
-	for k := range allPCRs {
-		alg, err := strconv.ParseInt(k, 10, 16)
-		if err != nil {
-			ac.Log.Debug().Err(err).Msg("ParseInt failed")
-			return nil, ErrQuote
-		}
-		algs = append(algs, tpm2.Algorithm(alg))
-	}
+	// collect firmware info
+	tui.SetUIState(tui.StCollectFirmwareInfo)
+	ac.Log.Info().Msg("Collecting firmware info")
+	fwProps := firmware.GatherFirmwareData(conn, &ac.State.Config, &ac.Options)
+	fwProps.Agent.Release = *ac.ReleaseId
 
-	// generate quote
-	ac.Log.Trace().Msg("generate quote")
-	quote, sig, err := a.Quote(aikHandle, aik.Auth, fwPropsHash[:], algs, toQuote)
-	if err != nil || (sig.ECC == nil && sig.RSA == nil) {
-		ac.Log.Debug().Err(err).Msg("TPM2_Quote failed")
-		return nil, ErrQuote
+	// without the server's appraisal at least point out boot loaders Secure Boot would refuse
+	var revocations []bootapps.Revocation
+	if dryRun {
+		revocations = ac.checkBootApps(&fwProps)
 	}
-	aikHandle.Flush(a)
 
-	// fetch the runtime measurment log
-	//XXX 1) should only run on linux 2) must check errors 3) is placed here because fw report can't report data that should be omitted from quote and b/c this data is part of PCRs anyway
-	//TODO: check if this can be fixed using the new blob out of band transfer mechanism
-	fwProps.IMALog = new(api.ErrorBuffer)
-	ima.ReportIMALog(fwProps.IMALog)
+	// compress and prepare hashblobs for out-of-band transfer (only include their hashes in fwPropsJSON and quoted JCS transform)
+	hashBlobs := api.ProcessFirmwarePropertiesHashBlobs(&fwProps)
 
-	cookie, _ := api.Cookie(rand.Reader)
-	evidence := api.Evidence{
-		Type:      api.EvidenceType,
-		Quote:     &quote,
-		Signature: &sig,
-		Algorithm: strconv.Itoa(int(ac.State.Config.PCRBank)),
-		PCRs:      allPCRs[strconv.Itoa(int(ac.State.Config.PCRBank))],
-		AllPCRs:   allPCRs,
-		Firmware:  fwProps,
-		Cookie:    cookie,
+	evidence, err := ac.bindEvidence(ctx, a, &aik, fwProps)
+	if err != nil {
+		return nil, err
 	}
 
 	if dryRun {
-		return &evidence, nil
+		ac.showRevocations(revocations)
+		return evidence, nil
 	}
 
 	// API call
 	tui.SetUIState(tui.StSendEvidence)
 	ac.Log.Info().Msg("Sending report to immune Guard cloud")
-	attestResponse, webLink, err := ac.Client.Attest(ctx, aik.Credential, evidence, hashBlobs)
+	attestResponse, webLink, err := ac.Client.Attest(ctx, aik.Credential, *evidence, hashBlobs)
 	if err != nil {
 		ac.Log.Debug().Err(err).Msg("client.Attest(..)")
 
@@ -220,7 +157,7 @@ func (ac *AttestationClient) Attest(ctx context.Context, dryRun bool) (*api.Evid
 		if webLink != "" {
 			ac.Log.Info().Msgf("See detailed results here: %s", webLink)
 		}
-		return &evidence, nil
+		return evidence, nil
 	} else {
 		tui.SetUIState(tui.StAttestationSuccess)
 		ac.Log.Info().Msg("Attestation successful")
@@ -264,5 +201,114 @@ func (ac *AttestationClient) Attest(ctx context.Context, dryRun bool) (*api.Evid
 		ac.Log.Debug().Msg(string(appraisal))
 	}
 
+	return evidence, nil
+}
+
+// bindEvidence binds the firmware properties to a TPM quote and, inside confidential VMs, to the attestation reports.
+// Without a TPM (a is nil) the reports are the only binding and one of them must succeed.
+func (ac *AttestationClient) bindEvidence(ctx context.Context, a tcg.TrustAnchor, aik *state.DeviceKeyV3, fwProps api.FirmwareProperties) (*api.Evidence, error) {
+	fwPropsHash, err := FirmwarePropertiesHash(&fwProps)
+	if err != nil {
+		ac.Log.Debug().Err(err).Msg("FirmwarePropertiesHash()")
+		return nil, ErrEncodeJson
+	}
+
+	evidence := api.Evidence{
+		Type:      api.EvidenceType,
+		Algorithm: strconv.Itoa(int(ac.State.Config.PCRBank)),
+	}
+	if a != nil {
+		toQuote, allPCRs, err := ac.readAllPCRBanks(ctx, a)
+		if err != nil {
+			ac.Log.Debug().Err(err).Msg("readAllPCRBanks()")
+			return nil, ErrReadPcr
+		}
+
+		quote, sig, err := ac.quote(a, aik, fwPropsHash, allPCRs, toQuote)
+		if err != nil {
+			return nil, err
+		}
+		evidence.Quote = &quote
+		evidence.Signature = &sig
+		evidence.PCRs = allPCRs[evidence.Algorithm]
+		evidence.AllPCRs = allPCRs
+	}
+
+	// fetch the runtime measurment log
+	//XXX 1) should only run on linux 2) must check errors 3) is placed here because fw report can't report data that should be omitted from quote and b/c this data is part of PCRs anyway
+	//TODO: check if this can be fixed using the new blob out of band transfer mechanism
+	fwProps.IMALog = new(api.ErrorBuffer)
+	if ac.Options.Enabled(firmware.CollectorIMA) {
+		ima.ReportIMALog(fwProps.IMALog, ac.Options.Root)
+	} else {
+		fwProps.IMALog.Error = api.DeniedByPolicy
+	}
+
+	// confidential VMs additionally bind the firmware properties to an AMD SEV-SNP or Intel TDX attestation report
+	var bound bool
+	evidence.SEVSNP, evidence.TDX, bound = ReportConfidentialVM(fwPropsHash, &ac.Options)
+	if a == nil && !bound {
+		ac.Log.Info().Msg("Neither a TPM nor a confidential VM attestation report is available")
+		return nil, ErrOpenTrustAnchor
+	}
+
+	evidence.Firmware = fwProps
+	evidence.Cookie, _ = api.Cookie(rand.Reader)
 	return &evidence, nil
 }
+
+// quote signs fwPropsHash and the PCRs toQuote of all banks in allPCRs with the AIK
+func (ac *AttestationClient) quote(a tcg.TrustAnchor, aik *state.DeviceKeyV3, fwPropsHash []byte, allPCRs map[string]map[string]api.Buffer, toQuote []int) (api.Attest, api.Signature, error) {
+	// load Root key
+	tui.SetUIState(tui.StQuotePCR)
+	ac.Log.Info().Msg("Signing attestation data")
+	rootHandle, rootPub, err := a.CreateAndLoadRoot(ac.EndorsementAuth, ac.State.Root.Auth, &ac.State.Config.Root.Public)
+	if err != nil {
+		ac.Log.Debug().Err(err).Msg("tcg.CreateAndLoadRoot(..)")
+		return api.Attest{}, api.Signature{}, ErrRootKey
+	}
+	defer rootHandle.Flush(a)
+
+	// make sure we're on the right TPM
+	rootName, err := api.ComputeName(rootPub)
+	if err != nil {
+		ac.Log.Debug().Err(err).Msg("Name(rootPub)")
+		return api.Attest{}, api.Signature{}, ErrRootKey
+	}
+
+	// check the root name. this will change if the endorsement proof value is changed
+	if !api.EqualNames(&rootName, &ac.State.Root.Name) {
+		return api.Attest{}, api.Signature{}, ErrRootKey
+	}
+
+	// load AIK
+	aikHandle, err := a.LoadDeviceKey(rootHandle, ac.State.Root.Auth, aik.Public, aik.Private)
+	if err != nil {
+		ac.Log.Debug().Err(err).Msg("LoadDeviceKey(..)")
+		return api.Attest{}, api.Signature{}, ErrAik
+	}
+	defer aikHandle.Flush(a)
+	rootHandle.Flush(a)
+
+	// convert used PCR banks to tpm2.Algorithm selection for quote
+	var algs []tpm2.Algorithm
+	for k := range allPCRs {
+		alg, err := strconv.ParseInt(k, 10, 16)
+		if err != nil {
+			ac.Log.Debug().Err(err).Msg("ParseInt failed")
+			return api.Attest{}, api.Signature{}, ErrQuote
+		}
+		algs = append(algs, tpm2.Algorithm(alg))
+	}
+
+	// generate quote
+	ac.Log.Trace().Msg("generate quote")
+	quote, sig, err := a.Quote(aikHandle, aik.Auth, fwPropsHash, algs, toQuote)
+	if err != nil || (sig.ECC == nil && sig.RSA == nil) {
+		ac.Log.Debug().Err(err).Msg("TPM2_Quote failed")
+		return api.Attest{}, api.Signature{}, ErrQuote
+	}
+	aikHandle.Flush(a)
+
+	return quote, sig, nil
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/gowebpki/jcs"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/sev"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/tdx"
+)
+
+var (
+	// replaced in tests
+	isSNPGuest = sev.IsSNPGuest
+	isTDXGuest = tdx.IsTDXGuest
+	reportSNP  = sev.ReportSNPGuest
+	reportTDX  = tdx.ReportTDX
+)
+
+// IsConfidentialVM returns true if we run inside an AMD SEV-SNP or Intel TDX guest
+func IsConfidentialVM() bool {
+	return isSNPGuest() || isTDXGuest()
+}
+
+// FirmwarePropertiesHash returns the SHA-256 of the canonical JSON representation of fwProps. This is the value
+// quoted by the TPM and used as report data of confidential VM attestation reports.
+func FirmwarePropertiesHash(fwProps *api.FirmwareProperties) ([]byte, error) {
+	fwPropsJSON, err := json.Marshal(fwProps)
+	if err != nil {
+		return nil, err
+	}
+	fwPropsJCS, err := jcs.Transform(fwPropsJSON)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(fwPropsJCS)
+	return hash[:], nil
+}
+
+// ReportConfidentialVM binds hash to the attestation reports of the confidential VM we run in. Reports are nil
+// outside of the respective guest type. bound is true if at least one report was produced.
+func ReportConfidentialVM(hash []byte, opts *firmware.Options) (snpReport *api.SEVSNPReport, tdxReport *api.TDXReport, bound bool) {
+	if isSNPGuest() {
+		snpReport = new(api.SEVSNPReport)
+		if opts.Enabled(firmware.CollectorSEV) {
+			bound = reportSNP(snpReport, hash) == nil
+		} else {
+			snpReport.Error = api.DeniedByPolicy
+		}
+	}
+
+	if isTDXGuest() {
+		tdxReport = new(api.TDXReport)
+		if opts.Enabled(firmware.CollectorTDX) {
+			bound = reportTDX(tdxReport, hash, opts.Root) == nil || bound
+		} else {
+			tdxReport.Error = api.DeniedByPolicy
+		}
+	}
+
+	return
+}
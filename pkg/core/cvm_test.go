@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/state"
+)
+
+func withFakeTDXGuest(t *testing.T, report func(*api.TDXReport, []byte, common.Root) error) {
+	savedSNP, savedTDX, savedReport := isSNPGuest, isTDXGuest, reportTDX
+	t.Cleanup(func() { isSNPGuest, isTDXGuest, reportTDX = savedSNP, savedTDX, savedReport })
+	isSNPGuest = func() bool { return false }
+	isTDXGuest = func() bool { return true }
+	reportTDX = report
+}
+
+func newTestCore() *AttestationClient {
+	ac := NewCore()
+	ac.Log = &log.Logger
+	ac.State = state.NewState()
+	ac.Options.Disabled = []string{firmware.CollectorIMA}
+	return ac
+}
+
+func TestBindEvidenceWithoutTPM(t *testing.T) {
+	withFakeTDXGuest(t, func(report *api.TDXReport, reportData []byte, _ common.Root) error {
+		report.Report = reportData
+		return nil
+	})
+
+	ac := newTestCore()
+	fwProps := api.FirmwareProperties{OS: api.OS{Hostname: "td"}}
+	hash, err := FirmwarePropertiesHash(&fwProps)
+	assert.NoError(t, err)
+
+	evidence, err := ac.bindEvidence(context.Background(), nil, nil, fwProps)
+	assert.NoError(t, err)
+	assert.Nil(t, evidence.Quote)
+	assert.Nil(t, evidence.Signature)
+	assert.Nil(t, evidence.SEVSNP)
+	if assert.NotNil(t, evidence.TDX) {
+		assert.Equal(t, api.Buffer(hash), evidence.TDX.Report)
+	}
+	assert.Equal(t, "td", evidence.Firmware.OS.Hostname)
+	assert.Equal(t, api.DeniedByPolicy, evidence.Firmware.IMALog.Error)
+}
+
+func TestBindEvidenceWithoutTPMOrReport(t *testing.T) {
+	withFakeTDXGuest(t, func(report *api.TDXReport, reportData []byte, _ common.Root) error {
+		report.Error = api.NoResponse
+		return errors.New("no tdx module")
+	})
+
+	ac := newTestCore()
+	_, err := ac.bindEvidence(context.Background(), nil, nil, api.FirmwareProperties{})
+	assert.ErrorIs(t, err, ErrOpenTrustAnchor)
+
+	// a report denied by policy doesn't bind the evidence either
+	ac.Options.Disabled = append(ac.Options.Disabled, firmware.CollectorTDX)
+	_, err = ac.bindEvidence(context.Background(), nil, nil, api.FirmwareProperties{})
+	assert.ErrorIs(t, err, ErrOpenTrustAnchor)
+}
+
+func TestBindEvidenceSNPWithoutTPM(t *testing.T) {
+	savedSNP, savedTDX, savedReport := isSNPGuest, isTDXGuest, reportSNP
+	t.Cleanup(func() { isSNPGuest, isTDXGuest, reportSNP = savedSNP, savedTDX, savedReport })
+	isSNPGuest = func() bool { return true }
+	isTDXGuest = func() bool { return false }
+	reportSNP = func(report *api.SEVSNPReport, reportData []byte) error {
+		report.Report = reportData
+		return nil
+	}
+	assert.True(t, IsConfidentialVM())
+
+	ac := newTestCore()
+	fwProps := api.FirmwareProperties{}
+	hash, err := FirmwarePropertiesHash(&fwProps)
+	assert.NoError(t, err)
+
+	evidence, err := ac.bindEvidence(context.Background(), nil, nil, fwProps)
+	assert.NoError(t, err)
+	assert.Nil(t, evidence.Quote)
+	assert.Nil(t, evidence.TDX)
+	if assert.NotNil(t, evidence.SEVSNP) {
+		assert.Equal(t, api.Buffer(hash), evidence.SEVSNP.Report)
+	}
+}
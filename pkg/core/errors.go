@@ -34,6 +34,8 @@ var (
 	ErrStateLoad       = AttestationClientError("other state load error")
 	ErrStateStore      = AttestationClientError("other state store error")
 	ErrUpdateConfig    = AttestationClientError("fetch config from server")
+	ErrConfigSignature = AttestationClientError("verify config signature")
+	ErrPolicyLoad      = AttestationClientError("load local policy")
 )
 
 // LogEnrollErrors is a helper function to translate errors to text and log them directly
@@ -64,6 +66,8 @@ func LogEnrollErrors(l *zerolog.Logger, err error) {
 		l.Error().Msg("Cannot open TPM")
 	} else if errors.Is(err, ErrUpdateConfig) {
 		l.Error().Msg("Failed to load configuration from server")
+	} else if errors.Is(err, ErrConfigSignature) {
+		l.Error().Msg("Configuration from server is not properly signed.")
 	} else {
 		l.Error().Msg("Enrollment failed. An unknown error occured. Please try again later.")
 	}
@@ -95,6 +99,8 @@ func LogAttestErrors(l *zerolog.Logger, err error) {
 		l.Error().Msg("Cannot open TPM")
 	} else if errors.Is(err, ErrUpdateConfig) {
 		l.Error().Msg("Failed to load configuration from server")
+	} else if errors.Is(err, ErrConfigSignature) {
+		l.Error().Msg("Configuration from server is not properly signed.")
 	} else if errors.Is(err, ErrStateStore) {
 		l.Error().Msg("Failed to store state.")
 	} else if err != nil {
@@ -112,6 +118,8 @@ func LogInitErrors(l *zerolog.Logger, err error) {
 		l.Error().Msg("Failed to load state.")
 	} else if errors.Is(err, ErrStateStore) {
 		l.Error().Msg("Failed to store state.")
+	} else if errors.Is(err, ErrPolicyLoad) {
+		l.Error().Msg("Failed to load local policy, check file permissions and syntax.")
 	} else {
 		l.Error().Msg("Unknown error occured during initialization.")
 	}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"crypto/ed25519"
 	"errors"
 	"net/url"
 	"os"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/immune-gmbh/agent/v3/pkg/api"
 	"github.com/immune-gmbh/agent/v3/pkg/must"
+	"github.com/immune-gmbh/agent/v3/pkg/policy"
 	"github.com/immune-gmbh/agent/v3/pkg/state"
 	"github.com/rs/zerolog"
 )
@@ -17,16 +19,33 @@ var (
 	// this is set by the build environment
 	releaseId string = "unknown"
 
+	// base64 encoded Ed25519 key server configurations must be signed with, set by the build environment
+	// development builds without a pinned key accept unsigned configurations
+	configSigningKey string = ""
+
 	// defaults
 	defaultServerURL       *url.URL = must.Get(url.Parse("https://api.immune.app/v2"))
 	defaultEndorsementAuth string   = ""
 )
 
+// DefaultPolicyPath returns the OS-specific location of the local hardware access policy
+func DefaultPolicyPath() string {
+	return filepath.Join(state.DefaultConfigDir(), policy.DefaultFileName)
+}
+
 func NewCore() *AttestationClient {
 	return &AttestationClient{
 		ReleaseId:       &releaseId,
 		EndorsementAuth: defaultEndorsementAuth,
+		ConfigKey:       must.Get(parseConfigSigningKey(configSigningKey)),
+	}
+}
+
+func parseConfigSigningKey(str string) (ed25519.PublicKey, error) {
+	if str == "" {
+		return nil, nil
 	}
+	return api.ParseConfigurationKey(str)
 }
 
 // load and migrate on-disk state
@@ -90,8 +109,11 @@ func (ac *AttestationClient) getServerUrl() *url.URL {
 
 // try to get a new configuration from server
 func (ac *AttestationClient) updateConfig() error {
-	update, err := ac.State.EnsureFresh(&ac.Client)
-	if err != nil {
+	update, err := ac.State.EnsureFresh(&ac.Client, ac.ConfigKey)
+	if errors.Is(err, api.ErrSignatureMissing) || errors.Is(err, api.ErrSignatureInvalid) {
+		ac.Log.Debug().Err(err).Msg("verifying fresh config")
+		return ErrConfigSignature
+	} else if err != nil {
 		ac.Log.Debug().Err(err).Msg("fetching fresh config")
 		return ErrUpdateConfig
 	}
@@ -108,16 +130,28 @@ func (ac *AttestationClient) updateConfig() error {
 	return nil
 }
 
-func (ac *AttestationClient) Init(stateDir string, logger *zerolog.Logger) error {
+func (ac *AttestationClient) Init(stateDir string, policyPath string, logger *zerolog.Logger) error {
 	ac.Log = logger
 
+	// load local hardware access policy
+	pol, err := policy.Load(policyPath)
+	if err != nil {
+		ac.Log.Debug().Err(err).Msgf("policy.Load(%s)", policyPath)
+		return ErrPolicyLoad
+	}
+	ac.Options.Policy = pol
+
+	if ac.ConfigKey == nil {
+		ac.Log.Debug().Msg("no configuration signing key pinned, accepting unsigned configurations")
+	}
+
 	// load on-disk state
 	if err := ac.initState(stateDir); err != nil {
 		return err
 	}
 
 	// init API client
-	ac.Client = api.NewClient(ac.getServerUrl(), nil, releaseId)
+	ac.Client = api.NewClient(ac.getServerUrl(), nil, ac.Proxy, releaseId)
 
 	return nil
 }
@@ -128,5 +162,11 @@ func (ac *AttestationClient) OverrideServerUrl(server *url.URL) {
 	// store URL in state
 	ac.State.ServerURL = server
 	// re-init API client
-	ac.Client = api.NewClient(ac.getServerUrl(), nil, releaseId)
+	ac.Client = api.NewClient(ac.getServerUrl(), nil, ac.Proxy, releaseId)
+}
+
+// SetProxy changes the HTTP proxy used to contact the server and re-inits the API client
+func (ac *AttestationClient) SetProxy(proxy *url.URL) {
+	ac.Proxy = proxy
+	ac.Client = api.NewClient(ac.getServerUrl(), nil, ac.Proxy, releaseId)
 }
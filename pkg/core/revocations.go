@@ -0,0 +1,48 @@
+package core
+
+import (
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/bootapps"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefivars"
+	"github.com/immune-gmbh/agent/v3/pkg/tui"
+)
+
+// checkBootApps flags boot applications on the ESP that Secure Boot refuses or that are known to be vulnerable
+func (ac *AttestationClient) checkBootApps(fwProps *api.FirmwareProperties) []bootapps.Revocation {
+	if fwProps.BootApps == nil || len(fwProps.BootApps.Images) == 0 {
+		return nil
+	}
+
+	var lists bootapps.RevocationLists
+	sb, err := uefivars.ReadSecureBoot(ac.Options.Root)
+	if err != nil {
+		ac.Log.Debug().Err(err).Msg("uefivars.ReadSecureBoot(ac.Options.Root)")
+	} else {
+		if sb.ForbiddenKeys != nil {
+			lists.Dbx = append(lists.Dbx, *sb.ForbiddenKeys...)
+		}
+		if sb.MokListX != nil {
+			lists.Dbx = append(lists.Dbx, *sb.MokListX...)
+		}
+		lists.SbatLevel = sb.SbatLevel
+	}
+	if path := uefivars.FindDbxUpdate(ac.Options.Root); path != "" {
+		lists.DbxUpdate, err = uefivars.ReadDbxUpdate(path)
+		if err != nil {
+			ac.Log.Debug().Err(err).Msg("uefivars.ReadDbxUpdate()")
+		}
+	}
+
+	return bootapps.CheckRevocations(fwProps.BootApps.Images, &lists)
+}
+
+func (ac *AttestationClient) showRevocations(revocations []bootapps.Revocation) {
+	for _, r := range revocations {
+		if r.Revoked {
+			ac.Log.Warn().Str("path", r.Path).Msgf("Revoked boot application: %s", r.Reason)
+		} else {
+			ac.Log.Warn().Str("path", r.Path).Msgf("Vulnerable boot application: %s", r.Reason)
+		}
+		tui.ShowBootAppRevocation(r.Path, r.Reason, r.Revoked)
+	}
+}
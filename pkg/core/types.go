@@ -1,7 +1,11 @@
 package core
 
 import (
+	"crypto/ed25519"
+	"net/url"
+
 	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware"
 	"github.com/immune-gmbh/agent/v3/pkg/state"
 	"github.com/rs/zerolog"
 )
@@ -16,10 +20,17 @@ type AttestationClient struct {
 
 	// API client
 	Client api.Client
+	Proxy  *url.URL
+
+	// key server configurations must be signed with, nil if unpinned
+	ConfigKey ed25519.PublicKey
 
 	// TPM
 	EndorsementAuth string
 
+	// local policy and restrictions on what is collected
+	Options firmware.Options
+
 	// Logging
 	Log *zerolog.Logger
 }
@@ -6,10 +6,10 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func ReportACPITables(acpiTables *api.ACPITables) error {
+func ReportACPITables(acpiTables *api.ACPITables, root common.Root) error {
 	log.Trace().Msg("ReportACPITables()")
 
-	t, err := readACPITables()
+	t, err := readACPITables(root)
 	if err != nil {
 		acpiTables.Error = common.ServeApiError(common.MapFSErrors(err))
 		log.Debug().Err(err).Msg("acpi.ReadACPITables()")
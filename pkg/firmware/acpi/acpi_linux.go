@@ -13,8 +13,8 @@ var (
 	sysfsDir = "/sys/firmware/acpi/tables"
 )
 
-func readACPITables() (map[string][]byte, error) {
-	files, err := os.ReadDir(sysfsDir)
+func readACPITables(root common.Root) (map[string][]byte, error) {
+	files, err := os.ReadDir(root.Path(sysfsDir))
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +26,7 @@ func readACPITables() (map[string][]byte, error) {
 			continue
 		}
 
-		path := path.Join(sysfsDir, f.Name())
+		path := path.Join(root.Path(sysfsDir), f.Name())
 		buf, err := readACPITableFile(path)
 		if err != nil {
 			log.Debug().Err(err).Msgf("getting acpi table: %s", f.Name())
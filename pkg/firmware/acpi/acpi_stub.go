@@ -5,8 +5,10 @@ package acpi
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readACPITables() (map[string][]byte, error) {
+func readACPITables(root common.Root) (map[string][]byte, error) {
 	return nil, errors.New("acpi.readACPITables not implemented on " + runtime.GOOS)
 }
@@ -5,6 +5,7 @@ import (
 	"syscall"
 	"unsafe"
 
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 	"github.com/immune-gmbh/agent/v3/pkg/util"
 	"github.com/rs/zerolog/log"
 )
@@ -94,7 +95,7 @@ func readACPITable(firmwareTableID uint32) ([]byte, error) {
 	return buf, nil
 }
 
-func readACPITables() (map[string][]byte, error) {
+func readACPITables(root common.Root) (map[string][]byte, error) {
 	tableIDs, err := enumACPITableIDs()
 	if err != nil {
 		return nil, err
@@ -6,10 +6,10 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func ReportBiosFlash(flash *api.HashBlob) error {
+func ReportBiosFlash(flash *api.HashBlob, root common.Root) error {
 	log.Trace().Msg("ReportBiosFlash()")
 
-	buf, err := readBiosFlashMMap()
+	buf, err := readBiosFlashMMap(root)
 	if err != nil {
 		flash.Error = common.ServeApiError(common.MapFSErrors(err))
 		log.Debug().Err(err).Msg("biosflash.ReportBiosFlash()")
@@ -16,8 +16,8 @@ const (
 	biosRegionMmap = 0xFF000000
 )
 
-func readBiosFlashMMap() (outBuf []byte, err error) {
-	f, err := os.Open(flashFilePath)
+func readBiosFlashMMap(root common.Root) (outBuf []byte, err error) {
+	f, err := os.Open(root.Path(flashFilePath))
 	var r io.Reader
 	if os.IsNotExist(err) {
 		var fd *os.File
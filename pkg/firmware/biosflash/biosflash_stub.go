@@ -5,8 +5,10 @@ package biosflash
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readBiosFlashMMap() (outBuf []byte, err error) {
+func readBiosFlashMMap(root common.Root) (outBuf []byte, err error) {
 	return nil, errors.New("biosflash.ReadBiosFlashMMap not implemented on " + runtime.GOOS)
 }
@@ -1,9 +1,10 @@
 package biosflash
 
 import (
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/immunecpu"
 )
 
-func readBiosFlashMMap() (outBuf []byte, err error) {
+func readBiosFlashMMap(root common.Root) (outBuf []byte, err error) {
 	return immunecpu.ReadBiosFlashMMap()
 }
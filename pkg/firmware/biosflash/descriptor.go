@@ -0,0 +1,97 @@
+package biosflash
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+const (
+	descriptorSignature       = 0x0ff0a55a
+	descriptorSignatureOffset = 0x10
+	flcompReadFreq17MHz       = 6
+
+	regionDescriptor = 0
+	regionBIOS       = 1
+)
+
+var (
+	regionNames = []string{
+		"descriptor", "bios", "me", "gbe", "platform_data", "device_expansion", "secondary_bios", "microcode", "ec",
+		"device_expansion_2", "ie", "10gbe_a", "10gbe_b",
+	}
+	// FLMSTR1-5, the fourth one is reserved
+	masterNames = []string{"bios", "me", "gbe", "", "ec"}
+
+	ErrNoDescriptor = errors.New("no flash descriptor")
+)
+
+func regionName(i int) string {
+	if i < len(regionNames) {
+		return regionNames[i]
+	}
+	return fmt.Sprintf("region%d", i)
+}
+
+// decodeRegion decodes a FLREG or FREG register. Unused regions have a base above their limit.
+func decodeRegion(i int, v uint32) (api.FlashRegion, bool) {
+	base := (v & 0x7fff) << 12
+	limit := ((v>>16)&0x7fff)<<12 | 0xfff
+	return api.FlashRegion{Name: regionName(i), Base: base, Limit: limit}, base < limit
+}
+
+// ParseDescriptor decodes the region map and master access permissions of an Intel flash descriptor. The image must
+// start at flash address 0.
+func ParseDescriptor(image []byte) (*api.FlashDescriptor, error) {
+	if len(image) < 0x1000 || binary.LittleEndian.Uint32(image[descriptorSignatureOffset:]) != descriptorSignature {
+		return nil, ErrNoDescriptor
+	}
+	flmap0 := binary.LittleEndian.Uint32(image[0x14:])
+	flmap1 := binary.LittleEndian.Uint32(image[0x18:])
+	fcba := int(flmap0&0xff) << 4
+	frba := int((flmap0>>16)&0xff) << 4
+	fmba := int(flmap1&0xff) << 4
+	if fcba+4 > len(image) || frba == 0 || fmba <= frba || fmba+4*len(masterNames) > 0x1000 {
+		return nil, errors.New("invalid flash descriptor map")
+	}
+
+	// there is no version field, Skylake and later run the SPI bus at 17 MHz for reads
+	desc := api.FlashDescriptor{Version: 1}
+	numRegions, readShift, writeShift, numMasters := 5, 16, 24, 3
+	if (binary.LittleEndian.Uint32(image[fcba:])>>17)&0x7 == flcompReadFreq17MHz {
+		desc.Version = 2
+		numRegions, readShift, writeShift, numMasters = 12, 8, 20, 5
+	}
+	if n := (fmba - frba) / 4; n < numRegions {
+		numRegions = n
+	}
+
+	desc.Regions = []api.FlashRegion{}
+	for i := 0; i < numRegions; i++ {
+		if r, ok := decodeRegion(i, binary.LittleEndian.Uint32(image[frba+4*i:])); ok {
+			desc.Regions = append(desc.Regions, r)
+		}
+	}
+
+	desc.Masters = []api.FlashMaster{}
+	for i := 0; i < numMasters; i++ {
+		if masterNames[i] == "" {
+			continue
+		}
+		flmstr := binary.LittleEndian.Uint32(image[fmba+4*i:])
+		m := api.FlashMaster{Name: masterNames[i], Read: []string{}, Write: []string{}}
+		for r := 0; r < writeShift-readShift; r++ {
+			if flmstr&(1<<(readShift+r)) != 0 {
+				m.Read = append(m.Read, regionName(r))
+			}
+			if writeShift+r < 32 && flmstr&(1<<(writeShift+r)) != 0 {
+				m.Write = append(m.Write, regionName(r))
+			}
+		}
+		desc.Masters = append(desc.Masters, m)
+	}
+
+	return &desc, nil
+}
@@ -0,0 +1,63 @@
+package biosflash
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flreg(base, limit uint32) uint32 {
+	return base>>12 | (limit>>12)<<16
+}
+
+// testDescriptor builds a descriptor like ifdtool would for a 16 MiB Skylake image: descriptor, BIOS and ME region
+func testDescriptor(version int) []byte {
+	image := make([]byte, 0x1000)
+	le := binary.LittleEndian
+	le.PutUint32(image[0x10:], descriptorSignature)
+	le.PutUint32(image[0x14:], 0x30>>4|(0x40>>4)<<16)
+	le.PutUint32(image[0x18:], 0x80>>4)
+	if version == 2 {
+		le.PutUint32(image[0x30:], flcompReadFreq17MHz<<17)
+	}
+	le.PutUint32(image[0x40:], flreg(0, 0xfff))
+	le.PutUint32(image[0x44:], flreg(0x1000000-0x800000, 0xffffff))
+	le.PutUint32(image[0x48:], flreg(0x3000, 0x7fffff))
+	for i := 3; i < 16; i++ {
+		le.PutUint32(image[0x40+4*i:], 0x7fff)
+	}
+	if version == 2 {
+		// BIOS reads descriptor, BIOS and ME and writes BIOS, ME reads and writes ME only
+		le.PutUint32(image[0x80:], 0x7<<8|0x2<<20)
+		le.PutUint32(image[0x84:], 0x4<<8|0x4<<20)
+	} else {
+		le.PutUint32(image[0x80:], 0x7<<16|0x2<<24)
+		le.PutUint32(image[0x84:], 0x4<<16|0x4<<24)
+	}
+	return image
+}
+
+func TestParseDescriptor(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		desc, err := ParseDescriptor(testDescriptor(version))
+		assert.NoError(t, err)
+		assert.Equal(t, version, desc.Version)
+		assert.Len(t, desc.Regions, 3)
+		assert.Equal(t, "bios", desc.Regions[1].Name)
+		assert.Equal(t, uint32(0x800000), desc.Regions[1].Base)
+		assert.Equal(t, uint32(0xffffff), desc.Regions[1].Limit)
+		assert.Equal(t, uint32(0x7fffff), desc.Regions[2].Limit)
+		assert.Equal(t, "bios", desc.Masters[0].Name)
+		assert.Equal(t, []string{"descriptor", "bios", "me"}, desc.Masters[0].Read)
+		assert.Equal(t, []string{"bios"}, desc.Masters[0].Write)
+		assert.Equal(t, []string{"me"}, desc.Masters[1].Write)
+	}
+}
+
+func TestParseDescriptorMissing(t *testing.T) {
+	_, err := ParseDescriptor(make([]byte, 0x1000))
+	assert.Equal(t, ErrNoDescriptor, err)
+	_, err = ParseDescriptor(nil)
+	assert.Equal(t, ErrNoDescriptor, err)
+}
@@ -0,0 +1,244 @@
+package biosflash
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/pci"
+)
+
+const (
+	intelVendor = 0x8086
+
+	// Skylake and later have a dedicated SPI controller at 00:1f.5, older PCHs put it into the root complex
+	// register block of the LPC bridge at 00:1f.0
+	lpcDevice, lpcFunction = 0x1f, 0
+	spiDevice, spiFunction = 0x1f, 5
+	cfgSPIBAR0             = 0x10
+	cfgRCBA                = 0xf0
+	cfgBIOSCntl            = 0xdc
+	rcbaEnable             = 1 << 0
+	rcbaSPIOffset          = 0x3800
+
+	biosCntlBIOSWE = 1 << 0
+	biosCntlBLE    = 1 << 1
+	biosCntlSMMBWP = 1 << 5
+
+	spiHSFS           = 0x04
+	spiFRAP           = 0x50
+	spiFREG0          = 0x54
+	spiBARSize        = 0x100
+	numProtectedRange = 5
+	hsfsFDOPSS        = 1 << 13
+	hsfsFDV           = 1 << 14
+	hsfsFLOCKDN       = 1 << 15
+	prReadProtect     = 1 << 15
+	prWriteProtect    = 1 << 31
+)
+
+// LPC bridge device IDs of the ICH7 up to 9-series PCHs, the last ones to locate the SPI controller using RCBA.
+// Newer LPC bridges reuse offset 0xf0 for other registers.
+var rcbaLPCDevices = []struct{ first, last uint16 }{
+	{0x27b0, 0x27bd}, // ICH7
+	{0x2810, 0x2815}, // ICH8
+	{0x2912, 0x2919}, // ICH9
+	{0x3a14, 0x3a1a}, // ICH10
+	{0x3b00, 0x3b1f}, // 5-series and 3400
+	{0x1c40, 0x1c5f}, // 6-series and C200
+	{0x1d40, 0x1d41}, // C600 and X79
+	{0x1e40, 0x1e5f}, // 7-series and C216
+	{0x8c40, 0x8c5f}, // 8-series and C220
+	{0x9c40, 0x9c47}, // 8-series LP
+	{0x8cc0, 0x8cdf}, // 9-series
+	{0x9cc1, 0x9cc9}, // 9-series LP
+	{0x8d40, 0x8d5f}, // C610 and X99
+}
+
+// register layout of the SPI controller
+type spiLayout struct {
+	NumRegions int
+	PR0        int
+}
+
+var (
+	spiLayoutRCBA = spiLayout{NumRegions: 5, PR0: 0x74}
+	spiLayoutPCH  = spiLayout{NumRegions: 12, PR0: 0x84}
+
+	// replaced in tests
+	readConfig = func(device, function uint16, root common.Root) ([]byte, error) {
+		spaces := []api.PCIConfigSpace{{Device: device, Function: uint8(function)}}
+		if err := pci.ReportConfigSpaces(spaces, root); err != nil {
+			return nil, err
+		}
+		return spaces[0].Value, nil
+	}
+	readMMIO = readPhysical
+)
+
+// ReportFlashProtection decodes the flash descriptor found in the dump and the write protection configured in the
+// SPI controller
+func ReportFlashProtection(fp *api.FlashProtection, flash *api.HashBlob, root common.Root) error {
+	log.Trace().Msg("ReportFlashProtection()")
+
+	switch {
+	case len(flash.Data) == 0:
+		fp.DescriptorErr = flash.Error
+		if fp.DescriptorErr == api.NoError {
+			fp.DescriptorErr = api.NoResponse
+		}
+	default:
+		desc, err := ParseDescriptor(flash.Data)
+		if err != nil {
+			// flash_mmap and the memory mapped window usually only cover the BIOS region
+			fp.DescriptorErr = api.NoResponse
+			log.Debug().Err(err).Msg("biosflash.ReportFlashProtection(): descriptor")
+		} else {
+			fp.Descriptor = desc
+		}
+	}
+
+	err := reportSPIController(fp, root)
+	if err != nil {
+		fp.Error = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("biosflash.ReportFlashProtection()")
+		log.Warn().Msg("Failed to read SPI flash protection")
+		return err
+	}
+
+	return nil
+}
+
+func isIntel(cfg []byte) bool {
+	return len(cfg) > cfgBIOSCntl && binary.LittleEndian.Uint16(cfg) == intelVendor
+}
+
+func hasRCBA(cfg []byte) bool {
+	id := binary.LittleEndian.Uint16(cfg[2:])
+	for _, r := range rcbaLPCDevices {
+		if id >= r.first && id <= r.last {
+			return true
+		}
+	}
+	return false
+}
+
+func locateSPIController(root common.Root) (uint64, spiLayout, uint8, error) {
+	if cfg, err := readConfig(spiDevice, spiFunction, root); err == nil && isIntel(cfg) {
+		if bar := uint64(binary.LittleEndian.Uint32(cfg[cfgSPIBAR0:]) &^ 0xfff); bar != 0 {
+			return bar, spiLayoutPCH, cfg[cfgBIOSCntl], nil
+		}
+	}
+
+	cfg, err := readConfig(lpcDevice, lpcFunction, root)
+	if err != nil {
+		return 0, spiLayout{}, 0, err
+	}
+	if !isIntel(cfg) {
+		return 0, spiLayout{}, 0, common.ErrorNoResponse(errors.New("no Intel LPC bridge"))
+	}
+	if !hasRCBA(cfg) {
+		// 100-series and later PCHs that hide the SPI controller or unknown chipsets
+		return 0, spiLayout{}, 0, common.Error(api.NotImplemented, fmt.Errorf("LPC bridge %04x has no RCBA", binary.LittleEndian.Uint16(cfg[2:])))
+	}
+	rcba := binary.LittleEndian.Uint32(cfg[cfgRCBA:])
+	if rcba&rcbaEnable == 0 {
+		// the SPI controller is hidden by firmware
+		return 0, spiLayout{}, 0, common.ErrorNoResponse(errors.New("no SPI controller"))
+	}
+	return uint64(rcba&^0x3fff) + rcbaSPIOffset, spiLayoutRCBA, cfg[cfgBIOSCntl], nil
+}
+
+func reportSPIController(fp *api.FlashProtection, root common.Root) error {
+	bar, layout, biosCntl, err := locateSPIController(root)
+	if err != nil {
+		return err
+	}
+	fp.BIOSControl = &biosCntl
+	fp.BIOSWriteEnable = biosCntl&biosCntlBIOSWE != 0
+	fp.BIOSLockEnable = biosCntl&biosCntlBLE != 0
+	fp.SMMBIOSWriteProtect = biosCntl&biosCntlSMMBWP != 0
+
+	regs, err := readMMIO(bar, spiBARSize)
+	if err != nil {
+		return err
+	}
+	if len(regs) < spiBARSize {
+		return common.ErrorNoResponse(errors.New("short SPI BAR"))
+	}
+
+	hsfs := binary.LittleEndian.Uint16(regs[spiHSFS:])
+	fp.HSFS = &hsfs
+	fp.ConfigLockDown = hsfs&hsfsFLOCKDN != 0
+	fp.DescriptorOverride = hsfs&hsfsFDOPSS == 0
+
+	// FREGn and FRAP are only loaded from a valid flash descriptor
+	var bios *api.FlashRegion
+	if hsfs&hsfsFDV != 0 {
+		bios = reportRegions(fp, regs, layout)
+	}
+
+	fp.ProtectedRanges = []api.FlashProtectedRange{}
+	for i := 0; i < numProtectedRange; i++ {
+		pr := binary.LittleEndian.Uint32(regs[layout.PR0+4*i:])
+		if pr&(prReadProtect|prWriteProtect) == 0 {
+			continue
+		}
+		fp.ProtectedRanges = append(fp.ProtectedRanges, api.FlashProtectedRange{
+			Base:         (pr & 0x7fff) << 12,
+			Limit:        ((pr>>16)&0x7fff)<<12 | 0xfff,
+			ReadProtect:  pr&prReadProtect != 0,
+			WriteProtect: pr&prWriteProtect != 0,
+		})
+	}
+
+	fp.BIOSWriteProtected = biosWriteProtected(fp, bios)
+	return nil
+}
+
+// reportRegions decodes the flash regions and their BIOS access permissions, returning the BIOS region if present
+func reportRegions(fp *api.FlashProtection, regs []byte, layout spiLayout) *api.FlashRegion {
+	frap := binary.LittleEndian.Uint32(regs[spiFRAP:])
+	var bios *api.FlashRegion
+	fp.Regions = []api.FlashRegion{}
+	for i := 0; i < layout.NumRegions; i++ {
+		r, ok := decodeRegion(i, binary.LittleEndian.Uint32(regs[spiFREG0+4*i:]))
+		if !ok {
+			continue
+		}
+		if i < 8 {
+			r.BIOSRead = frap&(1<<i) != 0
+			r.BIOSWrite = frap&(1<<(8+i)) != 0
+		}
+		fp.Regions = append(fp.Regions, r)
+		if i == regionBIOS {
+			bios = &fp.Regions[len(fp.Regions)-1]
+		}
+	}
+	return bios
+}
+
+// biosWriteProtected returns true if the OS can't modify the BIOS region, either because only SMM code is allowed to
+// set BIOSWE or because the locked SPI controller denies writes to the whole region
+func biosWriteProtected(fp *api.FlashProtection, bios *api.FlashRegion) bool {
+	if fp.BIOSLockEnable && fp.SMMBIOSWriteProtect {
+		return true
+	}
+	// the BIOS could reprogram the access permissions and ranges otherwise
+	if bios == nil || !fp.ConfigLockDown {
+		return false
+	}
+	if !bios.BIOSWrite && !fp.DescriptorOverride {
+		return true
+	}
+	for _, pr := range fp.ProtectedRanges {
+		if pr.WriteProtect && pr.Base <= bios.Base && pr.Limit >= bios.Limit {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package biosflash
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+// readPhysical reads MMIO registers using 32 bit accesses
+func readPhysical(addr uint64, size int) ([]byte, error) {
+	fd, err := os.Open(common.DefaultDevMemPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	page := addr &^ uint64(os.Getpagesize()-1)
+	off := int(addr - page)
+	mem, err := syscall.Mmap(int(fd.Fd()), int64(page), off+size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Munmap(mem)
+
+	buf := make([]byte, size)
+	for i := 0; i+4 <= size; i += 4 {
+		binary.LittleEndian.PutUint32(buf[i:], binary.LittleEndian.Uint32(mem[off+i:]))
+	}
+	return buf, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package biosflash
+
+import (
+	"errors"
+	"runtime"
+)
+
+func readPhysical(addr uint64, size int) ([]byte, error) {
+	return nil, errors.New("biosflash.readPhysical not implemented on " + runtime.GOOS)
+}
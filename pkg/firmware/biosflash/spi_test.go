@@ -0,0 +1,167 @@
+package biosflash
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+const (
+	testSPIBAR  = 0xfe010000
+	testRCBA    = 0xfed1c000
+	testRCBABAR = testRCBA + rcbaSPIOffset
+	testLPCID   = 0x8c44 // Z87
+)
+
+type fakePCH struct {
+	biosCntl uint8
+	hsfs     uint16
+	frap     uint32
+	prs      []uint32
+	rcba     bool // pre-Skylake
+	lpcID    uint16
+	noFDV    bool
+}
+
+func (f *fakePCH) install(t *testing.T) {
+	savedConfig, savedMMIO := readConfig, readMMIO
+	t.Cleanup(func() { readConfig, readMMIO = savedConfig, savedMMIO })
+
+	layout, bar := spiLayoutPCH, uint64(testSPIBAR)
+	if f.rcba {
+		layout, bar = spiLayoutRCBA, testRCBABAR
+		if f.lpcID == 0 {
+			f.lpcID = testLPCID
+		}
+	}
+	readConfig = func(device, function uint16, root common.Root) ([]byte, error) {
+		cfg := make([]byte, 256)
+		if device != 0x1f || (function == 5) == f.rcba {
+			binary.LittleEndian.PutUint16(cfg, 0xffff)
+			return cfg, nil
+		}
+		binary.LittleEndian.PutUint16(cfg, intelVendor)
+		binary.LittleEndian.PutUint16(cfg[2:], f.lpcID)
+		binary.LittleEndian.PutUint32(cfg[cfgSPIBAR0:], testSPIBAR)
+		binary.LittleEndian.PutUint32(cfg[cfgRCBA:], testRCBA|rcbaEnable)
+		cfg[cfgBIOSCntl] = f.biosCntl
+		return cfg, nil
+	}
+	readMMIO = func(addr uint64, size int) ([]byte, error) {
+		if addr != bar {
+			return nil, errors.New("wrong SPI BAR")
+		}
+		regs := make([]byte, size)
+		hsfs := f.hsfs
+		if !f.noFDV {
+			hsfs |= hsfsFDV
+		}
+		binary.LittleEndian.PutUint16(regs[spiHSFS:], hsfs)
+		binary.LittleEndian.PutUint32(regs[spiFRAP:], f.frap)
+		binary.LittleEndian.PutUint32(regs[spiFREG0:], flreg(0, 0xfff))
+		binary.LittleEndian.PutUint32(regs[spiFREG0+4:], flreg(0x800000, 0xffffff))
+		binary.LittleEndian.PutUint32(regs[spiFREG0+8:], flreg(0x3000, 0x7fffff))
+		for i := 3; i < layout.NumRegions; i++ {
+			binary.LittleEndian.PutUint32(regs[spiFREG0+4*i:], 0x7fff)
+		}
+		for i, pr := range f.prs {
+			binary.LittleEndian.PutUint32(regs[layout.PR0+4*i:], pr)
+		}
+		return regs, nil
+	}
+}
+
+func TestReportFlashProtection(t *testing.T) {
+	pch := fakePCH{biosCntl: biosCntlBLE | biosCntlSMMBWP, hsfs: hsfsFLOCKDN | hsfsFDOPSS, frap: 0x0202 | 0x0101}
+	pch.install(t)
+
+	var fp api.FlashProtection
+	flash := api.HashBlob{Data: testDescriptor(2)}
+	assert.NoError(t, ReportFlashProtection(&fp, &flash, ""))
+	assert.Equal(t, api.NoError, fp.Error)
+	assert.Equal(t, 2, fp.Descriptor.Version)
+	assert.Len(t, fp.Regions, 3)
+	assert.True(t, fp.Regions[1].BIOSRead)
+	assert.True(t, fp.Regions[1].BIOSWrite)
+	assert.False(t, fp.Regions[2].BIOSRead)
+	assert.True(t, fp.BIOSLockEnable)
+	assert.True(t, fp.SMMBIOSWriteProtect)
+	assert.True(t, fp.ConfigLockDown)
+	assert.False(t, fp.DescriptorOverride)
+	assert.Empty(t, fp.ProtectedRanges)
+	assert.True(t, fp.BIOSWriteProtected)
+}
+
+func TestReportFlashProtectionUnlocked(t *testing.T) {
+	pch := fakePCH{biosCntl: biosCntlBIOSWE, hsfs: hsfsFDOPSS, frap: 0x0202, rcba: true}
+	pch.install(t)
+
+	var fp api.FlashProtection
+	flash := api.HashBlob{Error: api.NoPermission}
+	assert.NoError(t, ReportFlashProtection(&fp, &flash, ""))
+	assert.Nil(t, fp.Descriptor)
+	assert.Equal(t, api.NoPermission, fp.DescriptorErr)
+	assert.True(t, fp.BIOSWriteEnable)
+	assert.False(t, fp.ConfigLockDown)
+	assert.False(t, fp.BIOSWriteProtected)
+}
+
+func TestReportFlashProtectionRanges(t *testing.T) {
+	pch := fakePCH{hsfs: hsfsFLOCKDN | hsfsFDOPSS, frap: 0x0202, prs: []uint32{0, flreg(0x800000, 0xffffff) | prWriteProtect}}
+	pch.install(t)
+
+	var fp api.FlashProtection
+	assert.NoError(t, ReportFlashProtection(&fp, &api.HashBlob{Data: make([]byte, 0x1000)}, ""))
+	assert.Equal(t, api.NoResponse, fp.DescriptorErr)
+	assert.Equal(t, []api.FlashProtectedRange{{Base: 0x800000, Limit: 0xffffff, WriteProtect: true}}, fp.ProtectedRanges)
+	assert.True(t, fp.BIOSWriteProtected)
+
+	// the BIOS can remove the range before FLOCKDN is set
+	pch.hsfs = hsfsFDOPSS
+	fp = api.FlashProtection{}
+	assert.NoError(t, ReportFlashProtection(&fp, &api.HashBlob{}, ""))
+	assert.False(t, fp.BIOSWriteProtected)
+}
+
+func TestReportFlashProtectionHidden(t *testing.T) {
+	savedConfig := readConfig
+	t.Cleanup(func() { readConfig = savedConfig })
+	readConfig = func(device, function uint16, root common.Root) ([]byte, error) {
+		cfg := make([]byte, 256)
+		binary.LittleEndian.PutUint16(cfg, intelVendor)
+		binary.LittleEndian.PutUint16(cfg[2:], testLPCID)
+		return cfg, nil
+	}
+
+	var fp api.FlashProtection
+	assert.Error(t, ReportFlashProtection(&fp, &api.HashBlob{}, ""))
+	assert.Equal(t, api.NoResponse, fp.Error)
+	assert.Nil(t, fp.BIOSControl)
+}
+
+func TestReportFlashProtectionNoRCBA(t *testing.T) {
+	// a hidden SPI controller on a 100-series PCH, offset 0xf0 of its LPC bridge isn't RCBA
+	pch := fakePCH{rcba: true, lpcID: 0xa145}
+	pch.install(t)
+
+	var fp api.FlashProtection
+	assert.Error(t, ReportFlashProtection(&fp, &api.HashBlob{}, ""))
+	assert.Equal(t, api.NotImplemented, fp.Error)
+	assert.Nil(t, fp.BIOSControl)
+}
+
+func TestReportFlashProtectionNoDescriptor(t *testing.T) {
+	pch := fakePCH{hsfs: hsfsFLOCKDN | hsfsFDOPSS, frap: 0x0202, noFDV: true}
+	pch.install(t)
+
+	var fp api.FlashProtection
+	assert.NoError(t, ReportFlashProtection(&fp, &api.HashBlob{}, ""))
+	assert.Nil(t, fp.Regions)
+	assert.True(t, fp.ConfigLockDown)
+	assert.False(t, fp.BIOSWriteProtected)
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// where the ESP of a powered-off machine is mounted in its root file system
+var offlineESPMounts = []string{"/boot/efi", "/efi", "/boot"}
+
 func getBootAppMap(rootPath, mountPath string) (map[string]api.HashBlob, error) {
 	var bootApps = make(map[string]api.HashBlob)
 	rootPath = filepath.Clean(rootPath)
@@ -55,20 +58,60 @@ func getBootAppMap(rootPath, mountPath string) (map[string]api.HashBlob, error)
 	return bootApps, nil
 }
 
-func ReportBootApps(request *api.BootApps) {
-	path, err := getEfiSystemPartPath()
+// ReportBootApps hashes all files on the EFI system partition. If mountESP is true an unmounted ESP is mounted
+// read-only for the duration of the walk where the platform supports it.
+func ReportBootApps(request *api.BootApps, mountESP bool, root common.Root) {
+	partUUID, err := WithEfiSystemPartition(mountESP, root, func(path string) error {
+		bootApps, err := getBootAppMap(path, path)
+		request.Images = bootApps
+		return err
+	})
+	reportPEImages(request)
+	request.PartitionUUID = partUUID
 	if err != nil {
 		log.Debug().Err(err).Msg("bootapps.ReportBootApps()")
 		request.ImagesErr = common.ServeApiError(common.MapFSErrors(err))
 		return
 	}
+}
 
-	bootApps, err := getBootAppMap(path, path)
+// ReportOfflineBootApps hashes all files on the ESP of a powered-off machine mounted at root. Its partition UUID is
+// unknown.
+func ReportOfflineBootApps(request *api.BootApps, root common.Root) {
+	err := WithOfflineEfiSystemPartition(root, func(path string) error {
+		bootApps, err := getBootAppMap(path, path)
+		request.Images = bootApps
+		return err
+	})
+	reportPEImages(request)
 	if err != nil {
-		log.Debug().Err(err).Msg("bootapps.ReportBootApps()")
+		log.Debug().Err(err).Msg("bootapps.ReportOfflineBootApps()")
 		request.ImagesErr = common.ServeApiError(common.MapFSErrors(err))
-		return
 	}
+}
+
+// WithOfflineEfiSystemPartition runs fn on the first of the usual ESP mount points below root that holds an EFI
+// directory
+func WithOfflineEfiSystemPartition(root common.Root, fn func(path string) error) error {
+	for _, mount := range offlineESPMounts {
+		path := root.Path(mount)
+		for _, dir := range []string{"EFI", "efi"} {
+			if fi, err := os.Stat(filepath.Join(path, dir)); err == nil && fi.IsDir() {
+				log.Debug().Msgf("bootapps: using %s as ESP", path)
+				return fn(path)
+			}
+		}
+	}
+
+	return os.ErrNotExist
+}
 
-	request.Images = bootApps
+func reportPEImages(request *api.BootApps) {
+	if request.Images == nil {
+		return
+	}
+	request.PEImages = make(map[string]api.PEImage, len(request.Images))
+	for key, blob := range request.Images {
+		request.PEImages[key] = reportPEImage(blob.Data)
+	}
 }
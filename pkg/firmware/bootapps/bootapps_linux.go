@@ -1,5 +1,339 @@
 package bootapps
 
-func getEfiSystemPartPath() (string, error) {
-	return "/boot/efi/", nil
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+const (
+	espTypeGUID = "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"
+	espTypeMBR  = "0xef"
+	gptHdrSig   = "EFI PART"
+)
+
+var (
+	procMountInfo = "/proc/self/mountinfo"
+	sysDevBlock   = "/sys/dev/block"
+	sysClassBlock = "/sys/class/block"
+	udevData      = "/run/udev/data"
+	devDir        = "/dev"
+
+	// where ESPs are usually mounted if their partition type can't be determined
+	wellKnownMounts = []string{"/boot/efi", "/efi", "/boot"}
+)
+
+type mountInfo struct {
+	Device     string // major:minor
+	MountPoint string
+	FSType     string
+}
+
+type partInfo struct {
+	Device   string // major:minor
+	TypeGUID string
+	UUID     string
+}
+
+func (p *partInfo) isESP() bool {
+	return p.TypeGUID == espTypeGUID || p.TypeGUID == espTypeMBR
+}
+
+// WithEfiSystemPartition finds the ESP via the mount table and partition type and runs fn on its mount path.
+// If the ESP is not mounted and mount is true, it is mounted read-only in a private mount namespace.
+func WithEfiSystemPartition(mount bool, root common.Root, fn func(path string) error) (string, error) {
+	mounts, err := readMountInfo(root.Path(procMountInfo))
+	if err != nil {
+		return "", err
+	}
+
+	var fallback *mountInfo
+	for i := range mounts {
+		m := &mounts[i]
+		if m.FSType != "vfat" {
+			continue
+		}
+
+		part, err := readPartInfo(m.Device, root)
+		if err != nil {
+			log.Debug().Err(err).Msgf("bootapps: can't get partition type of %s mounted at %s", m.Device, m.MountPoint)
+			if fallback == nil && contains(wellKnownMounts, m.MountPoint) {
+				fallback = m
+			}
+			continue
+		}
+
+		if part.isESP() {
+			log.Debug().Msgf("bootapps: using ESP %s mounted at %s", part.UUID, m.MountPoint)
+			return part.UUID, fn(root.Path(m.MountPoint))
+		}
+	}
+
+	if fallback != nil {
+		log.Debug().Msgf("bootapps: using vfat mount at %s as ESP", fallback.MountPoint)
+		return "", fn(root.Path(fallback.MountPoint))
+	}
+
+	part, dev, err := findUnmountedESP(root)
+	if err != nil {
+		return "", err
+	}
+	if !mount {
+		return "", fmt.Errorf("ESP %s is not mounted", part.UUID)
+	}
+
+	return part.UUID, mountPrivate(dev, fn)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// readMountInfo parses the mount table, see proc(5)
+func readMountInfo(file string) ([]mountInfo, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mountInfo
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		sep := -1
+		for i, v := range fields {
+			if v == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || len(fields) < sep+2 {
+			continue
+		}
+
+		mounts = append(mounts, mountInfo{
+			Device:     fields[2],
+			MountPoint: unescapeMountPath(fields[4]),
+			FSType:     fields[sep+1],
+		})
+	}
+
+	return mounts, sc.Err()
+}
+
+// unescapeMountPath decodes the octal escapes the kernel uses for whitespace and backslashes
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// readPartInfo gets partition type and UUID from the udev database or, if udev isn't running, the GPT itself
+func readPartInfo(device string, root common.Root) (*partInfo, error) {
+	part, err := readUdevPartInfo(device, root)
+	if err == nil {
+		return part, nil
+	}
+	log.Trace().Err(err).Msgf("bootapps: no udev data for %s", device)
+
+	return readGPTPartInfo(device, root)
+}
+
+func readUdevPartInfo(device string, root common.Root) (*partInfo, error) {
+	buf, err := os.ReadFile(root.Path(filepath.Join(udevData, "b"+device)))
+	if err != nil {
+		return nil, err
+	}
+
+	part := partInfo{Device: device}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if v, ok := strings.CutPrefix(line, "E:ID_PART_ENTRY_TYPE="); ok {
+			part.TypeGUID = strings.ToLower(v)
+		} else if v, ok := strings.CutPrefix(line, "E:ID_PART_ENTRY_UUID="); ok {
+			part.UUID = strings.ToLower(v)
+		}
+	}
+	if part.TypeGUID == "" {
+		return nil, errors.New("no partition entry type in udev data")
+	}
+
+	return &part, nil
+}
+
+func readGPTPartInfo(device string, root common.Root) (*partInfo, error) {
+	sysPath, err := filepath.EvalSymlinks(root.Path(filepath.Join(sysDevBlock, device)))
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := os.ReadFile(filepath.Join(sysPath, "partition"))
+	if err != nil {
+		return nil, err
+	}
+	partNo, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	// the parent directory of a partition in sysfs is the whole disk
+	disk := filepath.Base(filepath.Dir(sysPath))
+	sectorSize := uint64(512)
+	if buf, err := os.ReadFile(filepath.Join(filepath.Dir(sysPath), "queue", "logical_block_size")); err == nil {
+		if v, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 32); err == nil && v > 0 {
+			sectorSize = v
+		}
+	}
+
+	f, err := os.Open(filepath.Join(devDir, disk))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	part, err := readGPTEntry(f, sectorSize, uint32(partNo))
+	if err != nil {
+		return nil, err
+	}
+	part.Device = device
+
+	return part, nil
+}
+
+// readGPTEntry reads the 1-based partNo'th entry of the GUID partition table
+func readGPTEntry(r io.ReaderAt, sectorSize uint64, partNo uint32) (*partInfo, error) {
+	hdr := make([]byte, 92)
+	if _, err := r.ReadAt(hdr, int64(sectorSize)); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr[:8], []byte(gptHdrSig)) {
+		return nil, errors.New("no GPT header")
+	}
+
+	entriesLBA := binary.LittleEndian.Uint64(hdr[72:80])
+	numEntries := binary.LittleEndian.Uint32(hdr[80:84])
+	entrySize := binary.LittleEndian.Uint32(hdr[84:88])
+	if partNo == 0 || partNo > numEntries || entrySize < 128 {
+		return nil, fmt.Errorf("partition %d not in GPT", partNo)
+	}
+
+	entry := make([]byte, 32)
+	off := entriesLBA*sectorSize + uint64(partNo-1)*uint64(entrySize)
+	if _, err := r.ReadAt(entry, int64(off)); err != nil {
+		return nil, err
+	}
+
+	return &partInfo{
+		TypeGUID: mixedEndianGUID(entry[0:16]).String(),
+		UUID:     mixedEndianGUID(entry[16:32]).String(),
+	}, nil
+}
+
+// mixedEndianGUID converts an on-disk EFI_GUID to its canonical representation
+func mixedEndianGUID(b []byte) uuid.UUID {
+	var u uuid.UUID
+	copy(u[:], b)
+	u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+	u[4], u[5] = u[5], u[4]
+	u[6], u[7] = u[7], u[6]
+	return u
+}
+
+// findUnmountedESP looks at all partitions of all block devices for an ESP and returns it along with its device node
+func findUnmountedESP(root common.Root) (*partInfo, string, error) {
+	entries, err := os.ReadDir(root.Path(sysClassBlock))
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, e := range entries {
+		dir := root.Path(filepath.Join(sysClassBlock, e.Name()))
+		if _, err := os.Stat(filepath.Join(dir, "partition")); err != nil {
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(dir, "dev"))
+		if err != nil {
+			continue
+		}
+
+		part, err := readPartInfo(strings.TrimSpace(string(buf)), root)
+		if err != nil {
+			log.Trace().Err(err).Msgf("bootapps: can't get partition type of %s", e.Name())
+			continue
+		}
+		if part.isESP() {
+			return part, filepath.Join(devDir, e.Name()), nil
+		}
+	}
+
+	return nil, "", os.ErrNotExist
+}
+
+// mountPrivate mounts dev read-only inside a new mount namespace and runs fn on the mount path. The namespace
+// is bound to a locked OS thread that is never unlocked, so it is discarded together with the thread.
+func mountPrivate(dev string, fn func(path string) error) error {
+	done := make(chan error)
+
+	go func() {
+		runtime.LockOSThread()
+
+		done <- func() error {
+			if err := syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+				return fmt.Errorf("unshare mount namespace: %w", err)
+			}
+			// don't propagate our mounts to the host
+			if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+				return fmt.Errorf("make mounts private: %w", err)
+			}
+
+			dir, err := os.MkdirTemp("", "guard-esp")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(dir)
+
+			err = syscall.Mount(dev, dir, "vfat", syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+			if err != nil {
+				return fmt.Errorf("mount %s: %w", dev, err)
+			}
+			defer syscall.Unmount(dir, 0)
+
+			log.Debug().Msgf("bootapps: mounted ESP %s at %s in private namespace", dev, dir)
+			return fn(dir)
+		}()
+	}()
+
+	return <-done
 }
@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package bootapps
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMountInfo = `22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+25 22 0:5 / /dev rw,nosuid shared:2 - devtmpfs devtmpfs rw,size=8M
+41 22 259:1 / /boot/efi rw,relatime shared:30 - vfat /dev/nvme0n1p1 rw,fmask=0077
+42 22 8:17 / /media/usb\040stick rw,relatime shared:31 - vfat /dev/sdb1 rw
+`
+
+func TestReadMountInfo(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mountinfo")
+	assert.NoError(t, os.WriteFile(file, []byte(testMountInfo), 0644))
+
+	mounts, err := readMountInfo(file)
+	assert.NoError(t, err)
+	assert.Len(t, mounts, 4)
+	assert.Equal(t, mountInfo{Device: "259:1", MountPoint: "/boot/efi", FSType: "vfat"}, mounts[2])
+	assert.Equal(t, "/media/usb stick", mounts[3].MountPoint)
+}
+
+func TestReadUdevPartInfo(t *testing.T) {
+	old := udevData
+	t.Cleanup(func() { udevData = old })
+	udevData = t.TempDir()
+	data := "S:disk/by-partuuid/0f4b5d2a-1c7e-4a3b-9a51-2f0d3b6e7c11\n" +
+		"E:ID_PART_ENTRY_TYPE=C12A7328-F81F-11D2-BA4B-00A0C93EC93B\n" +
+		"E:ID_PART_ENTRY_UUID=0f4b5d2a-1c7e-4a3b-9a51-2f0d3b6e7c11\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(udevData, "b259:1"), []byte(data), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(udevData, "b259:2"), []byte("E:ID_FS_TYPE=ext4\n"), 0644))
+
+	part, err := readUdevPartInfo("259:1", "")
+	assert.NoError(t, err)
+	assert.True(t, part.isESP())
+	assert.Equal(t, "0f4b5d2a-1c7e-4a3b-9a51-2f0d3b6e7c11", part.UUID)
+
+	_, err = readUdevPartInfo("259:2", "")
+	assert.Error(t, err)
+}
+
+func TestReadGPTEntry(t *testing.T) {
+	const sector = 512
+	disk := make([]byte, 4*sector)
+
+	hdr := disk[sector:]
+	copy(hdr, gptHdrSig)
+	binary.LittleEndian.PutUint64(hdr[72:], 2)
+	binary.LittleEndian.PutUint32(hdr[80:], 4)
+	binary.LittleEndian.PutUint32(hdr[84:], 128)
+
+	// second entry, GUIDs in on-disk mixed-endian encoding
+	entry := disk[2*sector+128:]
+	copy(entry[0:], []byte{0x28, 0x73, 0x2a, 0xc1, 0x1f, 0xf8, 0xd2, 0x11, 0xba, 0x4b, 0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b})
+	copy(entry[16:], []byte{0x2a, 0x5d, 0x4b, 0x0f, 0x7e, 0x1c, 0x3b, 0x4a, 0x9a, 0x51, 0x2f, 0x0d, 0x3b, 0x6e, 0x7c, 0x11})
+
+	part, err := readGPTEntry(bytes.NewReader(disk), sector, 2)
+	assert.NoError(t, err)
+	assert.True(t, part.isESP())
+	assert.Equal(t, "0f4b5d2a-1c7e-4a3b-9a51-2f0d3b6e7c11", part.UUID)
+
+	part, err = readGPTEntry(bytes.NewReader(disk), sector, 1)
+	assert.NoError(t, err)
+	assert.False(t, part.isESP())
+
+	_, err = readGPTEntry(bytes.NewReader(disk), sector, 5)
+	assert.Error(t, err)
+
+	_, err = readGPTEntry(bytes.NewReader(make([]byte, 4*sector)), sector, 1)
+	assert.Error(t, err)
+}
@@ -5,8 +5,10 @@ package bootapps
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func getEfiSystemPartPath() (string, error) {
-	return "", errors.New("bootapps.getEfiSystemPartPath not implemented on " + runtime.GOOS)
+func WithEfiSystemPartition(mount bool, root common.Root, fn func(path string) error) (string, error) {
+	return "", errors.New("bootapps.WithEfiSystemPartition not implemented on " + runtime.GOOS)
 }
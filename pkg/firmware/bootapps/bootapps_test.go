@@ -0,0 +1,34 @@
+package bootapps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+func TestReportOfflineBootApps(t *testing.T) {
+	root := t.TempDir()
+
+	var missing api.BootApps
+	ReportOfflineBootApps(&missing, common.Root(root))
+	assert.Equal(t, api.NoResponse, missing.ImagesErr)
+
+	esp := filepath.Join(root, "boot/efi")
+	assert.NoError(t, os.MkdirAll(filepath.Join(esp, "EFI/BOOT"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(esp, "EFI/BOOT/BOOTX64.EFI"), testPE("shim", "", nil), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(esp, "EFI/BOOT/readme.txt"), []byte("not a PE file"), 0644))
+
+	var apps api.BootApps
+	ReportOfflineBootApps(&apps, common.Root(root))
+	assert.Empty(t, apps.ImagesErr)
+	assert.Empty(t, apps.PartitionUUID)
+	if assert.Len(t, apps.Images, 1) {
+		assert.Contains(t, apps.Images, "/EFI/BOOT/BOOTX64.EFI")
+		assert.Len(t, apps.PEImages["/EFI/BOOT/BOOTX64.EFI"].AuthenticodeSHA256, 32)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"unsafe"
 
 	"golang.org/x/sys/windows"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
 const (
@@ -20,6 +22,15 @@ type SYSTEM_BOOT_ENVIRONMENT_INFORMATION struct {
 	BootFlags      uint64
 }
 
+// WithEfiSystemPartition runs fn on the system partition. Windows doesn't expose its UUID via this path.
+func WithEfiSystemPartition(mount bool, root common.Root, fn func(path string) error) (string, error) {
+	path, err := getEfiSystemPartPath()
+	if err != nil {
+		return "", err
+	}
+	return "", fn(path)
+}
+
 func getEfiSystemPartPath() (string, error) {
 	// verify that this is a UEFI boot
 	var bootInfo SYSTEM_BOOT_ENVIRONMENT_INFORMATION
@@ -0,0 +1,217 @@
+package bootapps
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefivars"
+)
+
+const (
+	// offsets into the optional header
+	peChecksumOffset  = 64
+	peDataDirOffset32 = 96
+	peDataDirOffset64 = 112
+
+	peCertificateTable = 4 // data directory index
+
+	winCertHeaderSize     = 8
+	winCertTypePKCSSigned = 0x0002
+)
+
+// PKCS #7 ContentInfo wrapping SignedData, only the certificates are decoded
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     pkcs7SignedData `asn1:"explicit,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      asn1.RawValue
+}
+
+// peLayout is everything needed to compute the Authenticode digest of an image
+type peLayout struct {
+	File          *pe.File
+	SizeOfHeaders uint32
+	ChecksumOff   int
+	CertDirOff    int
+	CertTableOff  uint32
+	CertTableSize uint32
+}
+
+func parsePE(buf []byte) (*peLayout, error) {
+	f, err := pe.NewFile(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 0x40 {
+		return nil, errors.New("truncated DOS header")
+	}
+	// PE signature and COFF file header precede the optional header
+	optOff := int(binary.LittleEndian.Uint32(buf[0x3c:])) + 4 + 20
+
+	l := peLayout{File: f, ChecksumOff: optOff + peChecksumOffset}
+	var dirs []pe.DataDirectory
+	switch opt := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		l.SizeOfHeaders = opt.SizeOfHeaders
+		l.CertDirOff = optOff + peDataDirOffset32 + 8*peCertificateTable
+		dirs = dataDirectories(opt.DataDirectory[:], opt.NumberOfRvaAndSizes)
+	case *pe.OptionalHeader64:
+		l.SizeOfHeaders = opt.SizeOfHeaders
+		l.CertDirOff = optOff + peDataDirOffset64 + 8*peCertificateTable
+		dirs = dataDirectories(opt.DataDirectory[:], opt.NumberOfRvaAndSizes)
+	default:
+		return nil, errors.New("no optional header")
+	}
+	if len(dirs) > peCertificateTable {
+		l.CertTableOff, l.CertTableSize = dirs[peCertificateTable].VirtualAddress, dirs[peCertificateTable].Size
+	}
+	if l.ChecksumOff < 0 || l.CertDirOff+8 > int(l.SizeOfHeaders) || int(l.SizeOfHeaders) > len(buf) ||
+		uint64(l.CertTableOff)+uint64(l.CertTableSize) > uint64(len(buf)) {
+		return nil, errors.New("invalid PE header")
+	}
+
+	return &l, nil
+}
+
+// dataDirectories returns the directories present in the header. debug/pe accepts more than the 16 it decodes.
+func dataDirectories(dirs []pe.DataDirectory, n uint32) []pe.DataDirectory {
+	if uint64(n) < uint64(len(dirs)) {
+		return dirs[:n]
+	}
+	return dirs
+}
+
+// AuthenticodeDigest computes the hash of a PE image as defined by the Authenticode specification. This is the
+// value firmware compares against db and dbx and measures into PCR 4.
+func AuthenticodeDigest(buf []byte, alg crypto.Hash) ([]byte, error) {
+	l, err := parsePE(buf)
+	if err != nil {
+		return nil, err
+	}
+	return l.digest(buf, alg), nil
+}
+
+func (l *peLayout) digest(buf []byte, alg crypto.Hash) []byte {
+	h := alg.New()
+
+	// headers without the checksum and the certificate table entry
+	h.Write(buf[:l.ChecksumOff])
+	h.Write(buf[l.ChecksumOff+4 : l.CertDirOff])
+	h.Write(buf[l.CertDirOff+8 : l.SizeOfHeaders])
+
+	// sections in file order
+	sections := make([]*pe.Section, 0, len(l.File.Sections))
+	for _, s := range l.File.Sections {
+		if s.Size > 0 {
+			sections = append(sections, s)
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].Offset < sections[j].Offset })
+	hashed := uint64(l.SizeOfHeaders)
+	for _, s := range sections {
+		end := uint64(s.Offset) + uint64(s.Size)
+		if end > uint64(len(buf)) {
+			end = uint64(len(buf))
+		}
+		if uint64(s.Offset) < end {
+			h.Write(buf[s.Offset:end])
+			hashed += end - uint64(s.Offset)
+		}
+	}
+
+	// trailing data except the certificate table
+	end := uint64(len(buf)) - uint64(l.CertTableSize)
+	if hashed < end {
+		h.Write(buf[hashed:end])
+	}
+
+	return h.Sum(nil)
+}
+
+// sectionData returns the contents of the named section without the padding up to the file alignment
+func sectionData(f *pe.File, name string) ([]byte, bool) {
+	s := f.Section(name)
+	if s == nil {
+		return nil, false
+	}
+	data, err := s.Data()
+	if err != nil {
+		return nil, false
+	}
+	if s.VirtualSize > 0 && int(s.VirtualSize) < len(data) {
+		data = data[:s.VirtualSize]
+	}
+	return data, true
+}
+
+// certificates returns the certificates embedded in all Authenticode signatures of the image
+func (l *peLayout) certificates(buf []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	table := buf[l.CertTableOff : l.CertTableOff+l.CertTableSize]
+	for len(table) >= winCertHeaderSize {
+		// WIN_CERTIFICATE entries are 8 byte aligned
+		length := int(binary.LittleEndian.Uint32(table))
+		typ := binary.LittleEndian.Uint16(table[6:])
+		if length < winCertHeaderSize || length > len(table) {
+			return nil, errors.New("invalid WIN_CERTIFICATE")
+		}
+		if typ == winCertTypePKCSSigned {
+			var ci pkcs7ContentInfo
+			if _, err := asn1.Unmarshal(table[winCertHeaderSize:length], &ci); err != nil {
+				return nil, err
+			}
+			c, err := x509.ParseCertificates(ci.Content.Certificates.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, c...)
+		}
+		if aligned := (length + 7) &^ 7; aligned < len(table) {
+			table = table[aligned:]
+		} else {
+			break
+		}
+	}
+	return certs, nil
+}
+
+// reportPEImage computes the Authenticode digests of an image and extracts the certificates of its signatures
+func reportPEImage(buf []byte) api.PEImage {
+	var img api.PEImage
+	l, err := parsePE(buf)
+	if err != nil {
+		img.Error = common.ServeApiError(common.MapFSErrors(err))
+		return img
+	}
+
+	img.AuthenticodeSHA1 = l.digest(buf, crypto.SHA1)
+	img.AuthenticodeSHA256 = l.digest(buf, crypto.SHA256)
+	img.AuthenticodeSHA384 = l.digest(buf, crypto.SHA384)
+
+	certs, err := l.certificates(buf)
+	if err != nil {
+		// keep the digests, the signature is checked by the firmware anyway
+		img.Error = common.ServeApiError(common.MapFSErrors(err))
+	}
+	for _, cert := range certs {
+		img.Certificates = append(img.Certificates, uefivars.CertificateSignature(cert))
+	}
+	return img
+}
@@ -0,0 +1,238 @@
+package bootapps
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	mathrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefivars"
+)
+
+const (
+	testPEOffset     = 0x40
+	testOptOffset    = testPEOffset + 4 + 20
+	testSectionTable = testOptOffset + 240
+	testFileAlign    = 0x200
+)
+
+// testPE builds a minimal PE32+ image with a .text and a .sbat section followed by an optional certificate table
+func testPE(text, sbat string, certTable []byte) []byte {
+	le := binary.LittleEndian
+	buf := make([]byte, 3*testFileAlign)
+	copy(buf, "MZ")
+	le.PutUint32(buf[0x3c:], testPEOffset)
+	copy(buf[testPEOffset:], "PE\x00\x00")
+
+	coff := buf[testPEOffset+4:]
+	le.PutUint16(coff[0:], 0x8664) // machine
+	le.PutUint16(coff[2:], 2)      // number of sections
+	le.PutUint16(coff[16:], 240)   // size of optional header
+	le.PutUint16(coff[18:], 0x22)  // characteristics
+
+	opt := buf[testOptOffset:]
+	le.PutUint16(opt[0:], 0x20b)
+	le.PutUint32(opt[32:], 0x1000)        // section alignment
+	le.PutUint32(opt[36:], testFileAlign) // file alignment
+	le.PutUint32(opt[56:], 0x3000)        // size of image
+	le.PutUint32(opt[60:], testFileAlign) // size of headers
+	le.PutUint32(opt[64:], 0xdeadbeef)    // checksum
+	le.PutUint16(opt[68:], 10)            // EFI application
+	le.PutUint32(opt[108:], 16)           // number of data directories
+
+	for i, s := range []struct {
+		name string
+		data string
+	}{{".text", text}, {".sbat", sbat}} {
+		hdr := buf[testSectionTable+40*i:]
+		copy(hdr, s.name)
+		le.PutUint32(hdr[8:], uint32(len(s.data)))
+		le.PutUint32(hdr[12:], uint32(0x1000*(i+1)))
+		le.PutUint32(hdr[16:], testFileAlign)
+		le.PutUint32(hdr[20:], uint32(testFileAlign*(i+1)))
+		copy(buf[testFileAlign*(i+1):], s.data)
+	}
+
+	if certTable != nil {
+		le.PutUint32(opt[112+8*peCertificateTable:], uint32(len(buf)))
+		le.PutUint32(opt[112+8*peCertificateTable+4:], uint32(len(certTable)))
+		buf = append(buf, certTable...)
+	}
+	return buf
+}
+
+func TestAuthenticodeDigest(t *testing.T) {
+	unsigned := testPE("code", "sbat,1,SBAT Version,sbat,1,https://github.com/rhboot/shim/blob/main/SBAT.md\n", nil)
+	digest, err := AuthenticodeDigest(unsigned, crypto.SHA256)
+	assert.NoError(t, err)
+	assert.Len(t, digest, 32)
+
+	// signing changes the checksum, the certificate table entry and appends the signature
+	signed := testPE("code", "sbat,1,SBAT Version,sbat,1,https://github.com/rhboot/shim/blob/main/SBAT.md\n", bytes.Repeat([]byte{0x30}, 64))
+	binary.LittleEndian.PutUint32(signed[testOptOffset+peChecksumOffset:], 0x12345678)
+	signedDigest, err := AuthenticodeDigest(signed, crypto.SHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, signedDigest)
+
+	modified := testPE("c0de", "", nil)
+	modifiedDigest, err := AuthenticodeDigest(modified, crypto.SHA256)
+	assert.NoError(t, err)
+	assert.NotEqual(t, digest, modifiedDigest)
+
+	_, err = AuthenticodeDigest([]byte("MZ not a PE file"), crypto.SHA256)
+	assert.Error(t, err)
+}
+
+func TestSectionData(t *testing.T) {
+	l, err := parsePE(testPE("code", "sbat,1\n", nil))
+	assert.NoError(t, err)
+	data, ok := sectionData(l.File, ".sbat")
+	assert.True(t, ok)
+	assert.Equal(t, "sbat,1\n", string(data))
+	_, ok = sectionData(l.File, ".uname")
+	assert.False(t, ok)
+}
+
+// testCertTable wraps a certificate in a PKCS #7 SignedData without signer infos inside a WIN_CERTIFICATE
+func testCertTable(t *testing.T, der []byte) []byte {
+	type signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}
+	type contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     signedData `asn1:"explicit,tag:0"`
+	}
+	set := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	spcIndirectData, err := asn1.Marshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}})
+	assert.NoError(t, err)
+	pkcs7, err := asn1.Marshal(contentInfo{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content: signedData{
+			Version:          1,
+			DigestAlgorithms: set,
+			ContentInfo:      asn1.RawValue{FullBytes: spcIndirectData},
+			Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der},
+			SignerInfos:      set,
+		},
+	})
+	assert.NoError(t, err)
+
+	entry := make([]byte, winCertHeaderSize, winCertHeaderSize+len(pkcs7)+8)
+	binary.LittleEndian.PutUint32(entry, uint32(winCertHeaderSize+len(pkcs7)))
+	binary.LittleEndian.PutUint16(entry[4:], 0x0200) // WIN_CERT_REVISION_2_0
+	binary.LittleEndian.PutUint16(entry[6:], winCertTypePKCSSigned)
+	entry = append(entry, pkcs7...)
+	for len(entry)%8 != 0 {
+		entry = append(entry, 0)
+	}
+	return entry
+}
+
+func testCertificate(t *testing.T) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Secure Boot Signing"},
+		NotBefore:    time.Unix(1700000000, 0),
+		NotAfter:     time.Unix(1900000000, 0),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert
+}
+
+func TestReportPEImage(t *testing.T) {
+	cert := testCertificate(t)
+	signed := testPE("code", "", testCertTable(t, cert.Raw))
+
+	img := reportPEImage(signed)
+	assert.Empty(t, img.Error)
+	assert.Len(t, img.AuthenticodeSHA1, 20)
+	assert.Len(t, img.AuthenticodeSHA256, 32)
+	assert.Len(t, img.AuthenticodeSHA384, 48)
+	digest, err := AuthenticodeDigest(testPE("code", "", nil), crypto.SHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, digest, []byte(img.AuthenticodeSHA256))
+	if assert.Len(t, img.Certificates, 1) {
+		assert.Equal(t, uefivars.CertificateSignature(cert), img.Certificates[0])
+	}
+
+	unsigned := reportPEImage(testPE("code", "", nil))
+	assert.Empty(t, unsigned.Error)
+	assert.Empty(t, unsigned.Certificates)
+
+	// digests are kept if the signature is garbage
+	broken := reportPEImage(testPE("code", "", []byte{16, 0, 0, 0, 0, 2, 2, 0, 0x30, 0x80, 0, 0, 0, 0, 0, 0}))
+	assert.NotEmpty(t, broken.Error)
+	assert.Equal(t, digest, []byte(broken.AuthenticodeSHA256))
+
+	invalid := reportPEImage([]byte("MZ not a PE file"))
+	assert.NotEmpty(t, invalid.Error)
+	assert.Empty(t, invalid.AuthenticodeSHA256)
+}
+
+func TestParsePEExtraDataDirectories(t *testing.T) {
+	// 17 data directories, debug/pe only decodes 16 of them
+	buf := testPE("code", "", nil)
+	binary.LittleEndian.PutUint16(buf[testPEOffset+4+16:], 240+8)
+	binary.LittleEndian.PutUint32(buf[testOptOffset+108:], 17)
+	buf = append(buf[:testSectionTable], append(make([]byte, 8), buf[testSectionTable:testFileAlign-8]...)...)
+	buf = append(buf, testPE("code", "", nil)[testFileAlign:]...)
+
+	_, err := parsePE(buf)
+	assert.NoError(t, err)
+	img := reportPEImage(buf)
+	assert.Empty(t, img.Error)
+	assert.Len(t, img.AuthenticodeSHA256, 32)
+}
+
+func TestReportPEImageMalformed(t *testing.T) {
+	valid := testPE("code", "sbat,1\n", testCertTable(t, testCertificate(t).Raw))
+	rng := mathrand.New(mathrand.NewSource(1))
+
+	inputs := [][]byte{nil, []byte("MZ"), bytes.Repeat([]byte{0xff}, 0x200)}
+	for n := 0; n < len(valid); n += 7 {
+		inputs = append(inputs, valid[:n])
+	}
+	for i := 0; i < 2000; i++ {
+		buf := append([]byte(nil), valid...)
+		for j := 0; j < 4; j++ {
+			buf[rng.Intn(testFileAlign)] = byte(rng.Intn(256))
+		}
+		inputs = append(inputs, buf)
+	}
+	// header fields the parser trusts
+	for _, off := range []int{0x3c, testPEOffset + 4 + 16, testOptOffset + 60, testOptOffset + 108, testOptOffset + 112 + 8*peCertificateTable} {
+		for _, v := range []uint32{0, 1, 17, 0x7fffffff, 0xffffffff} {
+			buf := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint32(buf[off:], v)
+			inputs = append(inputs, buf)
+		}
+	}
+
+	for _, buf := range inputs {
+		assert.NotPanics(t, func() {
+			reportPEImage(buf)
+			CheckRevocations(map[string]api.HashBlob{"/a.efi": {Data: buf}}, &RevocationLists{})
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package bootapps
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefivars"
+)
+
+// Revocation is a boot application that is refused by or vulnerable despite the local Secure Boot configuration
+type Revocation struct {
+	Path    string
+	Reason  string
+	Revoked bool // false if the image is only revoked by newer dbx or SBAT updates than the installed ones
+}
+
+// RevocationLists are the revocations to check boot applications against
+type RevocationLists struct {
+	Dbx       []api.EFISignature // dbx and shim's MokListX
+	DbxUpdate []api.EFISignature // newest dbx update available, optional
+	SbatLevel string             // SbatLevel variable
+}
+
+// CheckRevocations compares the Authenticode digest and the .sbat section of every PE image against dbx and the
+// SBAT revocations, both the ones installed on the machine and the newest ones known
+func CheckRevocations(images map[string]api.HashBlob, lists *RevocationLists) []Revocation {
+	dbx := fingerprints(lists.Dbx, api.EFIFingerprint)
+	dbxCerts := fingerprints(lists.Dbx, api.EFICertificate)
+	dbxUpdate := fingerprints(lists.DbxUpdate, api.EFIFingerprint)
+	_, installed := ParseSbatLevel(lists.SbatLevel)
+	_, latest := ParseSbatLevel(LatestSbatLevel)
+
+	paths := make([]string, 0, len(images))
+	for path := range images {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	revocations := []Revocation{}
+	for _, path := range paths {
+		buf := images[path].Data
+		l, err := parsePE(buf)
+		if err != nil {
+			continue
+		}
+
+		digest := hex.EncodeToString(l.digest(buf, crypto.SHA256))
+		switch {
+		case dbx[digest]:
+			revocations = append(revocations, Revocation{Path: path, Reason: "Authenticode hash in dbx", Revoked: true})
+			continue
+		case revokedSigner(l, buf, dbxCerts):
+			revocations = append(revocations, Revocation{Path: path, Reason: "signing certificate in dbx", Revoked: true})
+			continue
+		case dbxUpdate[digest]:
+			revocations = append(revocations, Revocation{Path: path, Reason: "Authenticode hash in dbx update but not installed"})
+			continue
+		}
+
+		data, ok := sectionData(l.File, ".sbat")
+		if !ok {
+			continue
+		}
+		entries := ParseSbatSection(data)
+		if e, ok := revokedBySbat(entries, installed); ok {
+			reason := fmt.Sprintf("SBAT generation %d of %s revoked by SbatLevel", e.Generation, e.Component)
+			revocations = append(revocations, Revocation{Path: path, Reason: reason, Revoked: true})
+		} else if e, ok := revokedBySbat(entries, latest); ok {
+			reason := fmt.Sprintf("SBAT generation %d of %s vulnerable, SbatLevel not updated yet", e.Generation, e.Component)
+			revocations = append(revocations, Revocation{Path: path, Reason: reason})
+		}
+	}
+
+	return revocations
+}
+
+func fingerprints(sigs []api.EFISignature, typ string) map[string]bool {
+	set := make(map[string]bool, len(sigs))
+	for _, sig := range sigs {
+		if sig.Type == typ {
+			set[sig.Fingerprint] = true
+		}
+	}
+	return set
+}
+
+func revokedSigner(l *peLayout, buf []byte, dbxCerts map[string]bool) bool {
+	if len(dbxCerts) == 0 {
+		return false
+	}
+	certs, _ := l.certificates(buf)
+	for _, cert := range certs {
+		if dbxCerts[uefivars.CertificateSignature(cert).Fingerprint] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package bootapps
+
+import (
+	"crypto"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefivars"
+)
+
+const (
+	sbatHeader = "sbat,1,SBAT Version,sbat,1,https://github.com/rhboot/shim/blob/main/SBAT.md\n"
+	grub2      = sbatHeader + "grub,2,Free Software Foundation,grub,2.06,https://www.gnu.org/software/grub/\n"
+	grub3      = sbatHeader + "grub,3,Free Software Foundation,grub,2.06,https://www.gnu.org/software/grub/\n" +
+		"grub.debian,4,Debian,grub2,2.06-13,https://tracker.debian.org/pkg/grub2\n"
+	shim3 = sbatHeader + "shim,3,UEFI shim,shim,1,https://github.com/rhboot/shim\n"
+)
+
+func TestParseSbatLevel(t *testing.T) {
+	date, level := ParseSbatLevel(LatestSbatLevel)
+	assert.Equal(t, "2024010900", date)
+	assert.Equal(t, map[string]int{"shim": 4, "grub": 3, "grub.debian": 4}, level)
+
+	date, level = ParseSbatLevel("")
+	assert.Empty(t, date)
+	assert.Empty(t, level)
+}
+
+func TestParseSbatSection(t *testing.T) {
+	entries := ParseSbatSection([]byte(grub3 + "broken\nfoo,x\n\x00\x00"))
+	assert.Len(t, entries, 3)
+	assert.Equal(t, SbatEntry{Component: "grub.debian", Generation: 4, Vendor: "Debian", Package: "grub2", Version: "2.06-13"}, entries[2])
+}
+
+func TestCheckRevocations(t *testing.T) {
+	hashRevoked := testPE("revoked by hash", "", nil)
+	hashUpdate := testPE("revoked by the dbx update", "", nil)
+	cert := testCertificate(t)
+	certRevoked := testPE("signed by a revoked key", "", testCertTable(t, cert.Raw))
+	digest := func(buf []byte) string {
+		d, err := AuthenticodeDigest(buf, crypto.SHA256)
+		assert.NoError(t, err)
+		return hex.EncodeToString(d)
+	}
+
+	images := map[string]api.HashBlob{
+		"/EFI/old/grubx64.efi":    {Data: testPE("grub", grub2, nil)},
+		"/EFI/debian/grubx64.efi": {Data: testPE("grub", grub3, nil)},
+		"/EFI/debian/shimx64.efi": {Data: testPE("shim", shim3, nil)},
+		"/EFI/vendor/a.efi":       {Data: hashRevoked},
+		"/EFI/vendor/b.efi":       {Data: hashUpdate},
+		"/EFI/vendor/c.efi":       {Data: testPE("fine", "", nil)},
+		"/EFI/vendor/d.efi":       {Data: certRevoked},
+		"/EFI/vendor/readme.txt":  {Data: []byte("not a PE file")},
+	}
+	lists := RevocationLists{
+		Dbx: []api.EFISignature{
+			{Type: api.EFIFingerprint, Fingerprint: digest(hashRevoked)},
+			uefivars.CertificateSignature(cert),
+		},
+		DbxUpdate: []api.EFISignature{{Type: api.EFIFingerprint, Fingerprint: digest(hashUpdate)}},
+		SbatLevel: "sbat,1,2022111500\nshim,2\ngrub,3\n",
+	}
+
+	revocations := CheckRevocations(images, &lists)
+	assert.Equal(t, []Revocation{
+		{Path: "/EFI/debian/shimx64.efi", Reason: "SBAT generation 3 of shim vulnerable, SbatLevel not updated yet"},
+		{Path: "/EFI/old/grubx64.efi", Reason: "SBAT generation 2 of grub revoked by SbatLevel", Revoked: true},
+		{Path: "/EFI/vendor/a.efi", Reason: "Authenticode hash in dbx", Revoked: true},
+		{Path: "/EFI/vendor/b.efi", Reason: "Authenticode hash in dbx update but not installed"},
+		{Path: "/EFI/vendor/d.efi", Reason: "signing certificate in dbx", Revoked: true},
+	}, revocations)
+}
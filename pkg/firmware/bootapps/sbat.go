@@ -0,0 +1,86 @@
+package bootapps
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SBAT_VAR_LATEST of shim 15.8, the newest revocations known to this agent
+const LatestSbatLevel = "sbat,1,2024010900\nshim,4\ngrub,3\ngrub.debian,4\n"
+
+// SbatEntry is one component listed in the .sbat section of a boot loader
+type SbatEntry struct {
+	Component  string
+	Generation int
+	Vendor     string
+	Package    string
+	Version    string
+}
+
+// parseSbatCSV splits the CSV used by both .sbat sections and the SbatLevel variable into records
+func parseSbatCSV(data string) [][]string {
+	var records [][]string
+	for _, line := range strings.Split(strings.TrimRight(data, "\x00"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		records = append(records, strings.Split(line, ","))
+	}
+	return records
+}
+
+// ParseSbatSection decodes the .sbat section of a PE image. Records with an invalid generation are skipped.
+func ParseSbatSection(data []byte) []SbatEntry {
+	var entries []SbatEntry
+	for _, rec := range parseSbatCSV(string(data)) {
+		if len(rec) < 2 {
+			continue
+		}
+		gen, err := strconv.Atoi(rec[1])
+		if err != nil {
+			continue
+		}
+		e := SbatEntry{Component: rec[0], Generation: gen}
+		if len(rec) > 4 {
+			e.Vendor, e.Package, e.Version = rec[2], rec[3], rec[4]
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// ParseSbatLevel decodes the SbatLevel variable into the minimum generation of each component. The first record
+// holds the SBAT version and the date of the revocations.
+func ParseSbatLevel(level string) (string, map[string]int) {
+	var date string
+	min := make(map[string]int)
+	for i, rec := range parseSbatCSV(level) {
+		if i == 0 && rec[0] == "sbat" {
+			if len(rec) > 2 {
+				date = rec[2]
+			}
+			continue
+		}
+		if len(rec) < 2 {
+			continue
+		}
+		if gen, err := strconv.Atoi(rec[1]); err == nil {
+			min[rec[0]] = gen
+		}
+	}
+	return date, min
+}
+
+// revokedBySbat returns the first component of entries whose generation is older than the one required by level
+func revokedBySbat(entries []SbatEntry, level map[string]int) (SbatEntry, bool) {
+	for _, e := range entries {
+		if e.Component == "sbat" {
+			continue
+		}
+		if min, ok := level[e.Component]; ok && e.Generation < min {
+			return e, true
+		}
+	}
+	return SbatEntry{}, false
+}
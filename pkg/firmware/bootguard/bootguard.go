@@ -0,0 +1,185 @@
+package bootguard
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/msr"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/pci"
+)
+
+const (
+	MSR_PLATFORM_INFO          = 0xce
+	MSR_PLAT_FRMW_PROT_CTRL    = 0x110
+	MSR_BOOT_GUARD_SACM_INFO   = 0x13a
+	sacmInfoNEMEnabled         = 1 << 0
+	sacmInfoTPMSuccess         = 1 << 3
+	sacmInfoForceAnchorBoot    = 1 << 4
+	sacmInfoMeasuredBoot       = 1 << 5
+	sacmInfoVerifiedBoot       = 1 << 6
+	sacmInfoModuleRevoked      = 1 << 7
+	sacmInfoBootGuardCapable   = 1 << 32
+	platformInfoBIOSGuardAvail = 1 << 35
+	platFrmwProtCtrlLocked     = 1 << 0
+	platFrmwProtCtrlEnabled    = 1 << 1
+
+	// HECI1 is always at 00:16.0
+	heciBus      = 0
+	heciDevice   = 0x16
+	heciFunction = 0
+	intelVendor  = 0x8086
+
+	hfsts1ManufacturingMode = 1 << 4
+	hfsts6ForceACM          = 1 << 0
+	hfsts6MeasuredBoot      = 1 << 8
+	hfsts6VerifiedBoot      = 1 << 9
+	hfsts6PolicyStatus      = 1 << 26
+	hfsts6Error             = 1 << 27
+	hfsts6BootGuardDisable  = 1 << 28
+	hfsts6FPFSoCLock        = 1 << 30
+)
+
+// config space offsets of HFSTS1-6
+var hfstsOffsets = []int{0x40, 0x48, 0x60, 0x64, 0x68, 0x6c}
+
+var (
+	// replaced in tests
+	readMSR = func(reg uint32) (uint64, error) {
+		msrs := []api.MSR{{MSR: reg}}
+		if err := msr.ReportMSRs(msrs); err != nil {
+			return 0, err
+		}
+		if len(msrs[0].Values) == 0 {
+			return 0, common.ErrorNoResponse(errors.New("no MSR values"))
+		}
+		return msrs[0].Values[0], nil
+	}
+	readHECIConfig = func(root common.Root) ([]byte, error) {
+		spaces := []api.PCIConfigSpace{{Bus: heciBus, Device: heciDevice, Function: heciFunction}}
+		if err := pci.ReportConfigSpaces(spaces, root); err != nil {
+			return nil, err
+		}
+		return spaces[0].Value, nil
+	}
+)
+
+// ReportBootGuard decodes the Intel Boot Guard policy provisioned into the fuses, the outcome of the last boot and
+// whether BIOS Guard protects the flash
+func ReportBootGuard(bg *api.BootGuard, root common.Root) error {
+	log.Trace().Msg("ReportBootGuard()")
+
+	sacm, sacmErr := readMSR(MSR_BOOT_GUARD_SACM_INFO)
+	if sacmErr != nil {
+		bg.SACMInfoErr = common.ServeApiError(common.MapFSErrors(sacmErr))
+		log.Debug().Err(sacmErr).Msg("bootguard.ReportBootGuard(): BOOT_GUARD_SACM_INFO")
+	} else {
+		bg.SACMInfo = &sacm
+		bg.Capable = sacm&sacmInfoBootGuardCapable != 0
+		bg.ACMExecuted = sacm&sacmInfoNEMEnabled != 0
+		bg.VerifiedBoot = sacm&sacmInfoVerifiedBoot != 0
+		bg.MeasuredBoot = sacm&sacmInfoMeasuredBoot != 0
+		bg.ACMRevoked = sacm&sacmInfoModuleRevoked != 0
+		bg.TPMSuccess = sacm&sacmInfoTPMSuccess != 0
+	}
+
+	hfsts, hfstsErr := readHFSTS(root)
+	if hfstsErr != nil {
+		bg.HFSTSErr = common.ServeApiError(common.MapFSErrors(hfstsErr))
+		log.Debug().Err(hfstsErr).Msg("bootguard.ReportBootGuard(): HFSTS")
+	} else {
+		bg.HFSTS = hfsts
+		decodeHFSTS(bg, hfsts)
+	}
+
+	if sacmErr != nil && hfstsErr != nil {
+		bg.Error = bg.SACMInfoErr
+		log.Warn().Msg("Failed to read Intel Boot Guard status")
+		return sacmErr
+	}
+
+	// BIOS Guard is independent of the ME, a missing capability is not an error
+	info, err := readMSR(MSR_PLATFORM_INFO)
+	if err == nil && info&platformInfoBIOSGuardAvail != 0 {
+		bg.BIOSGuardCapable = true
+		err = reportBIOSGuard(bg)
+	}
+	if err != nil {
+		bg.BIOSGuardErr = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("bootguard.ReportBootGuard(): BIOS Guard")
+	}
+
+	return nil
+}
+
+func reportBIOSGuard(bg *api.BootGuard) error {
+	ctrl, err := readMSR(MSR_PLAT_FRMW_PROT_CTRL)
+	if err != nil {
+		return err
+	}
+	bg.BIOSGuardEnabled = ctrl&platFrmwProtCtrlEnabled != 0
+	bg.BIOSGuardLocked = ctrl&platFrmwProtCtrlLocked != 0
+	return nil
+}
+
+func readHFSTS(root common.Root) ([]uint32, error) {
+	cfg, err := readHECIConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg) < hfstsOffsets[len(hfstsOffsets)-1]+4 {
+		return nil, common.ErrorNoResponse(errors.New("short HECI config space"))
+	}
+	// missing or hidden by firmware
+	if binary.LittleEndian.Uint16(cfg[0:]) != intelVendor {
+		return nil, common.ErrorNoResponse(errors.New("no HECI device"))
+	}
+
+	hfsts := make([]uint32, len(hfstsOffsets))
+	for i, off := range hfstsOffsets {
+		hfsts[i] = binary.LittleEndian.Uint32(cfg[off:])
+	}
+	return hfsts, nil
+}
+
+func decodeHFSTS(bg *api.BootGuard, hfsts []uint32) {
+	hfsts1, hfsts6 := hfsts[0], hfsts[5]
+
+	bg.ManufacturingMode = hfsts1&hfsts1ManufacturingMode != 0
+	bg.ForceACM = hfsts6&hfsts6ForceACM != 0
+	bg.VerifiedPolicy = hfsts6&hfsts6VerifiedBoot != 0
+	bg.MeasuredPolicy = hfsts6&hfsts6MeasuredBoot != 0
+	bg.EnforcementPolicy = uint(hfsts6>>6) & 0x3
+	bg.ACMSVN = uint(hfsts6>>10) & 0xf
+	bg.KMSVN = uint(hfsts6>>14) & 0xf
+	bg.BPMSVN = uint(hfsts6>>18) & 0xf
+	bg.KMID = uint(hfsts6>>22) & 0xf
+	bg.PolicyValid = hfsts6&hfsts6PolicyStatus != 0
+	bg.BootGuardError = hfsts6&hfsts6Error != 0
+	bg.BootGuardDisable = hfsts6&hfsts6BootGuardDisable != 0
+	bg.FPFLocked = hfsts6&hfsts6FPFSoCLock != 0
+	bg.Profile = profile(bg)
+	// the ME knows about Boot Guard even if the MSR is not readable
+	bg.Capable = bg.Capable || bg.ForceACM || bg.VerifiedPolicy || bg.MeasuredPolicy
+}
+
+// profile maps the fused policy to the profiles of the Boot Guard reference documentation
+func profile(bg *api.BootGuard) string {
+	const immediateShutdown = 3
+
+	switch {
+	case !bg.ForceACM && !bg.VerifiedPolicy && !bg.MeasuredPolicy:
+		return "0"
+	case !bg.ForceACM && bg.VerifiedPolicy && bg.MeasuredPolicy:
+		return "3"
+	case bg.ForceACM && bg.VerifiedPolicy && !bg.MeasuredPolicy && bg.EnforcementPolicy == immediateShutdown:
+		return "4"
+	case bg.ForceACM && bg.VerifiedPolicy && bg.MeasuredPolicy && bg.EnforcementPolicy == immediateShutdown:
+		return "5"
+	default:
+		return "custom"
+	}
+}
@@ -0,0 +1,135 @@
+package bootguard
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+type fakePlatform struct {
+	msrs  map[uint32]uint64
+	hfsts []uint32 // nil if the HECI device is hidden
+}
+
+func (f *fakePlatform) install(t *testing.T) {
+	savedMSR, savedHECI := readMSR, readHECIConfig
+	t.Cleanup(func() { readMSR, readHECIConfig = savedMSR, savedHECI })
+
+	readMSR = func(reg uint32) (uint64, error) {
+		if v, ok := f.msrs[reg]; ok {
+			return v, nil
+		}
+		return 0, errors.New("no such MSR")
+	}
+	readHECIConfig = func(common.Root) ([]byte, error) {
+		cfg := make([]byte, 256)
+		if f.hfsts == nil {
+			binary.LittleEndian.PutUint16(cfg[0:], 0xffff)
+			return cfg, nil
+		}
+		binary.LittleEndian.PutUint16(cfg[0:], intelVendor)
+		for i, off := range hfstsOffsets {
+			binary.LittleEndian.PutUint32(cfg[off:], f.hfsts[i])
+		}
+		return cfg, nil
+	}
+}
+
+// FVME profile with immediate shutdown, fuses locked, ACM SVN 2, KM SVN 1, BIOS Guard enabled
+var fvme = fakePlatform{
+	msrs: map[uint32]uint64{
+		MSR_BOOT_GUARD_SACM_INFO: sacmInfoBootGuardCapable | sacmInfoNEMEnabled | sacmInfoTPMSuccess |
+			sacmInfoForceAnchorBoot | sacmInfoMeasuredBoot | sacmInfoVerifiedBoot | 0x4,
+		MSR_PLATFORM_INFO:       platformInfoBIOSGuardAvail,
+		MSR_PLAT_FRMW_PROT_CTRL: platFrmwProtCtrlEnabled | platFrmwProtCtrlLocked,
+	},
+	hfsts: []uint32{0x90000245, 0, 0, 0, 0,
+		hfsts6ForceACM | 3<<6 | hfsts6MeasuredBoot | hfsts6VerifiedBoot | 2<<10 | 1<<14 | hfsts6PolicyStatus | hfsts6FPFSoCLock},
+}
+
+func TestReportBootGuard(t *testing.T) {
+	fvme.install(t)
+
+	var bg api.BootGuard
+	assert.NoError(t, ReportBootGuard(&bg, ""))
+	assert.Equal(t, api.NoError, bg.Error)
+	assert.Len(t, bg.HFSTS, 6)
+	assert.Equal(t, "5", bg.Profile)
+	assert.True(t, bg.Capable)
+	assert.True(t, bg.ForceACM)
+	assert.Equal(t, uint(3), bg.EnforcementPolicy)
+	assert.Equal(t, uint(2), bg.ACMSVN)
+	assert.Equal(t, uint(1), bg.KMSVN)
+	assert.True(t, bg.FPFLocked)
+	assert.False(t, bg.ManufacturingMode)
+	assert.True(t, bg.ACMExecuted)
+	assert.True(t, bg.VerifiedBoot)
+	assert.True(t, bg.MeasuredBoot)
+	assert.True(t, bg.TPMSuccess)
+	assert.True(t, bg.PolicyValid)
+	assert.False(t, bg.BootGuardError)
+	assert.True(t, bg.BIOSGuardCapable)
+	assert.True(t, bg.BIOSGuardEnabled)
+	assert.True(t, bg.BIOSGuardLocked)
+}
+
+func TestReportBootGuardUnprovisioned(t *testing.T) {
+	plat := fakePlatform{
+		msrs:  map[uint32]uint64{MSR_BOOT_GUARD_SACM_INFO: 0, MSR_PLATFORM_INFO: 0},
+		hfsts: []uint32{hfsts1ManufacturingMode, 0, 0, 0, 0, 0},
+	}
+	plat.install(t)
+
+	var bg api.BootGuard
+	assert.NoError(t, ReportBootGuard(&bg, ""))
+	assert.Equal(t, "0", bg.Profile)
+	assert.False(t, bg.Capable)
+	assert.False(t, bg.FPFLocked)
+	assert.True(t, bg.ManufacturingMode)
+	assert.False(t, bg.BIOSGuardCapable)
+	assert.Equal(t, api.NoError, bg.BIOSGuardErr)
+}
+
+func TestReportBootGuardNoMSRAccess(t *testing.T) {
+	plat := fakePlatform{hfsts: fvme.hfsts}
+	plat.install(t)
+
+	var bg api.BootGuard
+	assert.NoError(t, ReportBootGuard(&bg, ""))
+	assert.Nil(t, bg.SACMInfo)
+	assert.Equal(t, api.UnknownError, bg.SACMInfoErr)
+	assert.Equal(t, "5", bg.Profile)
+	assert.True(t, bg.Capable)
+	assert.False(t, bg.VerifiedBoot)
+}
+
+func TestReportBootGuardNoAccess(t *testing.T) {
+	plat := fakePlatform{}
+	plat.install(t)
+
+	var bg api.BootGuard
+	assert.Error(t, ReportBootGuard(&bg, ""))
+	assert.Equal(t, api.NoResponse, bg.HFSTSErr)
+	assert.NotEqual(t, api.NoError, bg.Error)
+	assert.Empty(t, bg.Profile)
+}
+
+func TestProfile(t *testing.T) {
+	for _, tc := range []struct {
+		bg      api.BootGuard
+		profile string
+	}{
+		{api.BootGuard{}, "0"},
+		{api.BootGuard{VerifiedPolicy: true, MeasuredPolicy: true}, "3"},
+		{api.BootGuard{ForceACM: true, VerifiedPolicy: true, EnforcementPolicy: 3}, "4"},
+		{api.BootGuard{ForceACM: true, VerifiedPolicy: true, MeasuredPolicy: true, EnforcementPolicy: 3}, "5"},
+		{api.BootGuard{ForceACM: true, VerifiedPolicy: true, EnforcementPolicy: 1}, "custom"},
+	} {
+		assert.Equal(t, tc.profile, profile(&tc.bg))
+	}
+}
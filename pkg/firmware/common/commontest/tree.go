@@ -0,0 +1,25 @@
+// Package commontest builds fake root file system trees for the collector tests.
+package commontest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// WriteFile creates path with the given contents, including all missing parent directories
+func WriteFile(t testing.TB, path string, contents string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+}
+
+// WriteTree creates one file per entry of files below dir. Names may contain slashes.
+func WriteTree(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		WriteFile(t, filepath.Join(dir, name), contents)
+	}
+}
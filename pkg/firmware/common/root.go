@@ -0,0 +1,25 @@
+package common
+
+import "path/filepath"
+
+// Root is the directory the Linux collectors treat as the root file system. It allows running them against a
+// captured directory tree, a mounted disk image or the host file system bind-mounted into a container. The zero
+// value is the root of the running system.
+//
+// Device nodes below /dev are never resolved against the root. They talk to the running kernel, like the MSR and
+// TPM devices, so collectors always open them at their usual path. Offline collection reports them as not
+// implemented instead.
+type Root string
+
+// IsHost returns true if r is the root of the running system
+func (r Root) IsHost() bool {
+	return r == "" || filepath.Clean(string(r)) == "/"
+}
+
+// Path resolves an absolute path against the root file system
+func (r Root) Path(path string) string {
+	if r.IsHost() {
+		return path
+	}
+	return filepath.Join(string(r), path)
+}
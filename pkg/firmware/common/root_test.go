@@ -0,0 +1,19 @@
+package common
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPath(t *testing.T) {
+	var host Root
+	assert.True(t, host.IsHost())
+	assert.True(t, Root("/").IsHost())
+	assert.Equal(t, "/etc/os-release", host.Path("/etc/os-release"))
+
+	image := Root("/mnt/image/")
+	assert.False(t, image.IsHost())
+	assert.Equal(t, filepath.Join("/mnt/image", "etc/os-release"), image.Path("/etc/os-release"))
+}
@@ -0,0 +1,251 @@
+package epp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	procfs     = "/proc"
+	dpkgStatus = "/var/lib/dpkg/status"
+)
+
+// detector recognizes an installed EPP/EDR product by its files, packages and processes
+type detector struct {
+	Name        string   // api.EPPProduct.Name
+	Binaries    []string // main binary, the first one present is reported
+	Processes   []string // process names as in /proc/<pid>/comm, truncated to 15 characters
+	Packages    []string // Debian or RPM package names, used for the version
+	VersionFile string   // optional file holding the version either on the first line or as PRODUCT_VERSION = x
+}
+
+var detectors []detector
+
+// registerDetector adds a product to the list of detected EPPs
+func registerDetector(d detector) {
+	detectors = append(detectors, d)
+}
+
+func init() {
+	registerDetector(detector{
+		Name:      "mdatp",
+		Binaries:  []string{"/opt/microsoft/mdatp/sbin/wdavdaemon"},
+		Processes: []string{"wdavdaemon"},
+		Packages:  []string{"mdatp"},
+	})
+	registerDetector(detector{
+		Name:      "crowdstrike",
+		Binaries:  []string{"/opt/CrowdStrike/falcond"},
+		Processes: []string{"falcond", "falcon-sensor"},
+		Packages:  []string{"falcon-sensor"},
+	})
+	registerDetector(detector{
+		Name:      "sentinelone",
+		Binaries:  []string{"/opt/sentinelone/bin/sentinelone-agent"},
+		Processes: []string{"sentinelone-age", "s1-agent"},
+		Packages:  []string{"sentinelagent", "SentinelAgent"},
+	})
+	registerDetector(detector{
+		Name:        "sophos",
+		Binaries:    []string{"/opt/sophos-spl/base/bin/sophos_managementagent", "/opt/sophos-spl/bin/sophos_watchdog"},
+		Processes:   []string{"sophos_watchdog", "sophos_managem"},
+		VersionFile: "/opt/sophos-spl/base/VERSION.ini",
+	})
+	registerDetector(detector{
+		Name:      "clamav",
+		Binaries:  []string{"/usr/sbin/clamd", "/usr/bin/clamd"},
+		Processes: []string{"clamd"},
+		Packages:  []string{"clamav-daemon", "clamd", "clamav"},
+	})
+	registerDetector(detector{
+		Name:      "trellix",
+		Binaries:  []string{"/opt/McAfee/ens/tp/bin/mfetpd", "/opt/McAfee/agent/bin/macompatsvc"},
+		Processes: []string{"mfetpd", "macompatsvc"},
+		Packages:  []string{"mcafeetp", "mfetp", "McAfeeTP"},
+	})
+}
+
+// detectProducts runs all registered detectors and returns the installed products, procs are the names of the
+// running processes
+func detectProducts(procs map[string]bool, root common.Root) []api.EPPProduct {
+	pkgs, err := installedPackages(root.Path(dpkgStatus))
+	if err != nil && !os.IsNotExist(err) {
+		log.Debug().Err(err).Msg("epp: reading dpkg status")
+	}
+	rpms, err := queryRPM(packageNames(), root)
+	if err != nil {
+		log.Debug().Err(err).Msg("epp: querying rpm database")
+	}
+
+	var products []api.EPPProduct
+	for _, d := range detectors {
+		var product api.EPPProduct
+		for _, bin := range d.Binaries {
+			if fi, err := os.Stat(root.Path(bin)); err == nil && fi.Mode().IsRegular() {
+				product.Binary = bin
+				break
+			}
+		}
+		for _, pkg := range d.Packages {
+			if v, ok := pkgs[pkg]; ok {
+				product.Version = v
+				break
+			}
+			if v, ok := rpms[pkg]; ok {
+				product.Version = v
+				break
+			}
+		}
+		if product.Binary == "" && product.Version == "" {
+			continue
+		}
+
+		product.Name = d.Name
+		for _, p := range d.Processes {
+			product.Running = product.Running || procs[p]
+		}
+		if product.Version == "" && d.VersionFile != "" {
+			product.Version = readVersionFile(root.Path(d.VersionFile))
+		}
+
+		log.Debug().Msgf("epp: found %s %s, running: %v", product.Name, product.Version, product.Running)
+		products = append(products, product)
+	}
+
+	return products
+}
+
+// runningProcesses returns the names of all processes
+func runningProcesses(root common.Root) (map[string]bool, error) {
+	entries, err := os.ReadDir(root.Path(procfs))
+	if err != nil {
+		return nil, err
+	}
+
+	procs := make(map[string]bool)
+	for _, e := range entries {
+		if _, err := strconv.ParseUint(e.Name(), 10, 32); err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(root.Path(filepath.Join(procfs, e.Name(), "comm")))
+		if err != nil {
+			continue
+		}
+		procs[strings.TrimSpace(string(comm))] = true
+	}
+
+	return procs, nil
+}
+
+// installedPackages parses the dpkg status database into a package name to version map
+func installedPackages(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pkgs := make(map[string]string)
+	var name, version, status string
+	flush := func() {
+		if name != "" && strings.HasSuffix(status, " installed") {
+			pkgs[name] = version
+		}
+		name, version, status = "", "", ""
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+		} else if v, ok := strings.CutPrefix(line, "Package: "); ok {
+			name = v
+		} else if v, ok := strings.CutPrefix(line, "Version: "); ok {
+			version = v
+		} else if v, ok := strings.CutPrefix(line, "Status: "); ok {
+			status = v
+		}
+	}
+	flush()
+
+	return pkgs, sc.Err()
+}
+
+// packageNames returns the package names of all detectors
+func packageNames() []string {
+	var names []string
+	for _, d := range detectors {
+		names = append(names, d.Packages...)
+	}
+	return names
+}
+
+// queryRPM asks rpm for the versions of the installed packages among names, replaced in tests. Systems without rpm
+// yield no packages.
+var queryRPM = func(names []string, root common.Root) (map[string]string, error) {
+	bin, err := exec.LookPath("rpm")
+	if errors.Is(err, exec.ErrNotFound) || len(names) == 0 {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	args := []string{"-q", "--qf", "%{NAME} %{VERSION}-%{RELEASE}\n"}
+	if !root.IsHost() {
+		args = append([]string{"--root", string(root)}, args...)
+	}
+	out, err := exec.Command(bin, append(args, names...)...).Output()
+	// rpm fails if any of the packages isn't installed
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+
+	return parseRPMQuery(out), nil
+}
+
+// parseRPMQuery parses the "name version-release" lines printed by queryRPM. Packages that aren't installed are
+// reported as "package x is not installed".
+func parseRPMQuery(out []byte) map[string]string {
+	pkgs := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 {
+			pkgs[fields[0]] = fields[1]
+		}
+	}
+	return pkgs
+}
+
+func readVersionFile(file string) string {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		log.Debug().Err(err).Str("path", file).Msg("epp: reading version file")
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	for _, line := range lines {
+		if key, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(key) == "PRODUCT_VERSION" {
+			return strings.TrimSpace(value)
+		}
+	}
+	if !strings.Contains(lines[0], "=") {
+		return strings.TrimSpace(lines[0])
+	}
+
+	return ""
+}
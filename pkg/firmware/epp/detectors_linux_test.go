@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package epp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDpkgStatus = `Package: mdatp
+Status: install ok installed
+Architecture: amd64
+Version: 101.23082.0006
+
+Package: clamav
+Status: deinstall ok config-files
+Version: 0.103.8+dfsg-0+deb11u1
+
+Package: falcon-sensor
+Status: install ok installed
+Description: CrowdStrike Falcon Sensor
+ multi line description
+Version: 7.05.0-16004
+`
+
+func TestInstalledPackages(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "status")
+	commontest.WriteFile(t, file, testDpkgStatus)
+
+	pkgs, err := installedPackages(file)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"mdatp": "101.23082.0006", "falcon-sensor": "7.05.0-16004"}, pkgs)
+}
+
+func TestDetectProducts(t *testing.T) {
+	saved, savedRPM, savedProcfs, savedStatus := detectors, queryRPM, procfs, dpkgStatus
+	t.Cleanup(func() { detectors, queryRPM, procfs, dpkgStatus = saved, savedRPM, savedProcfs, savedStatus })
+	root := t.TempDir()
+	procfs = filepath.Join(root, "proc")
+	dpkgStatus = filepath.Join(root, "status")
+
+	commontest.WriteFile(t, dpkgStatus, testDpkgStatus)
+	commontest.WriteFile(t, filepath.Join(procfs, "1", "comm"), "systemd\n")
+	commontest.WriteFile(t, filepath.Join(procfs, "812", "comm"), "wdavdaemon\n")
+	commontest.WriteFile(t, filepath.Join(procfs, "self", "comm"), "guard\n")
+	commontest.WriteFile(t, filepath.Join(root, "opt/sophos-spl/bin/sophos_watchdog"), "binary")
+	commontest.WriteFile(t, filepath.Join(root, "opt/sophos-spl/base/VERSION.ini"), "PRODUCT_NAME = Sophos Server Protection Linux - Base Component\nPRODUCT_VERSION = 1.2.3.4\n")
+
+	detectors = nil
+	queryRPM = func([]string, common.Root) (map[string]string, error) { return nil, nil }
+	registerDetector(detector{Name: "mdatp", Processes: []string{"wdavdaemon"}, Packages: []string{"mdatp"}})
+	registerDetector(detector{Name: "clamav", Processes: []string{"clamd"}, Packages: []string{"clamav"}})
+	registerDetector(detector{
+		Name:        "sophos",
+		Binaries:    []string{filepath.Join(root, "opt/sophos-spl/bin/sophos_watchdog")},
+		Processes:   []string{"sophos_watchdog"},
+		VersionFile: filepath.Join(root, "opt/sophos-spl/base/VERSION.ini"),
+	})
+
+	procs, err := runningProcesses("")
+	assert.NoError(t, err)
+	products := detectProducts(procs, "")
+	assert.Equal(t, []api.EPPProduct{
+		{Name: "mdatp", Running: true, Version: "101.23082.0006"},
+		{Name: "sophos", Version: "1.2.3.4", Binary: filepath.Join(root, "opt/sophos-spl/bin/sophos_watchdog")},
+	}, products)
+}
+
+func TestParseRPMQuery(t *testing.T) {
+	out := "mdatp 101.23082.0006-1\npackage falcon-sensor is not installed\nclamd 0.103.9-1.el9\n"
+	assert.Equal(t, map[string]string{"mdatp": "101.23082.0006-1", "clamd": "0.103.9-1.el9"}, parseRPMQuery([]byte(out)))
+}
+
+func TestDetectProductsRPM(t *testing.T) {
+	saved, savedRPM := detectors, queryRPM
+	t.Cleanup(func() { detectors, queryRPM = saved, savedRPM })
+	root := t.TempDir()
+	commontest.WriteFile(t, filepath.Join(root, "usr/sbin/clamd"), "binary")
+
+	detectors = nil
+	queryRPM = func(names []string, r common.Root) (map[string]string, error) {
+		assert.Equal(t, []string{"mdatp", "clamav-daemon", "clamd"}, names)
+		assert.Equal(t, common.Root(root), r)
+		return map[string]string{"clamd": "0.103.9-1.el9"}, nil
+	}
+	registerDetector(detector{Name: "mdatp", Processes: []string{"wdavdaemon"}, Packages: []string{"mdatp"}})
+	registerDetector(detector{Name: "clamav", Binaries: []string{"/usr/sbin/clamd"}, Processes: []string{"clamd"}, Packages: []string{"clamav-daemon", "clamd"}})
+
+	products := detectProducts(map[string]bool{"clamd": true}, common.Root(root))
+	assert.Equal(t, []api.EPPProduct{
+		{Name: "clamav", Running: true, Version: "0.103.9-1.el9", Binary: "/usr/sbin/clamd"},
+	}, products)
+}
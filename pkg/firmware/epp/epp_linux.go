@@ -7,30 +7,69 @@ import (
 
 	"github.com/immune-gmbh/agent/v3/pkg/api"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/util"
 )
 
-func ReportEPP(eppInfo *api.EPPInfo) error {
-	_, err := os.Stat("/sys/module/eset_rtp/refcnt")
+func ReportEPP(eppInfo *api.EPPInfo, root common.Root) error {
+	log.Trace().Msg("ReportEPP()")
+
+	reportESET(eppInfo, root)
+
+	procs, err := runningProcesses(root)
+	if err != nil {
+		eppInfo.AntimalwareProcessesErr = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("epp.runningProcesses()")
+		return nil
+	}
+	reportProducts(eppInfo, detectProducts(procs, root), root)
+
+	return nil
+}
+
+// ReportOfflineEPP reports the products installed in the root file system of a powered-off machine
+func ReportOfflineEPP(eppInfo *api.EPPInfo, root common.Root) error {
+	log.Trace().Msg("ReportOfflineEPP()")
+
+	reportProducts(eppInfo, detectProducts(nil, root), root)
+
+	return nil
+}
+
+func reportProducts(eppInfo *api.EPPInfo, products []api.EPPProduct, root common.Root) {
+	for _, p := range products {
+		if p.Binary == "" {
+			continue
+		}
+		if eppInfo.AntimalwareProcesses == nil {
+			eppInfo.AntimalwareProcesses = make(map[string]api.HashBlob)
+		}
+		eppInfo.AntimalwareProcesses[p.Binary] = util.FileToHashBlob(root.Path(p.Binary))
+	}
+	eppInfo.Products = products
+}
+
+func reportESET(eppInfo *api.EPPInfo, root common.Root) {
+	_, err := os.Stat(root.Path("/sys/module/eset_rtp/refcnt"))
 	if os.IsNotExist(err) {
 		log.Trace().Msg("eset_rtp module not loaded")
-		return nil
+		return
 	}
 
 	var eset api.ESETConfig
 
-	data, err := os.ReadFile("/sys/module/eset_rtp/settings/enable")
+	data, err := os.ReadFile(root.Path("/sys/module/eset_rtp/settings/enable"))
 	eset.Enabled.Data = api.Buffer(data)
 	if err != nil {
 		log.Debug().Err(err).Msg("reading settings/enable")
 		eset.Enabled.Error = common.ServeApiError(common.MapFSErrors(err))
 	}
-	data, err = os.ReadFile("/sys/module/eset_rtp/settings/excludes/files")
+	data, err = os.ReadFile(root.Path("/sys/module/eset_rtp/settings/excludes/files"))
 	eset.ExcludedFiles.Data = api.Buffer(data)
 	if err != nil {
 		log.Debug().Err(err).Msg("reading settings/excludes/files")
 		eset.ExcludedFiles.Error = common.ServeApiError(common.MapFSErrors(err))
 	}
-	data, err = os.ReadFile("/sys/module/eset_rtp/settings/excludes/procs")
+	data, err = os.ReadFile(root.Path("/sys/module/eset_rtp/settings/excludes/procs"))
 	eset.ExcludedProcesses.Data = api.Buffer(data)
 	if err != nil {
 		log.Debug().Err(err).Msg("reading settings/excludes/procs")
@@ -38,5 +77,4 @@ func ReportEPP(eppInfo *api.EPPInfo) error {
 	}
 
 	eppInfo.ESET = &eset
-	return nil
 }
@@ -2,8 +2,15 @@
 
 package epp
 
-import "github.com/immune-gmbh/agent/v3/pkg/api"
+import (
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
 
-func ReportEPP(eppInfo *api.EPPInfo) error {
+func ReportEPP(eppInfo *api.EPPInfo, root common.Root) error {
+	return nil
+}
+
+func ReportOfflineEPP(eppInfo *api.EPPInfo, root common.Root) error {
 	return nil
 }
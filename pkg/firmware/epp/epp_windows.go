@@ -7,7 +7,7 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func ReportEPP(eppInfo *api.EPPInfo) error {
+func ReportEPP(eppInfo *api.EPPInfo, root common.Root) error {
 	log.Trace().Msg("ReportEPP()")
 
 	elamDrivers, err := ListElamDriverPaths()
@@ -34,3 +34,7 @@ func ReportEPP(eppInfo *api.EPPInfo) error {
 
 	return nil
 }
+
+func ReportOfflineEPP(eppInfo *api.EPPInfo, root common.Root) error {
+	return nil
+}
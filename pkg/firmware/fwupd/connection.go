@@ -29,7 +29,10 @@ func ReportFWUPD(devs *api.Devices) error {
 	}
 	defer conn.Close()
 
-	obj := conn.Object("org.freedesktop.fwupd", "/")
+	return reportFWUPD(conn.Object("org.freedesktop.fwupd", "/"), devs)
+}
+
+func reportFWUPD(obj dbus.BusObject, devs *api.Devices) error {
 	v, err := obj.GetProperty("org.freedesktop.fwupd.DaemonVersion")
 	if err != nil {
 		log.Debug().Err(err).Msg("fwupd.ReportFWUPD()")
@@ -93,5 +96,35 @@ func ReportFWUPD(devs *api.Devices) error {
 		}
 	}
 
+	reportHostSecurity(obj, devs)
+	reportUpdates(obj, devices, deviceIds, devs)
+
 	return nil
 }
+
+// reportHostSecurity collects the HSI attributes, their absence is not fatal as only newer fwupd versions on x86 support HSI
+func reportHostSecurity(obj dbus.BusObject, devs *api.Devices) {
+	v, err := obj.GetProperty("org.freedesktop.fwupd.HostSecurityId")
+	if err != nil {
+		log.Debug().Err(err).Msg("fwupd.ReportFWUPD(): HostSecurityId")
+		devs.HostSecurityErr = api.NotImplemented
+		return
+	}
+	devs.HostSecurityID, _ = v.Value().(string)
+
+	var attrs []map[string]dbus.Variant
+	err = obj.Call("org.freedesktop.fwupd.GetHostSecurityAttrs", 0).Store(&attrs)
+	if err != nil {
+		log.Debug().Err(err).Msg("fwupd.ReportFWUPD(): GetHostSecurityAttrs")
+		devs.HostSecurityErr = api.NoResponse
+		return
+	}
+
+	devs.HostSecurityAttrs = make([]api.FWUPdHSIAttribute, len(attrs))
+	for i, attr := range attrs {
+		devs.HostSecurityAttrs[i] = make(api.FWUPdHSIAttribute, len(attr))
+		for k, v := range attr {
+			devs.HostSecurityAttrs[i][k] = v.Value()
+		}
+	}
+}
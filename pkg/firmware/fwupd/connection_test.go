@@ -0,0 +1,216 @@
+//go:build linux
+// +build linux
+
+package fwupd
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+// fakeFWUPD implements the parts of the org.freedesktop.fwupd interface used by the collector
+type fakeFWUPD struct {
+	Devices  []map[string]dbus.Variant
+	Releases map[string][]map[string]dbus.Variant
+	HSIAttrs []map[string]dbus.Variant
+	HSIErr   *dbus.Error
+	History  []map[string]dbus.Variant
+	Results  map[string]map[string]dbus.Variant
+}
+
+func (f *fakeFWUPD) SetFeatureFlags(flags uint64) *dbus.Error {
+	return nil
+}
+
+func (f *fakeFWUPD) GetDevices() ([]map[string]dbus.Variant, *dbus.Error) {
+	return f.Devices, nil
+}
+
+func (f *fakeFWUPD) GetReleases(id string) ([]map[string]dbus.Variant, *dbus.Error) {
+	if rel, ok := f.Releases[id]; ok {
+		return rel, nil
+	}
+	return nil, dbus.NewError("org.freedesktop.fwupd.NothingToDo", []interface{}{"no releases"})
+}
+
+func (f *fakeFWUPD) GetHostSecurityAttrs() ([]map[string]dbus.Variant, *dbus.Error) {
+	return f.HSIAttrs, f.HSIErr
+}
+
+func (f *fakeFWUPD) GetHistory() ([]map[string]dbus.Variant, *dbus.Error) {
+	if len(f.History) == 0 {
+		return nil, dbus.NewError("org.freedesktop.fwupd.NothingToDo", []interface{}{"No history"})
+	}
+	return f.History, nil
+}
+
+func (f *fakeFWUPD) GetResults(id string) (map[string]dbus.Variant, *dbus.Error) {
+	if res, ok := f.Results[id]; ok {
+		return res, nil
+	}
+	return nil, dbus.NewError("org.freedesktop.fwupd.NothingToDo", []interface{}{"No results"})
+}
+
+// startFakeFWUPD runs a private bus daemon with fake serving as fwupd and returns the client side fwupd object
+func startFakeFWUPD(t *testing.T, fake *fakeFWUPD, props map[string]interface{}) dbus.BusObject {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	assert.NoError(t, err)
+	addr = strings.TrimSpace(addr)
+
+	srv, err := dbus.Connect(addr)
+	assert.NoError(t, err)
+	t.Cleanup(func() { srv.Close() })
+
+	assert.NoError(t, srv.Export(fake, "/", "org.freedesktop.fwupd"))
+	propMap := make(map[string]*prop.Prop)
+	for k, v := range props {
+		propMap[k] = &prop.Prop{Value: v, Emit: prop.EmitFalse}
+	}
+	_, err = prop.Export(srv, "/", prop.Map{"org.freedesktop.fwupd": propMap})
+	assert.NoError(t, err)
+	reply, err := srv.RequestName("org.freedesktop.fwupd", dbus.NameFlagDoNotQueue)
+	assert.NoError(t, err)
+	assert.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+
+	cl, err := dbus.Connect(addr)
+	assert.NoError(t, err)
+	t.Cleanup(func() { cl.Close() })
+
+	return cl.Object("org.freedesktop.fwupd", "/")
+}
+
+func TestReportFWUPDHostSecurity(t *testing.T) {
+	fake := &fakeFWUPD{
+		Devices: []map[string]dbus.Variant{
+			{"DeviceId": dbus.MakeVariant("362301da643102b9f38477387e2193e57abaa590"), "Name": dbus.MakeVariant("UEFI dbx")},
+		},
+		Releases: map[string][]map[string]dbus.Variant{
+			"362301da643102b9f38477387e2193e57abaa590": {{"Version": dbus.MakeVariant("371")}},
+		},
+		HSIAttrs: []map[string]dbus.Variant{
+			{
+				"AppstreamId": dbus.MakeVariant("org.fwupd.hsi.IntelBootguard.Enabled"),
+				"HsiLevel":    dbus.MakeVariant(uint32(2)),
+				"HsiResult":   dbus.MakeVariant(uint32(4)),
+				"Flags":       dbus.MakeVariant(uint64(1)),
+			},
+			{
+				"AppstreamId": dbus.MakeVariant("org.fwupd.hsi.Kernel.Lockdown"),
+				"HsiResult":   dbus.MakeVariant(uint32(9)),
+			},
+		},
+	}
+	obj := startFakeFWUPD(t, fake, map[string]interface{}{
+		"DaemonVersion":  "1.9.5",
+		"HostSecurityId": "HSI:2! (v1.9.5)",
+	})
+
+	var devs api.Devices
+	assert.NoError(t, reportFWUPD(obj, &devs))
+	assert.Equal(t, `"1.9.5"`, devs.FWUPdVersion)
+	assert.Len(t, devs.Topology, 1)
+	assert.Len(t, devs.Releases["362301da643102b9f38477387e2193e57abaa590"], 1)
+
+	assert.Equal(t, "HSI:2! (v1.9.5)", devs.HostSecurityID)
+	assert.Equal(t, api.NoError, devs.HostSecurityErr)
+	assert.Len(t, devs.HostSecurityAttrs, 2)
+	assert.Equal(t, "org.fwupd.hsi.IntelBootguard.Enabled", devs.HostSecurityAttrs[0]["AppstreamId"])
+	assert.Equal(t, uint32(2), devs.HostSecurityAttrs[0]["HsiLevel"])
+}
+
+func TestReportFWUPDNoHostSecurity(t *testing.T) {
+	fake := &fakeFWUPD{
+		HSIErr: dbus.NewError("org.freedesktop.fwupd.NotSupported", []interface{}{"HSI not supported"}),
+	}
+	obj := startFakeFWUPD(t, fake, map[string]interface{}{
+		"DaemonVersion":  "1.9.5",
+		"HostSecurityId": "",
+	})
+
+	var devs api.Devices
+	assert.NoError(t, reportFWUPD(obj, &devs))
+	assert.Empty(t, devs.HostSecurityAttrs)
+	assert.Equal(t, api.NoResponse, devs.HostSecurityErr)
+}
+
+func TestReportFWUPDUpdates(t *testing.T) {
+	const dbxID = "362301da643102b9f38477387e2193e57abaa590"
+	const meID = "6dd9c1fd8a2e3cb7d4b0c1a2e3f405162738495a"
+
+	fake := &fakeFWUPD{
+		Devices: []map[string]dbus.Variant{
+			{"DeviceId": dbus.MakeVariant(dbxID), "Name": dbus.MakeVariant("UEFI dbx"), "Version": dbus.MakeVariant("217")},
+			{
+				"DeviceId":    dbus.MakeVariant(meID),
+				"Name":        dbus.MakeVariant("System Firmware"),
+				"Version":     dbus.MakeVariant("1.12.0"),
+				"UpdateState": dbus.MakeVariant(FWUPD_UPDATE_STATE_NEEDS_REBOOT),
+			},
+		},
+		History: []map[string]dbus.Variant{
+			{
+				"DeviceId":    dbus.MakeVariant(meID),
+				"Name":        dbus.MakeVariant("System Firmware"),
+				"Version":     dbus.MakeVariant("1.12.0"),
+				"UpdateState": dbus.MakeVariant(FWUPD_UPDATE_STATE_FAILED),
+				"UpdateError": dbus.MakeVariant("failed to run update on reboot"),
+				"Created":     dbus.MakeVariant(uint64(1697000000)),
+				"Modified":    dbus.MakeVariant(uint64(1697000100)),
+				"Release": dbus.MakeVariant([]map[string]dbus.Variant{
+					{"Version": dbus.MakeVariant("1.13.1")},
+				}),
+			},
+		},
+		Results: map[string]map[string]dbus.Variant{
+			dbxID: {
+				"DeviceId":    dbus.MakeVariant(dbxID),
+				"Version":     dbus.MakeVariant("217"),
+				"UpdateState": dbus.MakeVariant(FWUPD_UPDATE_STATE_SUCCESS),
+			},
+		},
+	}
+	obj := startFakeFWUPD(t, fake, map[string]interface{}{
+		"DaemonVersion":  "1.9.5",
+		"HostSecurityId": "HSI:1 (v1.9.5)",
+	})
+
+	var devs api.Devices
+	assert.NoError(t, reportFWUPD(obj, &devs))
+	assert.Equal(t, api.NoError, devs.UpdatesErr)
+	assert.Equal(t, []api.FWUPdUpdate{
+		{
+			Source:     UpdateSourceHistory,
+			DeviceID:   meID,
+			Name:       "System Firmware",
+			Version:    "1.12.0",
+			NewVersion: "1.13.1",
+			State:      "failed",
+			Error:      "failed to run update on reboot",
+			Created:    1697000000,
+			Modified:   1697000100,
+		},
+		{Source: UpdateSourceResults, DeviceID: dbxID, Version: "217", State: "success"},
+		{Source: UpdateSourceDevice, DeviceID: meID, Name: "System Firmware", Version: "1.12.0", State: "needs-reboot"},
+	}, devs.Updates)
+}
@@ -0,0 +1,111 @@
+package fwupd
+
+import (
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+// FwupdUpdateState
+const (
+	FWUPD_UPDATE_STATE_UNKNOWN uint32 = iota
+	FWUPD_UPDATE_STATE_PENDING
+	FWUPD_UPDATE_STATE_SUCCESS
+	FWUPD_UPDATE_STATE_FAILED
+	FWUPD_UPDATE_STATE_NEEDS_REBOOT
+	FWUPD_UPDATE_STATE_FAILED_TRANSIENT
+)
+
+const (
+	UpdateSourceHistory = "history"
+	UpdateSourceResults = "results"
+	UpdateSourceDevice  = "device"
+)
+
+func updateStateString(state uint32) string {
+	switch state {
+	case FWUPD_UPDATE_STATE_PENDING:
+		return "pending"
+	case FWUPD_UPDATE_STATE_SUCCESS:
+		return "success"
+	case FWUPD_UPDATE_STATE_FAILED:
+		return "failed"
+	case FWUPD_UPDATE_STATE_NEEDS_REBOOT:
+		return "needs-reboot"
+	case FWUPD_UPDATE_STATE_FAILED_TRANSIENT:
+		return "failed-transient"
+	default:
+		return "unknown"
+	}
+}
+
+// parseUpdate converts a fwupd device dictionary into an update entry, the second return value is false if the
+// device carries no update state
+func parseUpdate(dev map[string]dbus.Variant, source string) (api.FWUPdUpdate, bool) {
+	upd := api.FWUPdUpdate{Source: source}
+
+	state, ok := dev["UpdateState"].Value().(uint32)
+	if !ok || state == FWUPD_UPDATE_STATE_UNKNOWN {
+		return upd, false
+	}
+	upd.State = updateStateString(state)
+
+	upd.DeviceID, _ = dev["DeviceId"].Value().(string)
+	upd.Name, _ = dev["Name"].Value().(string)
+	upd.Version, _ = dev["Version"].Value().(string)
+	upd.Error, _ = dev["UpdateError"].Value().(string)
+	upd.Created, _ = dev["Created"].Value().(uint64)
+	upd.Modified, _ = dev["Modified"].Value().(uint64)
+
+	// history entries carry the release that was installed
+	if releases, ok := dev["Release"].Value().([]map[string]dbus.Variant); ok && len(releases) > 0 {
+		upd.NewVersion, _ = releases[0]["Version"].Value().(string)
+	}
+
+	return upd, true
+}
+
+// reportUpdates collects pending and past updates from fwupd's history, the per-device results and the update state
+// of the devices returned by GetDevices
+func reportUpdates(obj dbus.BusObject, devices []map[string]dbus.Variant, deviceIds []string, devs *api.Devices) {
+	var history []map[string]dbus.Variant
+	err := obj.Call("org.freedesktop.fwupd.GetHistory", 0).Store(&history)
+	// fwupd returns an error instead of an empty list if there is no history
+	if err != nil {
+		log.Debug().Err(err).Msg("fwupd.ReportFWUPD(): GetHistory")
+		if !isNothingToDo(err) {
+			devs.UpdatesErr = api.NoResponse
+		}
+	}
+	for _, dev := range history {
+		if upd, ok := parseUpdate(dev, UpdateSourceHistory); ok {
+			devs.Updates = append(devs.Updates, upd)
+		}
+	}
+
+	for _, id := range deviceIds {
+		var result map[string]dbus.Variant
+		err := obj.Call("org.freedesktop.fwupd.GetResults", 0, id).Store(&result)
+		if err != nil {
+			log.Trace().Err(err).Msgf("fwupd.ReportFWUPD(): GetResults %s", id)
+			continue
+		}
+		if upd, ok := parseUpdate(result, UpdateSourceResults); ok {
+			devs.Updates = append(devs.Updates, upd)
+		}
+	}
+
+	for _, dev := range devices {
+		if upd, ok := parseUpdate(dev, UpdateSourceDevice); ok {
+			devs.Updates = append(devs.Updates, upd)
+		}
+	}
+}
+
+func isNothingToDo(err error) bool {
+	var dbusErr dbus.Error
+	return errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.fwupd.NothingToDo"
+}
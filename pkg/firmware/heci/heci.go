@@ -32,8 +32,8 @@ type MECommandIntf interface {
 	close() error
 }
 
-func reportMEClientCommands(command *api.MEClientCommands) error {
-	m, err := openMEClientInterface(command)
+func reportMEClientCommands(command *api.MEClientCommands, root common.Root) error {
+	m, err := openMEClientInterface(command, root)
 	if err != nil {
 		return err
 	}
@@ -54,13 +54,13 @@ func reportMEClientCommands(command *api.MEClientCommands) error {
 	return nil
 }
 
-func ReportMECommands(commands []api.MEClientCommands) (err error) {
+func ReportMECommands(commands []api.MEClientCommands, root common.Root) (err error) {
 	log.Trace().Msg("ReportMECommands()")
 
 	allFailed := true
 	for i := range commands {
 		v := &commands[i]
-		err = reportMEClientCommands(v)
+		err = reportMEClientCommands(v, root)
 		allFailed = allFailed && err != nil
 		if err != nil {
 			v.Error = common.ServeApiError(common.MapFSErrors(err))
@@ -80,7 +80,7 @@ func ReportMECommands(commands []api.MEClientCommands) (err error) {
 // Inside cmd parameter, GUID is required for the connection via device, the client address is required for raw messaging.
 // If any is not supplied, then there will be no connection via that method.
 // Address is expected to be < 0 if it is not present.
-func openMEClientInterface(cmd *api.MEClientCommands) (MECommandIntf, error) {
+func openMEClientInterface(cmd *api.MEClientCommands, root common.Root) (MECommandIntf, error) {
 	if cmd.GUID != nil {
 		m, err := openMEI("", *cmd.GUID)
 		if err != nil {
@@ -100,7 +100,7 @@ func openMEClientInterface(cmd *api.MEClientCommands) (MECommandIntf, error) {
 			return nil, err
 		}
 
-		m, err := openHECI1(uint8(addr))
+		m, err := openHECI1(uint8(addr), root)
 		if err != nil {
 			return nil, err
 		}
@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"os"
 	"syscall"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
 const defaultDevMemPath = "/dev/mem"
 
-func openHECI1(clientAddress uint8) (*heci, error) {
-	f, err := os.OpenFile(heci1PciConfigPath, os.O_RDWR, 0)
+func openHECI1(clientAddress uint8, root common.Root) (*heci, error) {
+	f, err := os.OpenFile(root.Path(heci1PciConfigPath), os.O_RDWR, 0)
 	if err != nil {
 		return nil, fmt.Errorf("can't open HECI PCI config space: %w", err)
 	}
@@ -5,11 +5,13 @@ package heci
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
 type osHandleType *int
 
-func openHECI1(clientAddress uint8) (*heci, error) {
+func openHECI1(clientAddress uint8, root common.Root) (*heci, error) {
 	return nil, errors.New("heci.openHECI1 not implemented on " + runtime.GOARCH)
 }
 
@@ -0,0 +1,170 @@
+package heci
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+// HFSTS1 and HFSTS3 fields
+const (
+	hfsts1WorkingStateMask  = 0xf
+	hfsts1ManufacturingMode = 1 << 4
+	hfsts1ErrorCodeShift    = 12
+	hfsts1ErrorCodeMask     = 0xf
+	hfsts1OpModeShift       = 16
+	hfsts1OpModeMask        = 0xf
+	hfsts3SKUShift          = 4
+	hfsts3SKUMask           = 0x7
+)
+
+var workingStates = map[uint32]string{
+	0: "reset",
+	1: "initializing",
+	2: "recovery",
+	3: "test",
+	4: "disabled",
+	5: "normal",
+	6: "wait",
+	7: "transition",
+	8: "invalid cpu",
+}
+
+var operationModes = map[uint32]string{
+	0: "normal",
+	2: "debug",
+	3: "temporarily disabled",
+	4: "security override jumper",
+	5: "security override mei",
+	6: "enhanced debug",
+}
+
+var variants = map[uint32]string{
+	skuIgnition:   api.ICU,
+	skuTXE:        api.TXE,
+	skuMEConsumer: api.ConsumerME,
+	skuMEBusiness: api.BusinessME,
+	skuLight:      api.LightME,
+	skuSPS:        api.SPS,
+}
+
+// parseFWVersions decodes the fw_ver attribute, one platform:major.minor.hotfix.build line for the running,
+// recovery and FITC image each
+func parseFWVersions(str string) ([][]uint16, error) {
+	var versions [][]uint16
+	for _, line := range strings.Fields(str) {
+		_, ver, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid firmware version %q", line)
+		}
+		var parts []uint16
+		for _, p := range strings.Split(ver, ".") {
+			n, err := strconv.ParseUint(p, 10, 16)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, uint16(n))
+		}
+		versions = append(versions, parts)
+	}
+	if len(versions) == 0 {
+		return nil, errors.New("no firmware version")
+	}
+	return versions, nil
+}
+
+// parseRegisters decodes fw_status and trc, one hex register per line
+func parseRegisters(str string) ([]uint32, error) {
+	var regs []uint32
+	for _, line := range strings.Fields(str) {
+		n, err := strconv.ParseUint(line, 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		regs = append(regs, uint32(n))
+	}
+	return regs, nil
+}
+
+func decodeHFSTS(st *api.MEStatus) {
+	if len(st.HFSTS) >= 1 {
+		hfsts1 := st.HFSTS[0]
+		ws := hfsts1 & hfsts1WorkingStateMask
+		if name, ok := workingStates[ws]; ok {
+			st.WorkingState = name
+		} else {
+			st.WorkingState = fmt.Sprintf("unknown (%d)", ws)
+		}
+		mode := hfsts1 >> hfsts1OpModeShift & hfsts1OpModeMask
+		if name, ok := operationModes[mode]; ok {
+			st.OperationMode = name
+		} else {
+			st.OperationMode = fmt.Sprintf("unknown (%d)", mode)
+		}
+		st.ErrorCode = uint(hfsts1 >> hfsts1ErrorCodeShift & hfsts1ErrorCodeMask)
+		st.ManufacturingMode = hfsts1&hfsts1ManufacturingMode != 0
+	}
+
+	// the SKU is only in HFSTS3 since CSME 11
+	if len(st.HFSTS) >= 3 {
+		if st.Firmware == nil {
+			st.Firmware = &api.ME{Manufacturer: "Intel"}
+		}
+		if variant, ok := variants[st.HFSTS[2]>>hfsts3SKUShift&hfsts3SKUMask]; ok {
+			st.Firmware.Variant = variant
+		} else {
+			st.Firmware.Variant = api.UnknownME
+		}
+	}
+}
+
+// ReportMEStatus reads the ME firmware version and status registers exported by the mei driver. Unlike
+// ReportMECommands this works on kernels that deny raw HECI access and without access to /dev/mei0. st.Firmware
+// only carries the Variant, Version, RecoveryVersion, FITCVersion and Manufacturer of ReportValues.ME, the server
+// fills these from it when the HECI commands were denied or failed.
+func ReportMEStatus(st *api.MEStatus, root common.Root) error {
+	log.Trace().Msg("ReportMEStatus()")
+
+	fwVer, fwStatus, trc, err := readMEISysfs(root)
+	if err != nil {
+		log.Debug().Err(err).Msg("heci.ReportMEStatus()")
+		st.Error = common.ServeApiError(common.MapFSErrors(err))
+		return err
+	}
+
+	if fwStatus != "" {
+		st.HFSTS, err = parseRegisters(fwStatus)
+		if err != nil {
+			log.Debug().Err(err).Msg("heci.ReportMEStatus(): fw_status")
+			st.Error = common.ServeApiError(err)
+			return err
+		}
+	}
+	if trc != "" {
+		if regs, err := parseRegisters(trc); err == nil && len(regs) == 1 {
+			st.TRC = &regs[0]
+		}
+	}
+	if fwVer != "" {
+		versions, err := parseFWVersions(fwVer)
+		if err != nil {
+			log.Debug().Err(err).Msg("heci.ReportMEStatus(): fw_ver")
+		} else {
+			st.Firmware = &api.ME{Version: versions[0], Manufacturer: "Intel"}
+			if len(versions) > 1 {
+				st.Firmware.RecoveryVersion = versions[1]
+			}
+			if len(versions) > 2 {
+				st.Firmware.FITCVersion = versions[2]
+			}
+		}
+	}
+	decodeHFSTS(st)
+
+	return nil
+}
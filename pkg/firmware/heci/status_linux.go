@@ -0,0 +1,42 @@
+package heci
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+var meiClassPath = "/sys/class/mei"
+
+func readAttr(dir, name string) string {
+	buf, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// readMEISysfs returns the fw_ver, fw_status and trc attributes of the first mei device that is not a sibling
+// device like the touch controller. Depending on kernel version and ME generation only some of them exist.
+func readMEISysfs(root common.Root) (string, string, string, error) {
+	entries, err := os.ReadDir(root.Path(meiClassPath))
+	if err != nil {
+		return "", "", "", err
+	}
+
+	for _, entry := range entries {
+		dir := filepath.Join(root.Path(meiClassPath), entry.Name())
+		if kind := readAttr(dir, "kind"); kind != "" && kind != "mei" {
+			continue
+		}
+		fwVer, fwStatus, trc := readAttr(dir, "fw_ver"), readAttr(dir, "fw_status"), readAttr(dir, "trc")
+		if fwVer == "" && fwStatus == "" {
+			continue
+		}
+		return fwVer, fwStatus, trc, nil
+	}
+
+	return "", "", "", os.ErrNotExist
+}
@@ -0,0 +1,76 @@
+//go:build linux
+// +build linux
+
+package heci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportMEStatus(t *testing.T) {
+	old := meiClassPath
+	t.Cleanup(func() { meiClassPath = old })
+	meiClassPath = t.TempDir()
+
+	// touch controller sorts first
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei0/kind"), "itouch\n")
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei0/fw_status"), "00000000\n")
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei1/kind"), "mei\n")
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei1/fw_ver"), "0:16.1.25.2124\n0:16.1.25.2124\n0:16.0.15.1518\n")
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei1/fw_status"),
+		"94000245\n09F10506\n00000020\n00004000\n00041F03\nC7E003CB\n")
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei1/trc"), "00000C02\n")
+
+	var st api.MEStatus
+	assert.NoError(t, ReportMEStatus(&st, ""))
+	assert.Empty(t, st.Error)
+	assert.Equal(t, &api.ME{
+		Variant:         api.ConsumerME,
+		Version:         []uint16{16, 1, 25, 2124},
+		RecoveryVersion: []uint16{16, 1, 25, 2124},
+		FITCVersion:     []uint16{16, 0, 15, 1518},
+		Manufacturer:    "Intel",
+	}, st.Firmware)
+	assert.Equal(t, []uint32{0x94000245, 0x09f10506, 0x20, 0x4000, 0x41f03, 0xc7e003cb}, st.HFSTS)
+	assert.Equal(t, uint32(0xc02), *st.TRC)
+	assert.Equal(t, "normal", st.WorkingState)
+	assert.Equal(t, "normal", st.OperationMode)
+	assert.Equal(t, uint(0), st.ErrorCode)
+	assert.False(t, st.ManufacturingMode)
+
+	// older kernels only export fw_status
+	assert.NoError(t, os.RemoveAll(filepath.Join(meiClassPath, "mei0")))
+	assert.NoError(t, os.Remove(filepath.Join(meiClassPath, "mei1/fw_ver")))
+	assert.NoError(t, os.Remove(filepath.Join(meiClassPath, "mei1/trc")))
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei1/fw_status"), "00032255\n")
+	st = api.MEStatus{}
+	assert.NoError(t, ReportMEStatus(&st, ""))
+	assert.Nil(t, st.Firmware)
+	assert.Nil(t, st.TRC)
+	assert.Equal(t, "normal", st.WorkingState)
+	assert.Equal(t, "temporarily disabled", st.OperationMode)
+	assert.Equal(t, uint(2), st.ErrorCode)
+	assert.True(t, st.ManufacturingMode)
+
+	// variant without fw_ver
+	commontest.WriteFile(t, filepath.Join(meiClassPath, "mei1/fw_status"), "94000245\n09F10506\n00000030\n")
+	st = api.MEStatus{}
+	assert.NoError(t, ReportMEStatus(&st, ""))
+	assert.Equal(t, &api.ME{Variant: api.BusinessME, Manufacturer: "Intel"}, st.Firmware)
+}
+
+func TestReportMEStatusNoDevice(t *testing.T) {
+	old := meiClassPath
+	t.Cleanup(func() { meiClassPath = old })
+	meiClassPath = filepath.Join(t.TempDir(), "missing")
+
+	var st api.MEStatus
+	assert.Error(t, ReportMEStatus(&st, ""))
+	assert.Equal(t, api.NoResponse, st.Error)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package heci
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+func readMEISysfs(root common.Root) (string, string, string, error) {
+	return "", "", "", errors.New("heci.readMEISysfs not implemented on " + runtime.GOOS)
+}
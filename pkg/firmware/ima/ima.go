@@ -1,17 +1,23 @@
 package ima
 
 import (
+	"os"
+
 	"github.com/klauspost/compress/zstd"
 	"github.com/rs/zerolog/log"
 
 	"github.com/immune-gmbh/agent/v3/pkg/api"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/util"
 )
 
-func ReportIMALog(imaLog *api.ErrorBuffer) error {
+// custom policies systemd and dracut's integrity module load at boot
+var policyFiles = []string{"/etc/ima/ima-policy", "/etc/sysconfig/ima-policy"}
+
+func ReportIMALog(imaLog *api.ErrorBuffer, root common.Root) error {
 	log.Trace().Msg("ReportIMALog()")
 
-	buf, err := readIMALog()
+	buf, err := readIMALog(root)
 	if err != nil {
 		log.Debug().Err(err).Msg("ima.ReportIMALog()")
 		log.Warn().Msg("Failed to read Linux IMA runtime measurement log")
@@ -26,3 +32,24 @@ func ReportIMALog(imaLog *api.ErrorBuffer) error {
 	imaLog.Data = encoder.EncodeAll(buf, make([]byte, 0, len(buf)))
 	return nil
 }
+
+// ReportIMAPolicy hashes the custom IMA policies present in the root file system
+func ReportIMAPolicy(policy *api.IMAPolicy, root common.Root) error {
+	log.Trace().Msg("ReportIMAPolicy()")
+
+	for _, file := range policyFiles {
+		if _, err := os.Stat(root.Path(file)); err != nil {
+			continue
+		}
+		if policy.Files == nil {
+			policy.Files = make(map[string]api.HashBlob)
+		}
+		policy.Files[file] = util.FileToHashBlob(root.Path(file))
+	}
+	if policy.Files == nil {
+		policy.Error = api.NoResponse
+		log.Debug().Msg("ima.ReportIMAPolicy(): no custom policy")
+	}
+
+	return nil
+}
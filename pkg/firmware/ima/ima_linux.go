@@ -1,7 +1,11 @@
 package ima
 
-import "os"
+import (
+	"os"
 
-func readIMALog() ([]byte, error) {
-	return os.ReadFile("/sys/kernel/security/ima/binary_runtime_measurements")
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+func readIMALog(root common.Root) ([]byte, error) {
+	return os.ReadFile(root.Path("/sys/kernel/security/ima/binary_runtime_measurements"))
 }
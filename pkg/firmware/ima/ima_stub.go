@@ -5,8 +5,10 @@ package ima
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readIMALog() ([]byte, error) {
+func readIMALog(root common.Root) ([]byte, error) {
 	return nil, errors.New("ima.readIMALog not implemented on " + runtime.GOOS)
 }
@@ -0,0 +1,207 @@
+package ipmi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/digitalocean/go-smbios/smbios"
+	"github.com/google/uuid"
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	netFnApp = 0x06
+
+	cmdGetDeviceID        = 0x01
+	cmdGetSelfTestResults = 0x04
+	cmdGetSystemGUID      = 0x37
+
+	completionOK   = 0x00
+	selfTestPassed = 0x55
+
+	smbiosIPMIDevice    = 38
+	smbiosHostInterface = 42
+)
+
+// IANA enterprise numbers of common BMC vendors
+var manufacturers = map[uint32]string{
+	2:     "IBM",
+	11:    "Hewlett-Packard",
+	343:   "Intel",
+	674:   "Dell",
+	7244:  "Quanta",
+	10876: "Super Micro",
+	19046: "Lenovo",
+}
+
+var interfaceTypes = map[uint8]string{1: "kcs", 2: "smic", 3: "bt", 4: "ssif"}
+
+// transport sends a request to the BMC and returns the response data starting with the completion code
+type transport interface {
+	Command(netFn, cmd uint8, data []byte) ([]byte, error)
+	Close() error
+}
+
+// openIPMI connects to the local BMC, replaced in tests
+var openIPMI = openDevice
+
+func command(t transport, netFn, cmd uint8, minLen int) ([]byte, error) {
+	resp, err := t.Command(netFn, cmd, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 0 {
+		return nil, errors.New("empty IPMI response")
+	}
+	if resp[0] != completionOK {
+		return nil, fmt.Errorf("IPMI command %#x failed with completion code %#x", cmd, resp[0])
+	}
+	if len(resp) < 1+minLen {
+		return nil, fmt.Errorf("truncated response to IPMI command %#x", cmd)
+	}
+	return resp[1:], nil
+}
+
+func bcd(b uint8) uint8 {
+	return b>>4*10 + b&0xf
+}
+
+func parseDeviceID(buf []byte) *api.IPMIDeviceID {
+	id := api.IPMIDeviceID{
+		DeviceID:         buf[0],
+		DeviceRevision:   buf[1] & 0xf,
+		FirmwareRevision: fmt.Sprintf("%d.%02d", buf[2]&0x7f, bcd(buf[3])),
+		IPMIVersion:      fmt.Sprintf("%d.%d", buf[4]&0xf, buf[4]>>4),
+		ManufacturerID:   (uint32(buf[6]) | uint32(buf[7])<<8 | uint32(buf[8])<<16) & 0xfffff,
+		ProductID:        uint16(buf[9]) | uint16(buf[10])<<8,
+	}
+	id.Manufacturer = manufacturers[id.ManufacturerID]
+	if len(buf) >= 15 {
+		id.AuxFirmware = buf[11:15]
+	}
+	return &id
+}
+
+// parseGUID decodes a GUID in the SMBIOS byte order with the first three fields in little endian
+func parseGUID(buf []byte) string {
+	var id uuid.UUID
+	copy(id[:], buf[:16])
+	id[0], id[1], id[2], id[3] = id[3], id[2], id[1], id[0]
+	id[4], id[5] = id[5], id[4]
+	id[6], id[7] = id[7], id[6]
+	return id.String()
+}
+
+func reportIPMI(bmc *api.BMC) error {
+	dev, err := openIPMI()
+	if err != nil {
+		apiErr := common.ServeApiError(common.MapFSErrors(err))
+		bmc.DeviceIDErr, bmc.SystemGUIDErr, bmc.SelfTestErr = apiErr, apiErr, apiErr
+		return err
+	}
+	defer dev.Close()
+
+	check := func(err error, item string, errOut *api.FirmwareError) {
+		if err != nil {
+			log.Debug().Err(err).Str("item", item).Msg("ipmi.ReportBMC()")
+			*errOut = common.ServeApiError(common.MapFSErrors(err))
+		}
+	}
+
+	var devErr error
+	if buf, err := command(dev, netFnApp, cmdGetDeviceID, 11); err == nil {
+		bmc.DeviceID = parseDeviceID(buf)
+	} else {
+		devErr = err
+		check(err, "device id", &bmc.DeviceIDErr)
+	}
+	if buf, err := command(dev, netFnApp, cmdGetSystemGUID, 16); err == nil {
+		bmc.SystemGUID = parseGUID(buf)
+	} else {
+		check(err, "system guid", &bmc.SystemGUIDErr)
+	}
+	if buf, err := command(dev, netFnApp, cmdGetSelfTestResults, 2); err == nil {
+		bmc.SelfTest = &api.IPMISelfTest{Passed: buf[0] == selfTestPassed, Result: buf[0], Detail: buf[1]}
+	} else {
+		check(err, "self test", &bmc.SelfTestErr)
+	}
+
+	return devErr
+}
+
+// reportSMBIOS decodes the IPMI Device Information and Management Controller Host Interface records
+func reportSMBIOS(bmc *api.BMC, table *api.HashBlob) error {
+	if table.Error != api.NoError {
+		bmc.SMBIOSErr = table.Error
+		return errors.New("no SMBIOS tables")
+	}
+
+	structs, err := smbios.NewDecoder(bytes.NewReader(table.Data)).Decode()
+	if err != nil {
+		bmc.SMBIOSErr = common.ServeApiError(common.MapFSErrors(err))
+		return err
+	}
+
+	for _, s := range structs {
+		buf := s.Formatted
+		switch s.Header.Type {
+		case smbiosIPMIDevice:
+			if len(buf) < 12 {
+				continue
+			}
+			typ, ok := interfaceTypes[buf[0]]
+			if !ok {
+				typ = fmt.Sprintf("unknown (%d)", buf[0])
+			}
+			var base uint64
+			for i := 7; i >= 0; i-- {
+				base = base<<8 | uint64(buf[4+i])
+			}
+			bmc.Interfaces = append(bmc.Interfaces, api.IPMIInterface{
+				Type:         typ,
+				SpecRevision: fmt.Sprintf("%d.%d", buf[1]>>4, buf[1]&0xf),
+				BaseAddress:  base,
+			})
+
+		case smbiosHostInterface:
+			if len(buf) < 2 || 2+int(buf[1]) >= len(buf) {
+				continue
+			}
+			hi := api.BMCHostInterface{Type: buf[0]}
+			// protocol records follow the interface specific data
+			rest := buf[2+int(buf[1]):]
+			n := int(rest[0])
+			for rest = rest[1:]; n > 0 && len(rest) >= 2 && 2+int(rest[1]) <= len(rest); n-- {
+				hi.Protocols = append(hi.Protocols, rest[0])
+				rest = rest[2+int(rest[1]):]
+			}
+			bmc.HostInterfaces = append(bmc.HostInterfaces, hi)
+		}
+	}
+	return nil
+}
+
+// ReportBMC queries the BMC for its identity and health and adds the description of the BMC interfaces from the
+// SMBIOS tables. It only fails if neither is available.
+func ReportBMC(bmc *api.BMC, smbiosTable *api.HashBlob) error {
+	log.Trace().Msg("ReportBMC()")
+
+	if err := reportSMBIOS(bmc, smbiosTable); err != nil {
+		log.Debug().Err(err).Msg("ipmi.ReportBMC() smbios")
+	}
+	err := reportIPMI(bmc)
+	if err != nil {
+		log.Debug().Err(err).Msg("ipmi.ReportBMC()")
+		// most machines without IPMI device have no BMC at all
+		if len(bmc.Interfaces) > 0 {
+			log.Warn().Msg("Failed to query the BMC")
+		} else if len(bmc.HostInterfaces) == 0 {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package ipmi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	ipmiDevice = "/dev/ipmi0"
+
+	ipmiIocMagic                = 'i'
+	ipmiSystemInterfaceAddrType = 0x0c
+	ipmiBMCChannel              = 0x0f
+	ipmiResponseRecvType        = 1
+	ipmiMaxMsgLength            = 272
+	ipmiTimeout                 = 5 * time.Second
+)
+
+// structs from linux/ipmi.h, pointers are uintptr to keep the C layout
+type ipmiSystemInterfaceAddr struct {
+	AddrType int32
+	Channel  int16
+	LUN      uint8
+}
+
+type ipmiMsg struct {
+	NetFn   uint8
+	Cmd     uint8
+	DataLen uint16
+	Data    uintptr
+}
+
+type ipmiReq struct {
+	Addr    uintptr
+	AddrLen uint32
+	MsgID   int
+	Msg     ipmiMsg
+}
+
+type ipmiRecv struct {
+	RecvType int32
+	Addr     uintptr
+	AddrLen  uint32
+	MsgID    int
+	Msg      ipmiMsg
+}
+
+var (
+	// _IOR(IPMI_IOC_MAGIC, 13, struct ipmi_req) and _IOWR(IPMI_IOC_MAGIC, 11, struct ipmi_recv)
+	ipmiCtlSendCommand     = 2<<30 | unsafe.Sizeof(ipmiReq{})<<16 | ipmiIocMagic<<8 | 13
+	ipmiCtlReceiveMsgTrunc = 3<<30 | unsafe.Sizeof(ipmiRecv{})<<16 | ipmiIocMagic<<8 | 11
+)
+
+// device talks to the BMC through the kernel IPMI message handler
+type device struct {
+	fd    int
+	msgID int
+}
+
+func openDevice() (transport, error) {
+	fd, err := syscall.Open(ipmiDevice, os.O_RDWR, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: ipmiDevice, Err: err}
+	}
+	return &device{fd: fd}, nil
+}
+
+func (d *device) Close() error {
+	return syscall.Close(d.fd)
+}
+
+var (
+	// replaced in tests
+	ioctl = func(fd int, req uintptr, arg unsafe.Pointer) error {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+			return errno
+		}
+		return nil
+	}
+	waitReadable = func(fd int, timeout time.Duration) error {
+		var r syscall.FdSet
+		bits := int(unsafe.Sizeof(r.Bits[0])) * 8
+		r.Bits[fd/bits] |= 1 << (fd % bits)
+		tv := syscall.NsecToTimeval(int64(timeout))
+		n, err := syscall.Select(fd+1, &r, nil, nil, &tv)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return os.ErrDeadlineExceeded
+		}
+		return nil
+	}
+)
+
+func (d *device) Command(netFn, cmd uint8, data []byte) ([]byte, error) {
+	d.msgID++
+	addr := ipmiSystemInterfaceAddr{AddrType: ipmiSystemInterfaceAddrType, Channel: ipmiBMCChannel}
+	req := ipmiReq{
+		Addr:    uintptr(unsafe.Pointer(&addr)),
+		AddrLen: uint32(unsafe.Sizeof(addr)),
+		MsgID:   d.msgID,
+		Msg:     ipmiMsg{NetFn: netFn, Cmd: cmd, DataLen: uint16(len(data))},
+	}
+	if len(data) > 0 {
+		req.Msg.Data = uintptr(unsafe.Pointer(&data[0]))
+	}
+	err := ioctl(d.fd, ipmiCtlSendCommand, unsafe.Pointer(&req))
+	runtime.KeepAlive(&addr)
+	runtime.KeepAlive(data)
+	if err != nil {
+		return nil, fmt.Errorf("IPMICTL_SEND_COMMAND: %w", err)
+	}
+
+	// skip responses to earlier requests that timed out
+	deadline := time.Now().Add(ipmiTimeout)
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return nil, os.ErrDeadlineExceeded
+		}
+		if err := waitReadable(d.fd, timeout); err != nil {
+			return nil, err
+		}
+
+		var recvAddr ipmiSystemInterfaceAddr
+		buf := make([]byte, ipmiMaxMsgLength)
+		recv := ipmiRecv{
+			Addr:    uintptr(unsafe.Pointer(&recvAddr)),
+			AddrLen: uint32(unsafe.Sizeof(recvAddr)),
+			Msg:     ipmiMsg{Data: uintptr(unsafe.Pointer(&buf[0])), DataLen: uint16(len(buf))},
+		}
+		err := ioctl(d.fd, ipmiCtlReceiveMsgTrunc, unsafe.Pointer(&recv))
+		runtime.KeepAlive(&recvAddr)
+		runtime.KeepAlive(buf)
+		if err != nil && !errors.Is(err, syscall.EMSGSIZE) {
+			return nil, fmt.Errorf("IPMICTL_RECEIVE_MSG_TRUNC: %w", err)
+		}
+		if recv.RecvType == ipmiResponseRecvType && recv.MsgID == d.msgID {
+			return buf[:recv.Msg.DataLen], nil
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package ipmi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMsg struct {
+	recvType int32
+	msgID    int
+	data     []byte
+}
+
+// fakeDriver plays the kernel IPMI message handler behind the ioctl and select calls
+type fakeDriver struct {
+	t        *testing.T
+	requests []ipmiMsg
+	reqData  [][]byte
+	queue    []fakeMsg
+	response []byte // answer to each request, none if nil
+	sendErr  error
+}
+
+// pointer reinterprets the uintptr fields of the ioctl structs without a uintptr to unsafe.Pointer conversion
+func pointer(p *uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(p))
+}
+
+func (f *fakeDriver) ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	switch req {
+	case ipmiCtlSendCommand:
+		r := (*ipmiReq)(arg)
+		addr := (*ipmiSystemInterfaceAddr)(pointer(&r.Addr))
+		assert.Equal(f.t, uint32(unsafe.Sizeof(*addr)), r.AddrLen)
+		assert.Equal(f.t, ipmiSystemInterfaceAddr{AddrType: ipmiSystemInterfaceAddrType, Channel: ipmiBMCChannel}, *addr)
+		var data []byte
+		if r.Msg.DataLen > 0 {
+			data = bytes.Clone(unsafe.Slice((*byte)(pointer(&r.Msg.Data)), r.Msg.DataLen))
+		}
+		f.requests = append(f.requests, r.Msg)
+		f.reqData = append(f.reqData, data)
+		if f.sendErr != nil {
+			return f.sendErr
+		}
+		if f.response != nil {
+			f.queue = append(f.queue, fakeMsg{recvType: ipmiResponseRecvType, msgID: r.MsgID, data: f.response})
+		}
+		return nil
+
+	case ipmiCtlReceiveMsgTrunc:
+		r := (*ipmiRecv)(arg)
+		if len(f.queue) == 0 {
+			return syscall.EAGAIN
+		}
+		msg := f.queue[0]
+		f.queue = f.queue[1:]
+		r.RecvType = msg.recvType
+		r.MsgID = msg.msgID
+		buf := unsafe.Slice((*byte)(pointer(&r.Msg.Data)), r.Msg.DataLen)
+		n := copy(buf, msg.data)
+		r.Msg.DataLen = uint16(n)
+		if n < len(msg.data) {
+			return syscall.EMSGSIZE
+		}
+		return nil
+
+	default:
+		f.t.Errorf("unexpected ioctl %#x", req)
+		return syscall.ENOTTY
+	}
+}
+
+func (f *fakeDriver) wait(fd int, timeout time.Duration) error {
+	if len(f.queue) == 0 {
+		return os.ErrDeadlineExceeded
+	}
+	return nil
+}
+
+func withDriver(t *testing.T, f *fakeDriver) *device {
+	oldIoctl, oldWait := ioctl, waitReadable
+	t.Cleanup(func() { ioctl, waitReadable = oldIoctl, oldWait })
+	f.t = t
+	ioctl, waitReadable = f.ioctl, f.wait
+	return &device{fd: 3}
+}
+
+func TestIoctlNumbers(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("values from a 64 bit kernel")
+	}
+	// IPMICTL_SEND_COMMAND and IPMICTL_RECEIVE_MSG_TRUNC as compiled from linux/ipmi.h
+	assert.Equal(t, uintptr(0x8028690d), ipmiCtlSendCommand)
+	assert.Equal(t, uintptr(0xc030690b), ipmiCtlReceiveMsgTrunc)
+}
+
+func TestDeviceCommand(t *testing.T) {
+	f := &fakeDriver{response: []byte{0x00, 0x20, 0x81}}
+	dev := withDriver(t, f)
+
+	// a late response to an earlier request and an event are skipped
+	f.queue = []fakeMsg{
+		{recvType: ipmiResponseRecvType, msgID: 0, data: []byte{0xc3}},
+		{recvType: 2, msgID: 1, data: []byte{0x00}},
+	}
+	resp, err := dev.Command(netFnApp, cmdGetDeviceID, []byte{0x01, 0x02})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x20, 0x81}, resp)
+	assert.Equal(t, uint8(netFnApp), f.requests[0].NetFn)
+	assert.Equal(t, uint8(cmdGetDeviceID), f.requests[0].Cmd)
+	assert.Equal(t, []byte{0x01, 0x02}, f.reqData[0])
+
+	// message ids increase per request
+	resp, err = dev.Command(netFnApp, cmdGetSystemGUID, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x20, 0x81}, resp)
+	assert.Equal(t, uint8(cmdGetSystemGUID), f.requests[1].Cmd)
+	assert.Equal(t, uintptr(0), f.requests[1].Data)
+	assert.Nil(t, f.reqData[1])
+	assert.Equal(t, 2, dev.msgID)
+}
+
+func TestDeviceCommandTruncated(t *testing.T) {
+	long := bytes.Repeat([]byte{0xaa}, ipmiMaxMsgLength+10)
+	dev := withDriver(t, &fakeDriver{response: long})
+
+	resp, err := dev.Command(netFnApp, cmdGetDeviceID, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, long[:ipmiMaxMsgLength], resp)
+}
+
+func TestDeviceCommandErrors(t *testing.T) {
+	f := &fakeDriver{sendErr: syscall.ENXIO}
+	dev := withDriver(t, f)
+	_, err := dev.Command(netFnApp, cmdGetDeviceID, nil)
+	assert.True(t, errors.Is(err, syscall.ENXIO))
+
+	// no response
+	dev = withDriver(t, &fakeDriver{})
+	_, err = dev.Command(netFnApp, cmdGetDeviceID, nil)
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+}
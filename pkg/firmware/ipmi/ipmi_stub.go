@@ -0,0 +1,12 @@
+//go:build !linux
+
+package ipmi
+
+import (
+	"errors"
+	"runtime"
+)
+
+func openDevice() (transport, error) {
+	return nil, errors.New("ipmi not implemented on " + runtime.GOOS)
+}
@@ -0,0 +1,108 @@
+package ipmi
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBMC answers IPMI commands with canned responses
+type fakeBMC struct {
+	responses map[uint8][]byte
+	closed    bool
+}
+
+func (f *fakeBMC) Command(netFn, cmd uint8, data []byte) ([]byte, error) {
+	if netFn != netFnApp {
+		return []byte{0xc1}, nil // invalid command
+	}
+	resp, ok := f.responses[cmd]
+	if !ok {
+		return nil, os.ErrDeadlineExceeded
+	}
+	return resp, nil
+}
+
+func (f *fakeBMC) Close() error {
+	f.closed = true
+	return nil
+}
+
+func withBMC(t *testing.T, bmc *fakeBMC, err error) {
+	old := openIPMI
+	t.Cleanup(func() { openIPMI = old })
+	openIPMI = func() (transport, error) {
+		if err != nil {
+			return nil, err
+		}
+		return bmc, nil
+	}
+}
+
+// testSMBIOS builds a table with a system information, an IPMI device and a Redfish host interface record
+func testSMBIOS() []byte {
+	var buf []byte
+	buf = append(buf, 1, 8, 1, 0, 1, 2, 3, 4, 'V', 'e', 'n', 'd', 'o', 'r', 0, 0)
+	buf = append(buf, 38, 18, 2, 0, 1, 0x20, 0x20, 0xff, 0xa3, 0x0c, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	buf = append(buf, 42, 14, 3, 0, 0x40, 3, 2, 0x10, 0x0b, 1, 4, 2, 0xaa, 0xbb, 0, 0)
+	buf = append(buf, 127, 4, 4, 0, 0, 0)
+	return buf
+}
+
+func TestReportBMC(t *testing.T) {
+	bmc := &fakeBMC{responses: map[uint8][]byte{
+		cmdGetDeviceID: {0x00, 0x20, 0x81, 0x07, 0x10, 0x02, 0xbf, 0xa2, 0x02, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+		cmdGetSystemGUID: {0x00, 0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd,
+			0xee, 0xff},
+		cmdGetSelfTestResults: {0x00, 0x57, 0x04},
+	}}
+	withBMC(t, bmc, nil)
+
+	var report api.BMC
+	assert.NoError(t, ReportBMC(&report, &api.HashBlob{Data: testSMBIOS()}))
+	assert.True(t, bmc.closed)
+
+	assert.Equal(t, &api.IPMIDeviceID{
+		DeviceID:         0x20,
+		DeviceRevision:   1,
+		FirmwareRevision: "7.10",
+		AuxFirmware:      []byte{0, 0, 0, 0},
+		IPMIVersion:      "2.0",
+		ManufacturerID:   674,
+		Manufacturer:     "Dell",
+		ProductID:        0x100,
+	}, report.DeviceID)
+	assert.Equal(t, "00112233-4455-6677-8899-aabbccddeeff", report.SystemGUID)
+	assert.Equal(t, &api.IPMISelfTest{Result: 0x57, Detail: 0x04}, report.SelfTest)
+	assert.Equal(t, []api.IPMIInterface{{Type: "kcs", SpecRevision: "2.0", BaseAddress: 0xca3}}, report.Interfaces)
+	assert.Equal(t, []api.BMCHostInterface{{Type: 0x40, Protocols: []uint8{4}}}, report.HostInterfaces)
+	assert.Empty(t, report.SMBIOSErr)
+}
+
+func TestReportBMCErrors(t *testing.T) {
+	// BMC that doesn't implement Get System GUID and hangs on the self test
+	withBMC(t, &fakeBMC{responses: map[uint8][]byte{
+		cmdGetDeviceID:   {0x00, 0x20, 0x01, 0x02, 0x51, 0x02, 0xbf, 0x57, 0x01, 0x00, 0x34, 0x12},
+		cmdGetSystemGUID: {0xc1},
+	}}, nil)
+
+	var report api.BMC
+	assert.NoError(t, ReportBMC(&report, &api.HashBlob{Error: api.DeniedByPolicy}))
+	assert.Equal(t, "2.51", report.DeviceID.FirmwareRevision)
+	assert.Equal(t, "Intel", report.DeviceID.Manufacturer)
+	assert.Nil(t, report.DeviceID.AuxFirmware)
+	assert.Equal(t, api.UnknownError, report.SystemGUIDErr)
+	assert.Equal(t, api.NoResponse, report.SelfTestErr)
+	assert.Equal(t, api.DeniedByPolicy, report.SMBIOSErr)
+
+	// no BMC at all
+	withBMC(t, nil, &os.PathError{Op: "open", Path: "/dev/ipmi0", Err: os.ErrNotExist})
+	report = api.BMC{}
+	err := ReportBMC(&report, &api.HashBlob{Data: testSMBIOS()[:16]})
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Equal(t, api.NoResponse, report.DeviceIDErr)
+	assert.Empty(t, report.Interfaces)
+}
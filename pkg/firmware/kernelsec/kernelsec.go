@@ -0,0 +1,47 @@
+package kernelsec
+
+import (
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+// ReportKernelSecurity reports the security relevant configuration of the running kernel. Each item carries its own
+// error, the function only fails if none of them could be read.
+func ReportKernelSecurity(ks *api.KernelSecurity, root common.Root) error {
+	log.Trace().Msg("ReportKernelSecurity()")
+
+	probes := []struct {
+		item   string
+		errOut *api.FirmwareError
+		read   func() error
+	}{
+		{"lockdown", &ks.LockdownErr, func() (err error) { ks.Lockdown, err = readLockdown(root); return }},
+		{"lsm", &ks.LSMsErr, func() (err error) { ks.LSMs, err = readLSMs(root); return }},
+		{"selinux", &ks.SELinuxErr, func() (err error) { ks.SELinux, err = readSELinux(root); return }},
+		{"apparmor", &ks.AppArmorErr, func() (err error) { ks.AppArmor, ks.AppArmorProfiles, err = readAppArmor(root); return }},
+		{"vulnerabilities", &ks.VulnerabilitiesErr, func() (err error) { ks.Vulnerabilities, err = readVulnerabilities(root); return }},
+		{"sig_enforce", &ks.ModuleSigEnforceErr, func() (err error) { ks.ModuleSigEnforce, err = readModuleSigEnforce(root); return }},
+		{"tainted", &ks.TaintErr, func() (err error) { ks.Taint, err = readTaint(root); return }},
+		{"iommu", &ks.IOMMUErr, func() (err error) { ks.IOMMU, err = readIOMMU(root); return }},
+		{"iommu_dma_protection", &ks.DMAProtectionErr, func() (err error) { ks.DMAProtection, err = readDMAProtection(root); return }},
+	}
+
+	var lastErr error
+	failed := 0
+	for _, p := range probes {
+		if err := p.read(); err != nil {
+			log.Debug().Err(err).Str("item", p.item).Msg("kernelsec.ReportKernelSecurity()")
+			*p.errOut = common.ServeApiError(common.MapFSErrors(err))
+			lastErr = err
+			failed++
+		}
+	}
+
+	if failed == len(probes) {
+		log.Warn().Msg("Failed to read kernel security settings")
+		return lastErr
+	}
+
+	return nil
+}
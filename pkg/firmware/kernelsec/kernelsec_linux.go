@@ -0,0 +1,173 @@
+package kernelsec
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+var (
+	sysfs  = "/sys"
+	procfs = "/proc"
+)
+
+func readString(path string) (string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+// readLockdown returns the active lockdown mode, the file lists all modes with the active one in brackets
+func readLockdown(root common.Root) (string, error) {
+	str, err := readString(filepath.Join(root.Path(sysfs), "kernel", "security", "lockdown"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, mode := range strings.Fields(str) {
+		if strings.HasPrefix(mode, "[") && strings.HasSuffix(mode, "]") {
+			return strings.Trim(mode, "[]"), nil
+		}
+	}
+
+	return "", errors.New("no active lockdown mode")
+}
+
+func readLSMs(root common.Root) ([]string, error) {
+	str, err := readString(filepath.Join(root.Path(sysfs), "kernel", "security", "lsm"))
+	if err != nil {
+		return nil, err
+	}
+	if str == "" {
+		return nil, nil
+	}
+
+	return strings.Split(str, ","), nil
+}
+
+func readSELinux(root common.Root) (string, error) {
+	str, err := readString(filepath.Join(root.Path(sysfs), "fs", "selinux", "enforce"))
+	if errors.Is(err, os.ErrNotExist) {
+		// selinuxfs is only mounted if SELinux is active
+		return "disabled", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if str == "1" {
+		return "enforcing", nil
+	}
+	return "permissive", nil
+}
+
+// readAppArmor returns whether AppArmor is enabled and counts the loaded profiles by mode
+func readAppArmor(root common.Root) (string, map[string]int, error) {
+	str, err := readString(filepath.Join(root.Path(sysfs), "module", "apparmor", "parameters", "enabled"))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && str != "Y") {
+		return "disabled", nil, nil
+	} else if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.Open(filepath.Join(root.Path(sysfs), "kernel", "security", "apparmor", "profiles"))
+	if err != nil {
+		return "enabled", nil, err
+	}
+	defer f.Close()
+
+	// each line looks like "/usr/bin/man (enforce)"
+	profiles := make(map[string]int)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.LastIndex(line, " ("); i >= 0 && strings.HasSuffix(line, ")") {
+			profiles[line[i+2:len(line)-1]]++
+		}
+	}
+
+	return "enabled", profiles, sc.Err()
+}
+
+func readVulnerabilities(root common.Root) (map[string]string, error) {
+	dir := filepath.Join(root.Path(sysfs), "devices", "system", "cpu", "vulnerabilities")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	vulns := make(map[string]string)
+	for _, e := range entries {
+		if str, err := readString(filepath.Join(dir, e.Name())); err == nil {
+			vulns[e.Name()] = str
+		}
+	}
+
+	return vulns, nil
+}
+
+func readModuleSigEnforce(root common.Root) (*bool, error) {
+	str, err := readString(filepath.Join(root.Path(sysfs), "module", "module", "parameters", "sig_enforce"))
+	if errors.Is(err, os.ErrNotExist) {
+		// the parameter is missing if the kernel is built without module signing support
+		enforced := false
+		return &enforced, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	enforced := str == "Y"
+	return &enforced, nil
+}
+
+func readTaint(root common.Root) (*uint64, error) {
+	str, err := readString(filepath.Join(root.Path(procfs), "sys", "kernel", "tainted"))
+	if err != nil {
+		return nil, err
+	}
+
+	taint, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &taint, nil
+}
+
+// readIOMMU checks whether any IOMMU has been registered for DMA remapping
+func readIOMMU(root common.Root) (*bool, error) {
+	entries, err := os.ReadDir(filepath.Join(root.Path(sysfs), "class", "iommu"))
+	if err != nil {
+		return nil, err
+	}
+
+	active := len(entries) > 0
+	return &active, nil
+}
+
+// readDMAProtection checks whether the Thunderbolt domains use the IOMMU to protect against DMA from external devices
+func readDMAProtection(root common.Root) (*bool, error) {
+	files, err := filepath.Glob(filepath.Join(root.Path(sysfs), "bus", "thunderbolt", "devices", "domain*", "iommu_dma_protection"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	protected := true
+	for _, file := range files {
+		str, err := readString(file)
+		if err != nil {
+			return nil, err
+		}
+		protected = protected && str == "1"
+	}
+
+	return &protected, nil
+}
@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+package kernelsec
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportKernelSecurity(t *testing.T) {
+	oldSysfs, oldProcfs := sysfs, procfs
+	t.Cleanup(func() { sysfs, procfs = oldSysfs, oldProcfs })
+	sysfs = t.TempDir()
+	procfs = t.TempDir()
+
+	commontest.WriteFile(t, filepath.Join(sysfs, "kernel/security/lockdown"), "none [integrity] confidentiality\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "kernel/security/lsm"), "lockdown,capability,yama,apparmor\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "module/apparmor/parameters/enabled"), "Y\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "kernel/security/apparmor/profiles"), "/usr/bin/man (enforce)\nnvidia_modprobe (enforce)\nfirefox (complain)\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "devices/system/cpu/vulnerabilities/meltdown"), "Not affected\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "devices/system/cpu/vulnerabilities/spectre_v2"), "Mitigation: Enhanced IBRS\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "module/module/parameters/sig_enforce"), "Y\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "class/iommu/dmar0/uevent"), "")
+	commontest.WriteFile(t, filepath.Join(procfs, "sys/kernel/tainted"), "4096\n")
+
+	var ks api.KernelSecurity
+	assert.NoError(t, ReportKernelSecurity(&ks, ""))
+
+	assert.Equal(t, "integrity", ks.Lockdown)
+	assert.Equal(t, []string{"lockdown", "capability", "yama", "apparmor"}, ks.LSMs)
+	assert.Equal(t, "disabled", ks.SELinux)
+	assert.Equal(t, "enabled", ks.AppArmor)
+	assert.Equal(t, map[string]int{"enforce": 2, "complain": 1}, ks.AppArmorProfiles)
+	assert.Equal(t, map[string]string{"meltdown": "Not affected", "spectre_v2": "Mitigation: Enhanced IBRS"}, ks.Vulnerabilities)
+	assert.True(t, *ks.ModuleSigEnforce)
+	assert.Equal(t, uint64(4096), *ks.Taint)
+	assert.True(t, *ks.IOMMU)
+	assert.Nil(t, ks.DMAProtection)
+	assert.Equal(t, api.NoResponse, ks.DMAProtectionErr)
+	assert.Equal(t, api.NoError, ks.LockdownErr)
+}
+
+func TestReportKernelSecurityNoSysfs(t *testing.T) {
+	oldSysfs, oldProcfs := sysfs, procfs
+	t.Cleanup(func() { sysfs, procfs = oldSysfs, oldProcfs })
+	sysfs = filepath.Join(t.TempDir(), "missing")
+	procfs = filepath.Join(t.TempDir(), "missing")
+
+	var ks api.KernelSecurity
+	assert.NoError(t, ReportKernelSecurity(&ks, ""))
+	assert.Equal(t, api.NoResponse, ks.LockdownErr)
+	assert.Equal(t, api.NoResponse, ks.TaintErr)
+	// absence of these means the feature is off
+	assert.Equal(t, "disabled", ks.SELinux)
+	assert.Equal(t, "disabled", ks.AppArmor)
+	assert.False(t, *ks.ModuleSigEnforce)
+}
+
+func TestReportKernelSecurityRoot(t *testing.T) {
+	root := t.TempDir()
+
+	commontest.WriteFile(t, filepath.Join(root, "sys/kernel/security/lockdown"), "[none] integrity confidentiality\n")
+	commontest.WriteFile(t, filepath.Join(root, "proc/sys/kernel/tainted"), "0\n")
+
+	var ks api.KernelSecurity
+	assert.NoError(t, ReportKernelSecurity(&ks, common.Root(root)))
+	assert.Equal(t, "none", ks.Lockdown)
+	assert.Equal(t, uint64(0), *ks.Taint)
+}
@@ -0,0 +1,48 @@
+//go:build !linux
+
+package kernelsec
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+var errNotImplemented = errors.New("kernelsec not implemented on " + runtime.GOOS)
+
+func readLockdown(root common.Root) (string, error) {
+	return "", errNotImplemented
+}
+
+func readLSMs(root common.Root) ([]string, error) {
+	return nil, errNotImplemented
+}
+
+func readSELinux(root common.Root) (string, error) {
+	return "", errNotImplemented
+}
+
+func readAppArmor(root common.Root) (string, map[string]int, error) {
+	return "", nil, errNotImplemented
+}
+
+func readVulnerabilities(root common.Root) (map[string]string, error) {
+	return nil, errNotImplemented
+}
+
+func readModuleSigEnforce(root common.Root) (*bool, error) {
+	return nil, errNotImplemented
+}
+
+func readTaint(root common.Root) (*uint64, error) {
+	return nil, errNotImplemented
+}
+
+func readIOMMU(root common.Root) (*bool, error) {
+	return nil, errNotImplemented
+}
+
+func readDMAProtection(root common.Root) (*bool, error) {
+	return nil, errNotImplemented
+}
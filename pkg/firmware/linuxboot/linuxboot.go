@@ -0,0 +1,65 @@
+package linuxboot
+
+import (
+	"os"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+// ReportLinuxBoot reports the running kernel's release and command line along with the kernel and initramfs images
+// it was likely booted from. The images are searched in /boot and on the ESP, which is mounted if mountESP is true.
+func ReportLinuxBoot(linuxBoot *api.LinuxBoot, mountESP bool, root common.Root) error {
+	log.Trace().Msg("ReportLinuxBoot()")
+
+	release, cmdline, err := readRunningKernel(root)
+	if err != nil {
+		linuxBoot.Error = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("linuxboot.ReportLinuxBoot()")
+		log.Warn().Msg("Failed to read running Linux kernel information")
+		return err
+	}
+	linuxBoot.Release = release
+	linuxBoot.Cmdline = cmdline
+
+	kernels, initramfs := findBootImages(release, cmdline, mountESP, root)
+	if len(kernels) == 0 && len(initramfs) == 0 {
+		err = os.ErrNotExist
+		linuxBoot.Error = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("linuxboot.ReportLinuxBoot()")
+		log.Warn().Msg("Failed to find Linux kernel and initramfs images")
+		return err
+	}
+	if len(kernels) > 0 {
+		linuxBoot.Kernels = kernels
+	}
+	if len(initramfs) > 0 {
+		linuxBoot.Initramfs = initramfs
+	}
+
+	return nil
+}
+
+// ReportOfflineLinuxBoot reports all kernel and initramfs images in /boot and on the ESP of a powered-off machine. The
+// release and command line of the kernel it last ran are unknown.
+func ReportOfflineLinuxBoot(linuxBoot *api.LinuxBoot, root common.Root) error {
+	log.Trace().Msg("ReportOfflineLinuxBoot()")
+
+	kernels, initramfs := findOfflineBootImages(root)
+	if len(kernels) == 0 && len(initramfs) == 0 {
+		err := os.ErrNotExist
+		linuxBoot.Error = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("linuxboot.ReportOfflineLinuxBoot()")
+		log.Warn().Msg("Failed to find Linux kernel and initramfs images")
+		return err
+	}
+	if len(kernels) > 0 {
+		linuxBoot.Kernels = kernels
+	}
+	if len(initramfs) > 0 {
+		linuxBoot.Initramfs = initramfs
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package linuxboot
+
+import (
+	"bufio"
+	"bytes"
+	"debug/pe"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/bootapps"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+const espPrefix = "esp:"
+
+var (
+	procCmdline   = "/proc/cmdline"
+	procOSRelease = "/proc/sys/kernel/osrelease"
+	rootDir       = "/"
+	bootDir       = "/boot"
+
+	// naming schemes used by distributions for images in /boot, the placeholder is the kernel release or flavor
+	kernelNames    = []string{"vmlinuz-%s", "vmlinux-%s", "Image-%s", "kernel-%s"}
+	initramfsNames = []string{"initrd.img-%s", "initramfs-%s.img", "initrd-%s", "initrd-%s.img", "initramfs-%s"}
+)
+
+func readRunningKernel(root common.Root) (string, string, error) {
+	release, err := os.ReadFile(root.Path(procOSRelease))
+	if err != nil {
+		return "", "", err
+	}
+	cmdline, err := os.ReadFile(root.Path(procCmdline))
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(string(release)), strings.TrimSpace(string(cmdline)), nil
+}
+
+func findBootImages(release, cmdline string, mountESP bool, root common.Root) (map[string]api.HashBlob, map[string]api.HashBlob) {
+	img := newImages()
+	img.addBootDir(release, cmdline, root)
+	// /boot may be an XBOOTLDR partition holding boot loader spec entries
+	img.addBootLoaderSpec(root.Path(bootDir), bootDir, release)
+
+	partUUID, err := bootapps.WithEfiSystemPartition(mountESP, root, func(esp string) error {
+		img.addBootLoaderSpec(esp, espPrefix, release)
+		img.addUKIs(esp, espPrefix, release)
+		img.addInitrdParams(esp, espPrefix, cmdline)
+		// hash while the ESP is still mounted
+		img.hash()
+		return nil
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("linuxboot: can't search ESP for kernel images")
+		img.hash()
+	} else {
+		log.Debug().Msgf("linuxboot: searched ESP %s for kernel images", partUUID)
+	}
+
+	return img.kernelBlobs, img.initramfsBlobs
+}
+
+// findOfflineBootImages looks for the images of all installed kernels since the running one is unknown
+func findOfflineBootImages(root common.Root) (map[string]api.HashBlob, map[string]api.HashBlob) {
+	img := newImages()
+	img.addAllBootDir(root)
+	img.addBootLoaderSpec(root.Path(bootDir), bootDir, "")
+
+	err := bootapps.WithOfflineEfiSystemPartition(root, func(esp string) error {
+		img.addBootLoaderSpec(esp, espPrefix, "")
+		img.addUKIs(esp, espPrefix, "")
+		return nil
+	})
+	if err != nil {
+		log.Debug().Err(err).Msg("linuxboot: can't search ESP for kernel images")
+	}
+	img.hash()
+
+	return img.kernelBlobs, img.initramfsBlobs
+}
+
+// images collects report keys mapped to file paths until they are hashed
+type images struct {
+	kernels        map[string]string
+	initramfs      map[string]string
+	kernelBlobs    map[string]api.HashBlob
+	initramfsBlobs map[string]api.HashBlob
+}
+
+func newImages() *images {
+	return &images{
+		kernels:        make(map[string]string),
+		initramfs:      make(map[string]string),
+		kernelBlobs:    make(map[string]api.HashBlob),
+		initramfsBlobs: make(map[string]api.HashBlob),
+	}
+}
+
+// add records root/rel under the key prefix+rel if it is a regular file
+func add(m map[string]string, root, prefix, rel string) bool {
+	rel = "/" + strings.TrimLeft(filepath.ToSlash(rel), "/")
+	path := filepath.Join(root, rel)
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+
+	m[prefix+rel] = path
+	return true
+}
+
+// hash moves all collected files into the blob maps
+func (img *images) hash() {
+	for key, path := range img.kernels {
+		img.kernelBlobs[key] = util.FileToHashBlob(path)
+	}
+	for key, path := range img.initramfs {
+		img.initramfsBlobs[key] = util.FileToHashBlob(path)
+	}
+	img.kernels = make(map[string]string)
+	img.initramfs = make(map[string]string)
+}
+
+// addBootDir looks for images in /boot named after the kernel release or the image GRUB booted
+func (img *images) addBootDir(release, cmdline string, root common.Root) {
+	suffixes := []string{release}
+
+	if bootImage := cmdlineParam(cmdline, "BOOT_IMAGE"); len(bootImage) > 0 {
+		// GRUB prefixes the path with the device, e.g. (hd0,gpt2)/vmlinuz-linux
+		if i := strings.Index(bootImage[0], ")"); strings.HasPrefix(bootImage[0], "(") && i > 0 {
+			bootImage[0] = bootImage[0][i+1:]
+		}
+		// the path is relative to the partition /boot lives on
+		add(img.kernels, root.Path(rootDir), "", bootImage[0])
+		add(img.kernels, root.Path(bootDir), bootDir, bootImage[0])
+
+		// distributions that don't use the release in file names use a flavor like vmlinuz-linux-lts
+		base := filepath.Base(bootImage[0])
+		for _, name := range kernelNames {
+			if flavor, ok := strings.CutPrefix(base, strings.TrimSuffix(name, "%s")); ok && flavor != release {
+				suffixes = append(suffixes, flavor)
+			}
+		}
+	}
+
+	for _, suffix := range suffixes {
+		for _, name := range kernelNames {
+			add(img.kernels, root.Path(bootDir), bootDir, fmt.Sprintf(name, suffix))
+		}
+		for _, name := range initramfsNames {
+			add(img.initramfs, root.Path(bootDir), bootDir, fmt.Sprintf(name, suffix))
+		}
+	}
+}
+
+// addAllBootDir adds all images in /boot following one of the naming schemes
+func (img *images) addAllBootDir(root common.Root) {
+	dir := root.Path(bootDir)
+	for _, m := range []struct {
+		images map[string]string
+		names  []string
+	}{{img.kernels, kernelNames}, {img.initramfs, initramfsNames}} {
+		for _, name := range m.names {
+			files, _ := filepath.Glob(filepath.Join(dir, fmt.Sprintf(name, "*")))
+			for _, file := range files {
+				add(m.images, dir, bootDir, filepath.Base(file))
+			}
+		}
+	}
+}
+
+type loaderEntry struct {
+	Version string
+	Linux   string
+	EFI     string
+	Initrd  []string
+}
+
+// parseLoaderEntry reads a boot loader specification type #1 entry
+func parseLoaderEntry(buf []byte) loaderEntry {
+	var entry loaderEntry
+
+	sc := bufio.NewScanner(bytes.NewReader(buf))
+	for sc.Scan() {
+		key, value, _ := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "version":
+			entry.Version = value
+		case "linux":
+			entry.Linux = value
+		case "efi":
+			entry.EFI = value
+		case "initrd":
+			entry.Initrd = append(entry.Initrd, value)
+		}
+	}
+
+	return entry
+}
+
+// addBootLoaderSpec adds images referenced by systemd-boot entries for the running kernel release, all entries if release is empty
+func (img *images) addBootLoaderSpec(root, prefix, release string) {
+	files, _ := filepath.Glob(filepath.Join(root, "loader", "entries", "*.conf"))
+	for _, file := range files {
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			log.Debug().Err(err).Str("path", file).Msg("linuxboot: read loader entry")
+			continue
+		}
+
+		entry := parseLoaderEntry(buf)
+		if entry.Version != release && !strings.Contains(entry.Linux, release) && !strings.Contains(entry.EFI, release) {
+			continue
+		}
+		if entry.Linux != "" {
+			add(img.kernels, root, prefix, entry.Linux)
+		}
+		if entry.EFI != "" {
+			add(img.kernels, root, prefix, entry.EFI)
+		}
+		for _, initrd := range entry.Initrd {
+			add(img.initramfs, root, prefix, initrd)
+		}
+	}
+}
+
+// addUKIs adds boot loader specification type #2 unified kernel images built for the running kernel release, all of them
+// if release is empty
+func (img *images) addUKIs(root, prefix, release string) {
+	files, _ := filepath.Glob(filepath.Join(root, "EFI", "Linux", "*.efi"))
+	for _, file := range files {
+		if release != "" {
+			uname, err := ukiRelease(file)
+			if err != nil {
+				log.Debug().Err(err).Str("path", file).Msg("linuxboot: read UKI")
+				continue
+			}
+			if uname != release {
+				continue
+			}
+		}
+		rel, _ := filepath.Rel(root, file)
+		add(img.kernels, root, prefix, rel)
+	}
+}
+
+// ukiRelease returns the contents of the .uname section of a UKI
+func ukiRelease(file string) (string, error) {
+	f, err := pe.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sect := f.Section(".uname")
+	if sect == nil {
+		return "", nil
+	}
+	buf, err := sect.Data()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(buf), "\x00")), nil
+}
+
+// addInitrdParams adds initrds passed to the EFI stub via initrd= with paths relative to the ESP
+func (img *images) addInitrdParams(root, prefix, cmdline string) {
+	for _, initrd := range cmdlineParam(cmdline, "initrd") {
+		add(img.initramfs, root, prefix, strings.ReplaceAll(initrd, "\\", "/"))
+	}
+}
+
+// cmdlineParam returns the values of all occurrences of a kernel command line parameter
+func cmdlineParam(cmdline, name string) []string {
+	var values []string
+	for _, field := range strings.Fields(cmdline) {
+		if v, ok := strings.CutPrefix(field, name+"="); ok && v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
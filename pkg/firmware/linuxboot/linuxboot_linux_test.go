@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package linuxboot
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+)
+
+func keys(m map[string]string) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+func blobKeys(m map[string]api.HashBlob) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+func TestCmdlineParam(t *testing.T) {
+	cmdline := `initrd=\EFI\arch\amd-ucode.img initrd=\EFI\arch\initramfs-linux.img root=UUID=1234 rw quiet`
+	assert.Equal(t, []string{`\EFI\arch\amd-ucode.img`, `\EFI\arch\initramfs-linux.img`}, cmdlineParam(cmdline, "initrd"))
+	assert.Equal(t, []string{"UUID=1234"}, cmdlineParam(cmdline, "root"))
+	assert.Empty(t, cmdlineParam(cmdline, "BOOT_IMAGE"))
+}
+
+func TestAddBootDir(t *testing.T) {
+	rootDir = t.TempDir()
+	bootDir = filepath.Join(rootDir, "boot")
+
+	// Debian style with release in the file names
+	commontest.WriteFile(t, filepath.Join(bootDir, "vmlinuz-6.1.0-13-amd64"), "kernel")
+	commontest.WriteFile(t, filepath.Join(bootDir, "initrd.img-6.1.0-13-amd64"), "initrd")
+	commontest.WriteFile(t, filepath.Join(bootDir, "vmlinuz-6.1.0-12-amd64"), "old kernel")
+
+	img := newImages()
+	img.addBootDir("6.1.0-13-amd64", "BOOT_IMAGE=/boot/vmlinuz-6.1.0-13-amd64 root=/dev/sda1 ro", "")
+	assert.ElementsMatch(t, []string{"/boot/vmlinuz-6.1.0-13-amd64", bootDir + "/vmlinuz-6.1.0-13-amd64"}, keys(img.kernels))
+	assert.ElementsMatch(t, []string{bootDir + "/initrd.img-6.1.0-13-amd64"}, keys(img.initramfs))
+
+	// Arch style on a separate /boot partition with flavor in the file names
+	commontest.WriteFile(t, filepath.Join(bootDir, "vmlinuz-linux"), "kernel")
+	commontest.WriteFile(t, filepath.Join(bootDir, "initramfs-linux.img"), "initrd")
+
+	img = newImages()
+	img.addBootDir("6.5.9-arch2-1", "BOOT_IMAGE=(hd0,gpt1)/vmlinuz-linux root=UUID=1234 rw", "")
+	assert.ElementsMatch(t, []string{bootDir + "/vmlinuz-linux"}, keys(img.kernels))
+	assert.ElementsMatch(t, []string{bootDir + "/initramfs-linux.img"}, keys(img.initramfs))
+}
+
+func TestAddBootLoaderSpec(t *testing.T) {
+	esp := t.TempDir()
+	commontest.WriteFile(t, filepath.Join(esp, "loader", "entries", "fedora-6.5.6.conf"), `title Fedora Linux
+version 6.5.6-300.fc39.x86_64
+linux   /ab12/6.5.6-300.fc39.x86_64/linux
+initrd  /ab12/6.5.6-300.fc39.x86_64/initrd
+options root=UUID=1234 rw
+`)
+	commontest.WriteFile(t, filepath.Join(esp, "loader", "entries", "fedora-6.4.0.conf"), `version 6.4.0-100.fc39.x86_64
+linux   /ab12/6.4.0-100.fc39.x86_64/linux
+`)
+	commontest.WriteFile(t, filepath.Join(esp, "ab12", "6.5.6-300.fc39.x86_64", "linux"), "kernel")
+	commontest.WriteFile(t, filepath.Join(esp, "ab12", "6.5.6-300.fc39.x86_64", "initrd"), "initrd")
+	commontest.WriteFile(t, filepath.Join(esp, "ab12", "6.4.0-100.fc39.x86_64", "linux"), "old kernel")
+
+	img := newImages()
+	img.addBootLoaderSpec(esp, espPrefix, "6.5.6-300.fc39.x86_64")
+	assert.ElementsMatch(t, []string{"esp:/ab12/6.5.6-300.fc39.x86_64/linux"}, keys(img.kernels))
+	assert.ElementsMatch(t, []string{"esp:/ab12/6.5.6-300.fc39.x86_64/initrd"}, keys(img.initramfs))
+
+	img.hash()
+	assert.Empty(t, img.kernels)
+	assert.Len(t, img.kernelBlobs, 1)
+	assert.Len(t, img.kernelBlobs["esp:/ab12/6.5.6-300.fc39.x86_64/linux"].Sha256, 32)
+}
+
+func TestAddInitrdParams(t *testing.T) {
+	esp := t.TempDir()
+	commontest.WriteFile(t, filepath.Join(esp, "EFI", "arch", "initramfs-linux.img"), "initrd")
+
+	img := newImages()
+	img.addInitrdParams(esp, espPrefix, `initrd=\EFI\arch\initramfs-linux.img initrd=\EFI\arch\missing.img rw`)
+	assert.Equal(t, map[string]string{"esp:/EFI/arch/initramfs-linux.img": filepath.Join(esp, "EFI", "arch", "initramfs-linux.img")}, img.initramfs)
+}
+
+func TestFindOfflineBootImages(t *testing.T) {
+	rootDir, bootDir = "/", "/boot"
+	root := t.TempDir()
+
+	commontest.WriteFile(t, filepath.Join(root, "boot", "vmlinuz-6.1.0-13-amd64"), "kernel")
+	commontest.WriteFile(t, filepath.Join(root, "boot", "vmlinuz-6.1.0-12-amd64"), "old kernel")
+	commontest.WriteFile(t, filepath.Join(root, "boot", "initrd.img-6.1.0-13-amd64"), "initrd")
+	commontest.WriteFile(t, filepath.Join(root, "boot", "config-6.1.0-13-amd64"), "config")
+	commontest.WriteFile(t, filepath.Join(root, "boot", "efi", "EFI", "Linux", "arch-linux.efi"), "not a PE file")
+
+	kernels, initramfs := findOfflineBootImages(common.Root(root))
+	assert.ElementsMatch(t, []string{"/boot/vmlinuz-6.1.0-13-amd64", "/boot/vmlinuz-6.1.0-12-amd64", "esp:/EFI/Linux/arch-linux.efi"}, blobKeys(kernels))
+	assert.ElementsMatch(t, []string{"/boot/initrd.img-6.1.0-13-amd64"}, blobKeys(initramfs))
+}
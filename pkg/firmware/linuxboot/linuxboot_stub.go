@@ -0,0 +1,23 @@
+//go:build !linux
+
+package linuxboot
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+func readRunningKernel(root common.Root) (string, string, error) {
+	return "", "", errors.New("linuxboot.readRunningKernel not implemented on " + runtime.GOOS)
+}
+
+func findBootImages(release, cmdline string, mountESP bool, root common.Root) (map[string]api.HashBlob, map[string]api.HashBlob) {
+	return nil, nil
+}
+
+func findOfflineBootImages(root common.Root) (map[string]api.HashBlob, map[string]api.HashBlob) {
+	return nil, nil
+}
@@ -17,10 +17,10 @@ import (
 )
 
 // deprecated
-func ReportMACAddresses(macs *api.MACAddresses) error {
+func ReportMACAddresses(macs *api.MACAddresses, root common.Root) error {
 	log.Trace().Msg("ReportMACAddresses()")
 
-	m, err := readMACAddresses()
+	m, err := readMACAddresses(root)
 	if err != nil {
 		// on Windows the WMI calls return their own errors which are
 		// mostly of no interest and just map to err-unknown here
@@ -39,11 +39,11 @@ func ReportMACAddresses(macs *api.MACAddresses) error {
 	return nil
 }
 
-func ReportNICs(nics *api.NICList) error {
+func ReportNICs(nics *api.NICList, root common.Root) error {
 	log.Trace().Msg("ReportNICs()")
 
 	// get MAC addresses of (hopefully) non-virtual NICs
-	macs, err := readMACAddresses()
+	macs, err := readMACAddresses(root)
 	if err != nil {
 		// on Windows the WMI calls return their own errors which are
 		// mostly of no interest and just map to err-unknown here
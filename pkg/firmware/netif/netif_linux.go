@@ -4,9 +4,13 @@ import (
 	"net"
 	"path/filepath"
 	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readMACAddresses() ([]string, error) {
+const sysClassNet = "/sys/class/net"
+
+func readMACAddresses(root common.Root) ([]string, error) {
 	ifas, err := net.Interfaces()
 	if err != nil {
 		return nil, err
@@ -14,7 +18,7 @@ func readMACAddresses() ([]string, error) {
 	macAddrs := []string{}
 	for _, ifa := range ifas {
 		// exclude virtual interfaces
-		dp, err := filepath.EvalSymlinks("/sys/class/net/" + ifa.Name)
+		dp, err := filepath.EvalSymlinks(filepath.Join(root.Path(sysClassNet), ifa.Name))
 		if err == nil && strings.Contains(dp, "/devices/virtual/") {
 			continue
 		}
@@ -5,8 +5,10 @@ package netif
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readMACAddresses() ([]string, error) {
+func readMACAddresses(root common.Root) ([]string, error) {
 	return nil, errors.New("msr.ReadMACAddresses not implemented on " + runtime.GOOS)
 }
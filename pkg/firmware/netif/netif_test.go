@@ -11,7 +11,7 @@ func TestReportMacAddrs(t *testing.T) {
 		t.Skip("not implemented on OSX")
 	}
 
-	macAddrs, err := readMACAddresses()
+	macAddrs, err := readMACAddresses("")
 	if err != nil {
 		t.Fatal(err)
 	}
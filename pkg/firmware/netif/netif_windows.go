@@ -4,6 +4,8 @@ import (
 	"strings"
 
 	"github.com/yusufpapurcu/wmi"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
 const (
@@ -19,7 +21,7 @@ type wmiAdapterEntity struct {
 	PNPDeviceId *string
 }
 
-func readMACAddresses() ([]string, error) {
+func readMACAddresses(root common.Root) ([]string, error) {
 	var results []wmiAdapterEntity
 	if err := wmi.Query(wmiQuery, &results); err != nil {
 		return nil, err
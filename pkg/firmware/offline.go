@@ -0,0 +1,124 @@
+package firmware
+
+import (
+	"runtime"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/bootapps"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/epp"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/ima"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/linuxboot"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/osinfo"
+)
+
+// gatherOffline collects the boot chain and configuration from the file system of a powered-off machine mounted at
+// the root set in the options. Everything read from the hardware, the firmware or the running kernel is reported as
+// not implemented.
+func gatherOffline(request *api.Configuration, opts *Options, root common.Root) api.FirmwareProperties {
+	log.Trace().Msg("start gathering offline data")
+
+	var fwData api.FirmwareProperties
+
+	// live hardware and firmware
+	fwData.Flash.Error = api.NotImplemented
+	fwData.UEFIFirmware = &api.UEFIFirmware{Error: api.NotImplemented}
+	fwData.CPUIDLeafs = request.CPUIDLeafs
+	failAll(fwData.CPUIDLeafs, api.NotImplemented, func(v *api.CPUIDLeaf) *api.FirmwareError { return &v.Error })
+	fwData.MSRs = request.MSRs
+	failAll(fwData.MSRs, api.NotImplemented, func(v *api.MSR) *api.FirmwareError { return &v.Error })
+	fwData.MACAddresses.Error = api.NotImplemented
+	fwData.PCIConfigSpaces = request.PCIConfigSpaces
+	failAll(fwData.PCIConfigSpaces, api.NotImplemented, func(v *api.PCIConfigSpace) *api.FirmwareError { return &v.Error })
+	fwData.PCIDevices = &api.PCIDevices{Error: api.NotImplemented}
+	fwData.SEV = request.SEV
+	failAll(fwData.SEV, api.NotImplemented, func(v *api.SEVCommand) *api.FirmwareError { return &v.Error })
+	fwData.ACPI.Error = api.NotImplemented
+	fwData.SMBIOS.Error = api.NotImplemented
+	fwData.BMC = &api.BMC{
+		DeviceIDErr:   api.NotImplemented,
+		SystemGUIDErr: api.NotImplemented,
+		SelfTestErr:   api.NotImplemented,
+		SMBIOSErr:     api.NotImplemented,
+	}
+	fwData.TXTPublicSpace.Error = api.NotImplemented
+	fwData.UEFIVariables = request.UEFIVariables
+	failAll(fwData.UEFIVariables, api.NotImplemented, func(v *api.UEFIVariable) *api.FirmwareError { return &v.Error })
+	fwData.TPM2Properties = request.TPM2Properties
+	failAll(fwData.TPM2Properties, api.NotImplemented, func(v *api.TPM2Property) *api.FirmwareError { return &v.Error })
+	for _, nvIndex := range request.TPM2NVRAM {
+		fwData.TPM2NVRAM = append(fwData.TPM2NVRAM, api.TPM2NVIndex{Index: nvIndex, Error: api.NotImplemented})
+	}
+	fwData.ME = request.ME
+	failAll(fwData.ME, api.NotImplemented, func(v *api.MEClientCommands) *api.FirmwareError { return &v.Error })
+	fwData.MEStatus = &api.MEStatus{Error: api.NotImplemented}
+	fwData.SGX = &api.SGXInfo{Error: api.NotImplemented}
+	fwData.BootGuard = &api.BootGuard{Error: api.NotImplemented}
+	fwData.FlashProtection = &api.FlashProtection{Error: api.NotImplemented}
+	fwData.TDXEventLog = &api.HashBlob{Error: api.NotImplemented}
+	fwData.NICs = &api.NICList{Error: api.NotImplemented}
+	fwData.VTdRegisterSet.Error = api.NotImplemented
+	fwData.Memory.Error = api.NotImplemented
+
+	// Operating System information
+	if runtime.GOOS != "linux" {
+		fwData.OS.Error = api.NotImplemented
+	} else if opts.Enabled(CollectorOS) {
+		osinfo.ReportOSInfo(&fwData.OS, root)
+	} else {
+		fwData.OS.Error = api.DeniedByPolicy
+	}
+
+	// Agent information, this is the agent doing the collection
+	fwData.Agent = &api.Agent{}
+	if opts.Enabled(CollectorAgent) {
+		ReportAgentHash(fwData.Agent)
+	} else {
+		fwData.Agent.ImageSHA2.Error = api.DeniedByPolicy
+	}
+
+	// Endpoint protection software installed, none of it is running
+	if runtime.GOOS == "linux" && opts.Enabled(CollectorEPP) {
+		fwData.EPPInfo = new(api.EPPInfo)
+		epp.ReportOfflineEPP(fwData.EPPInfo, root)
+	}
+
+	// UEFI Boot Applications
+	fwData.BootApps = &api.BootApps{}
+	if opts.Enabled(CollectorBootApps) {
+		bootapps.ReportOfflineBootApps(fwData.BootApps, root)
+	} else {
+		fwData.BootApps.ImagesErr = api.DeniedByPolicy
+	}
+
+	if runtime.GOOS == "linux" {
+		// Linux kernel and initramfs images
+		fwData.LinuxBoot = new(api.LinuxBoot)
+		if opts.Enabled(CollectorLinuxBoot) {
+			linuxboot.ReportOfflineLinuxBoot(fwData.LinuxBoot, root)
+		} else {
+			fwData.LinuxBoot.Error = api.DeniedByPolicy
+		}
+
+		// custom IMA policies loaded at boot
+		fwData.IMAPolicy = new(api.IMAPolicy)
+		if opts.Enabled(CollectorIMA) {
+			ima.ReportIMAPolicy(fwData.IMAPolicy, root)
+		} else {
+			fwData.IMAPolicy.Error = api.DeniedByPolicy
+		}
+
+		// state of the running kernel and the attached devices
+		fwData.KernelSecurity = new(api.KernelSecurity)
+		failKernelSecurity(fwData.KernelSecurity, api.NotImplemented)
+		fwData.Peripherals = &api.Peripherals{USBErr: api.NotImplemented, ThunderboltErr: api.NotImplemented}
+		fwData.Storage = &api.Storage{DrivesErr: api.NotImplemented, LUKSErr: api.NotImplemented}
+	}
+
+	redact(&fwData, opts)
+
+	log.Trace().Msg("done gathering offline data")
+	return fwData
+}
@@ -0,0 +1,187 @@
+package firmware
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/policy"
+)
+
+// collector names used to disable parts of the report
+const (
+	CollectorFlash     = "flash"
+	CollectorCPUID     = "cpuid"
+	CollectorMSR       = "msr"
+	CollectorMAC       = "mac"
+	CollectorPCI       = "pci"
+	CollectorSEV       = "sev"
+	CollectorACPI      = "acpi"
+	CollectorSMBIOS    = "smbios"
+	CollectorTXT       = "txt"
+	CollectorUEFI      = "uefi"
+	CollectorEventLog  = "eventlog"
+	CollectorTPM2      = "tpm2"
+	CollectorEPP       = "epp"
+	CollectorME        = "me"
+	CollectorOS        = "os"
+	CollectorAgent     = "agent"
+	CollectorNIC       = "nic"
+	CollectorFWUPD     = "fwupd"
+	CollectorBootApps  = "bootapps"
+	CollectorIMA       = "ima"
+	CollectorLinuxBoot = "linuxboot"
+	CollectorKernelSec = "kernelsec"
+	CollectorTDX       = "tdx"
+	CollectorSGX       = "sgx"
+	CollectorBootGuard = "bootguard"
+	CollectorSPI       = "spi"
+	CollectorUEFIFV    = "uefifv"
+	CollectorPCIDevs   = "pcidevices"
+	CollectorUSB       = "usb" // USB and Thunderbolt
+	CollectorStorage   = "storage"
+	CollectorBMC       = "bmc"
+)
+
+var Collectors = []string{
+	CollectorFlash, CollectorCPUID, CollectorMSR, CollectorMAC, CollectorPCI, CollectorSEV, CollectorACPI,
+	CollectorSMBIOS, CollectorTXT, CollectorUEFI, CollectorEventLog, CollectorTPM2, CollectorEPP, CollectorME,
+	CollectorOS, CollectorAgent, CollectorNIC, CollectorFWUPD, CollectorBootApps, CollectorIMA,
+	CollectorLinuxBoot, CollectorKernelSec, CollectorTDX, CollectorSGX,
+	CollectorBootGuard, CollectorSPI, CollectorUEFIFV, CollectorPCIDevs, CollectorUSB,
+	CollectorStorage, CollectorBMC,
+}
+
+// redaction rules that strip identifying data from the report
+const (
+	RedactHostname = "hostname"
+	RedactIP       = "ip"
+	RedactMAC      = "mac"
+)
+
+var RedactionRules = []string{RedactHostname, RedactIP, RedactMAC}
+
+// Options hold local restrictions on what GatherFirmwareData collects and reports. The zero value collects everything.
+type Options struct {
+	Policy   *policy.Policy
+	Disabled []string    // Collector*
+	Redact   []string    // Redact*
+	MountESP bool        // mount the EFI system partition read-only if it isn't mounted
+	Root     common.Root // directory the Linux collectors treat as the root file system, empty for "/"
+	Offline  bool        // Root belongs to a powered-off machine, only collect what's on its file system
+}
+
+// Validate checks that all disabled collectors and redaction rules are known
+func (o *Options) Validate() error {
+	if err := validateNames(o.Disabled, Collectors); err != nil {
+		return fmt.Errorf("unknown collector: %w", err)
+	}
+	if err := validateNames(o.Redact, RedactionRules); err != nil {
+		return fmt.Errorf("unknown redaction rule: %w", err)
+	}
+	if o.Offline && o.Root == "" {
+		return errors.New("offline collection needs a root file system")
+	}
+	return nil
+}
+
+func validateNames(names, known []string) error {
+	for _, name := range names {
+		if !contains(known, name) {
+			return fmt.Errorf("'%s'", name)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, name string) bool {
+	for _, v := range list {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled returns false if the collector has been disabled, a nil receiver enables everything
+func (o *Options) Enabled(collector string) bool {
+	return o == nil || !contains(o.Disabled, collector)
+}
+
+func (o *Options) redacts(rule string) bool {
+	return o != nil && contains(o.Redact, rule)
+}
+
+func (o *Options) mountESP() bool {
+	return o != nil && o.MountESP
+}
+
+func (o *Options) root() common.Root {
+	if o == nil {
+		return ""
+	}
+	return o.Root
+}
+
+func (o *Options) offline() bool {
+	return o != nil && o.Offline
+}
+
+func (o *Options) policy() *policy.Policy {
+	if o == nil {
+		return nil
+	}
+	return o.Policy
+}
+
+// denyAll marks all requested items of a disabled collector
+func denyAll[T any](items []T, errorOf func(*T) *api.FirmwareError) {
+	failAll(items, api.DeniedByPolicy, errorOf)
+}
+
+// failAll sets the error of all requested items
+func failAll[T any](items []T, err api.FirmwareError, errorOf func(*T) *api.FirmwareError) {
+	for i := range items {
+		*errorOf(&items[i]) = err
+	}
+}
+
+// denyKernelSecurity marks all items of a disabled kernel security collector
+func denyKernelSecurity(ks *api.KernelSecurity) {
+	failKernelSecurity(ks, api.DeniedByPolicy)
+}
+
+// failKernelSecurity sets the error of all kernel security items
+func failKernelSecurity(ks *api.KernelSecurity, err api.FirmwareError) {
+	for _, e := range []*api.FirmwareError{
+		&ks.LockdownErr, &ks.LSMsErr, &ks.SELinuxErr, &ks.AppArmorErr, &ks.VulnerabilitiesErr,
+		&ks.ModuleSigEnforceErr, &ks.TaintErr, &ks.IOMMUErr, &ks.DMAProtectionErr,
+	} {
+		*e = err
+	}
+}
+
+// redact strips data from the report as requested by the redaction rules
+func redact(fwData *api.FirmwareProperties, opts *Options) {
+	if opts.redacts(RedactHostname) {
+		fwData.OS.Hostname = ""
+	}
+
+	if opts.redacts(RedactMAC) {
+		fwData.MACAddresses.Addresses = nil
+	}
+
+	if fwData.NICs != nil {
+		for i := range fwData.NICs.List {
+			nic := &fwData.NICs.List[i]
+			if opts.redacts(RedactMAC) {
+				nic.MAC = ""
+			}
+			if opts.redacts(RedactIP) {
+				nic.IPv4 = nil
+				nic.IPv6 = nil
+			}
+		}
+	}
+}
@@ -1,18 +1,16 @@
 package osinfo
 
 import (
-	"os"
-
 	"github.com/immune-gmbh/agent/v3/pkg/api"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 	"github.com/rs/zerolog/log"
 )
 
 // XXX the stuct filled by this function has inconsistent error reporting semantics
-func ReportOSInfo(osInfo *api.OS) error {
+func ReportOSInfo(osInfo *api.OS, root common.Root) error {
 	log.Trace().Msg("ReportOSInfo()")
 
-	release, err := readOSReleasePrettyName()
+	release, err := readOSReleasePrettyName(root)
 	if err != nil {
 		osInfo.Error = common.ServeApiError(common.MapFSErrors(err))
 		log.Debug().Err(err).Msg("osinfo.ReportOSInfo()")
@@ -21,7 +19,7 @@ func ReportOSInfo(osInfo *api.OS) error {
 	}
 	osInfo.Release = release
 
-	hostname, err := os.Hostname()
+	hostname, err := readHostname(root)
 	if err != nil {
 		osInfo.Error = common.ServeApiError(common.MapFSErrors(err))
 		log.Debug().Err(err).Msg("osinfo.ReportOSInfo()")
@@ -7,16 +7,19 @@ import (
 	"os"
 	"runtime"
 	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
 const (
 	etcOSRelease     = "/etc/os-release"
+	etcHostname      = "/etc/hostname"
 	prettyNamePrefix = "PRETTY_NAME=\""
 	prettyNameSplit  = "\""
 )
 
-func readOSReleasePrettyName() (string, error) {
-	f, err := os.OpenFile(etcOSRelease, os.O_RDONLY, os.ModePerm)
+func readOSReleasePrettyName(root common.Root) (string, error) {
+	f, err := os.OpenFile(root.Path(etcOSRelease), os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		return "", fmt.Errorf("error opening %v: %w", etcOSRelease, err)
 	}
@@ -44,3 +47,17 @@ func readOSReleasePrettyName() (string, error) {
 
 	return runtime.GOOS, nil
 }
+
+// readHostname returns the kernel's host name or, if the collectors run against another root file system, the one
+// configured there
+func readHostname(root common.Root) (string, error) {
+	if root.IsHost() {
+		return os.Hostname()
+	}
+
+	buf, err := os.ReadFile(root.Path(etcHostname))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
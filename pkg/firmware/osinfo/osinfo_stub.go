@@ -4,9 +4,16 @@ package osinfo
 
 import (
 	"errors"
+	"os"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readOSReleasePrettyName() (string, error) {
+func readOSReleasePrettyName(root common.Root) (string, error) {
 	return "unsupported", errors.New("osinfo.readOSReleasePrettyName not implemented on " + runtime.GOOS)
 }
+
+func readHostname(root common.Root) (string, error) {
+	return os.Hostname()
+}
@@ -2,12 +2,15 @@ package osinfo
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 
 	"golang.org/x/sys/windows/registry"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readOSReleasePrettyName() (string, error) {
+func readOSReleasePrettyName(root common.Root) (string, error) {
 	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`, registry.QUERY_VALUE)
 	if err != nil {
 		return runtime.GOOS, fmt.Errorf(`can't read open reg key 'SOFTWARE\Microsoft\Windows NT\CurrentVersion': %w`, err)
@@ -36,3 +39,7 @@ func readOSReleasePrettyName() (string, error) {
 
 	return fmt.Sprintf("%s %v.%v id %s", pn, maj, min, rid), nil
 }
+
+func readHostname(root common.Root) (string, error) {
+	return os.Hostname()
+}
@@ -6,8 +6,8 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func reportConfigSpace(request *api.PCIConfigSpace) error {
-	buf, err := readConfigSpace(uint32(request.Bus), uint32(request.Device), uint32(request.Function), 0, 4096)
+func reportConfigSpace(request *api.PCIConfigSpace, root common.Root) error {
+	buf, err := readConfigSpace(uint32(request.Bus), uint32(request.Device), uint32(request.Function), 0, 4096, root)
 
 	if err != nil {
 		log.Debug().Err(err).Msg("pci.ReportConfigSpace()")
@@ -18,13 +18,13 @@ func reportConfigSpace(request *api.PCIConfigSpace) error {
 	return nil
 }
 
-func ReportConfigSpaces(requests []api.PCIConfigSpace) (err error) {
+func ReportConfigSpaces(requests []api.PCIConfigSpace, root common.Root) (err error) {
 	log.Trace().Msg("ReportConfigSpaces()")
 
 	allFailed := true
 	for i := range requests {
 		v := &requests[i]
-		err = reportConfigSpace(v)
+		err = reportConfigSpace(v, root)
 		allFailed = allFailed && err != nil
 	}
 	if allFailed && len(requests) > 0 {
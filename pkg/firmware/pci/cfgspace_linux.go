@@ -4,14 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-)
 
-const (
-	pciPath = "/sys/bus/pci/devices"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readConfigSpace(bus, device, function, offset, maxcount uint32) ([]byte, error) {
-	devPath := filepath.Join(pciPath, fmt.Sprintf("0000:%02x:%02x.%d", bus, device, function))
+var pciPath = "/sys/bus/pci/devices"
+
+func readConfigSpace(bus, device, function, offset, maxcount uint32, root common.Root) ([]byte, error) {
+	devPath := filepath.Join(root.Path(pciPath), fmt.Sprintf("0000:%02x:%02x.%d", bus, device, function))
 	f, err := os.Open(filepath.Join(devPath, "config"))
 	if err != nil {
 		return nil, err
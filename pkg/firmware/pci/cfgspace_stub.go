@@ -5,9 +5,11 @@ package pci
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readConfigSpace(bus, device, function, offset, maxcount uint32) (outBuf []byte, err error) {
+func readConfigSpace(bus, device, function, offset, maxcount uint32, root common.Root) (outBuf []byte, err error) {
 	err = errors.New("pciconfig.ReadConfigSpace not implemented on " + runtime.GOOS)
 	return
 }
@@ -1,7 +1,9 @@
 package pci
 
+import "github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+
 // readConfigSpace is just a wrapper to keep things consistent
-func readConfigSpace(bus, device, function, offset, maxcount uint32) (outBuf []byte, err error) {
+func readConfigSpace(bus, device, function, offset, maxcount uint32, root common.Root) (outBuf []byte, err error) {
 	// currently not implemented, trying to move functionality into non-pnp driver
 	return nil, nil
 }
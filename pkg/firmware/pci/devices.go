@@ -0,0 +1,24 @@
+package pci
+
+import (
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+// ReportDevices enumerates all PCI functions instead of reading the configuration space of the ones requested by
+// the server. Devices that vanish or can't be read during the walk are skipped.
+func ReportDevices(devs *api.PCIDevices, root common.Root) error {
+	log.Trace().Msg("ReportDevices()")
+
+	list, err := listDevices(root)
+	if err != nil {
+		log.Debug().Err(err).Msg("pci.ReportDevices()")
+		log.Warn().Msg("Failed to enumerate PCI devices")
+		devs.Error = common.ServeApiError(common.MapFSErrors(err))
+		return err
+	}
+	devs.Devices = list
+
+	return nil
+}
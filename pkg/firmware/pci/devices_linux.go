@@ -0,0 +1,155 @@
+package pci
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	pciCommand          = 0x04
+	pciCommandBusMaster = 1 << 2
+)
+
+func listDevices(root common.Root) ([]api.PCIDevice, error) {
+	entries, err := os.ReadDir(root.Path(pciPath))
+	if err != nil {
+		return nil, err
+	}
+
+	devs := []api.PCIDevice{}
+	for _, entry := range entries {
+		dev, err := readDevice(entry.Name(), root)
+		if err != nil {
+			log.Debug().Err(err).Str("device", entry.Name()).Msg("pci.listDevices()")
+			continue
+		}
+		devs = append(devs, *dev)
+	}
+	return devs, nil
+}
+
+func readHex(dir, attr string, bits int) (uint64, error) {
+	buf, err := os.ReadFile(filepath.Join(dir, attr))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(string(buf)), "0x"), 16, bits)
+}
+
+// readLink returns the name of the sysfs object a link like driver or iommu_group points to
+func readLink(dir, attr string) (string, error) {
+	target, err := os.Readlink(filepath.Join(dir, attr))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(target), nil
+}
+
+func readDevice(addr string, root common.Root) (*api.PCIDevice, error) {
+	dir := filepath.Join(root.Path(pciPath), addr)
+	dev := api.PCIDevice{Address: addr}
+
+	ids := []struct {
+		attr string
+		bits int
+		out  func(uint64)
+	}{
+		{"vendor", 16, func(v uint64) { dev.Vendor = uint16(v) }},
+		{"device", 16, func(v uint64) { dev.Device = uint16(v) }},
+		{"subsystem_vendor", 16, func(v uint64) { dev.SubsystemVendor = uint16(v) }},
+		{"subsystem_device", 16, func(v uint64) { dev.SubsystemDevice = uint16(v) }},
+		{"class", 24, func(v uint64) { dev.Class = uint32(v) }},
+		{"revision", 8, func(v uint64) { dev.Revision = uint8(v) }},
+	}
+	for _, id := range ids {
+		v, err := readHex(dir, id.attr, id.bits)
+		if err != nil {
+			return nil, err
+		}
+		id.out(v)
+	}
+
+	// unbound devices have no driver link, devices without IOMMU no group
+	if driver, err := readLink(dir, "driver"); err == nil {
+		dev.Driver = driver
+	}
+	if group, err := readLink(dir, "iommu_group"); err == nil {
+		if n, err := strconv.Atoi(group); err == nil {
+			dev.IOMMUGroup = &n
+		}
+	}
+
+	// the first 64 bytes of the configuration space are readable without privileges
+	if cfg, err := os.ReadFile(filepath.Join(dir, "config")); err == nil && len(cfg) > pciCommand+1 {
+		master := cfg[pciCommand]&pciCommandBusMaster != 0
+		dev.BusMaster = &master
+	}
+	if buf, err := os.ReadFile(filepath.Join(dir, "removable")); err == nil {
+		dev.Removable = strings.TrimSpace(string(buf)) == "removable"
+	}
+
+	size, sum, err := readROM(dir, root)
+	if err != nil {
+		log.Debug().Err(err).Str("device", addr).Msg("pci.readDevice() option ROM")
+		dev.ROMErr = common.ServeApiError(common.MapFSErrors(err))
+	} else if sum != nil {
+		dev.ROMSize = size
+		dev.ROMSha256 = sum
+	}
+
+	return &dev, nil
+}
+
+// enableROM toggles read access to the rom attribute, replaced in tests
+var enableROM = func(path string, enable bool) error {
+	val := "0"
+	if enable {
+		val = "1"
+	}
+	return os.WriteFile(path, []byte(val), 0)
+}
+
+// readROM hashes the option ROM of a device. The kernel only allows reading the rom attribute after enabling it, it's
+// disabled again afterwards. Outside the host's sysfs the attribute is a plain file that is hashed as it is.
+func readROM(dir string, root common.Root) (uint64, []byte, error) {
+	path := filepath.Join(dir, "rom")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return 0, nil, nil
+	}
+
+	if root.IsHost() {
+		if err := enableROM(path, true); err != nil {
+			return 0, nil, err
+		}
+		defer func() {
+			if err := enableROM(path, false); err != nil {
+				log.Debug().Err(err).Str("path", path).Msg("pci.readROM() disable")
+			}
+		}()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, nil, err
+	}
+	if n == 0 {
+		return 0, nil, nil
+	}
+	return uint64(n), h.Sum(nil), nil
+}
@@ -0,0 +1,111 @@
+//go:build linux
+// +build linux
+
+package pci
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeDevice(t *testing.T, addr string, attrs map[string]string, links map[string]string) {
+	dir := filepath.Join(pciPath, addr)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	commontest.WriteTree(t, dir, attrs)
+	for name, target := range links {
+		assert.NoError(t, os.Symlink(target, filepath.Join(dir, name)))
+	}
+}
+
+func TestReportDevices(t *testing.T) {
+	oldPath, oldEnable := pciPath, enableROM
+	t.Cleanup(func() { pciPath, enableROM = oldPath, oldEnable })
+	pciPath = t.TempDir()
+	var enabled []bool
+	enableROM = func(path string, enable bool) error {
+		enabled = append(enabled, enable)
+		return nil
+	}
+
+	rom := "\x55\xaaoption rom"
+	writeDevice(t, "0000:00:14.0", map[string]string{
+		"vendor": "0x8086\n", "device": "0xa36d\n", "subsystem_vendor": "0x1028\n", "subsystem_device": "0x0869\n",
+		"class": "0x0c0330\n", "revision": "0x10\n", "config": "\x86\x80\x6d\xa3\x06\x04",
+	}, map[string]string{
+		"driver":      "../../../bus/pci/drivers/xhci_hcd",
+		"iommu_group": "../../../kernel/iommu_groups/4",
+	})
+	writeDevice(t, "0000:3b:00.0", map[string]string{
+		"vendor": "0x10de\n", "device": "0x1e87\n", "subsystem_vendor": "0x0000\n", "subsystem_device": "0x0000\n",
+		"class": "0x030000\n", "revision": "0xa1\n", "config": "\xde\x10\x87\x1e\x00\x00",
+		"removable": "removable\n", "rom": rom,
+	}, nil)
+	// vanished during the walk
+	writeDevice(t, "0000:3c:00.0", map[string]string{"vendor": "0x1234\n"}, nil)
+
+	var devs api.PCIDevices
+	assert.NoError(t, ReportDevices(&devs, ""))
+	assert.Empty(t, devs.Error)
+	if !assert.Len(t, devs.Devices, 2) {
+		return
+	}
+
+	group, master, noMaster := 4, true, false
+	assert.Equal(t, api.PCIDevice{
+		Address: "0000:00:14.0", Vendor: 0x8086, Device: 0xa36d, SubsystemVendor: 0x1028, SubsystemDevice: 0x0869,
+		Class: 0x0c0330, Revision: 0x10, Driver: "xhci_hcd", IOMMUGroup: &group, BusMaster: &master,
+	}, devs.Devices[0])
+
+	sum := sha256.Sum256([]byte(rom))
+	assert.Equal(t, api.PCIDevice{
+		Address: "0000:3b:00.0", Vendor: 0x10de, Device: 0x1e87, Class: 0x030000, Revision: 0xa1,
+		BusMaster: &noMaster, Removable: true, ROMSize: uint64(len(rom)), ROMSha256: sum[:],
+	}, devs.Devices[1])
+	assert.Equal(t, []bool{true, false}, enabled)
+}
+
+func TestReportDevicesCapturedTree(t *testing.T) {
+	oldEnable := enableROM
+	t.Cleanup(func() { enableROM = oldEnable })
+	enableROM = func(path string, enable bool) error {
+		t.Errorf("rom attribute toggled in a captured tree: %s", path)
+		return nil
+	}
+
+	root := t.TempDir()
+	rom := "\x55\xaaoption rom"
+	dir := filepath.Join(root, pciPath, "0000:3b:00.0")
+	commontest.WriteTree(t, dir, map[string]string{
+		"vendor": "0x10de\n", "device": "0x1e87\n", "subsystem_vendor": "0x0000\n", "subsystem_device": "0x0000\n",
+		"class": "0x030000\n", "revision": "0xa1\n", "rom": rom,
+	})
+
+	var devs api.PCIDevices
+	assert.NoError(t, ReportDevices(&devs, common.Root(root)))
+	if !assert.Len(t, devs.Devices, 1) {
+		return
+	}
+	sum := sha256.Sum256([]byte(rom))
+	assert.Equal(t, uint64(len(rom)), devs.Devices[0].ROMSize)
+	assert.Equal(t, api.Buffer(sum[:]), devs.Devices[0].ROMSha256)
+	content, err := os.ReadFile(filepath.Join(dir, "rom"))
+	assert.NoError(t, err)
+	assert.Equal(t, rom, string(content))
+}
+
+func TestReportDevicesNoSysfs(t *testing.T) {
+	oldPath := pciPath
+	t.Cleanup(func() { pciPath = oldPath })
+	pciPath = filepath.Join(t.TempDir(), "missing")
+
+	var devs api.PCIDevices
+	assert.Error(t, ReportDevices(&devs, ""))
+	assert.Equal(t, api.NoResponse, devs.Error)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package pci
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+func listDevices(root common.Root) ([]api.PCIDevice, error) {
+	return nil, errors.New("PCI device enumeration not implemented on " + runtime.GOOS)
+}
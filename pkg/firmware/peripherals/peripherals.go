@@ -0,0 +1,33 @@
+package peripherals
+
+import (
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+// ReportPeripherals lists the USB devices and Thunderbolt domains. The function only fails if neither bus could be
+// read.
+func ReportPeripherals(p *api.Peripherals, root common.Root) error {
+	log.Trace().Msg("ReportPeripherals()")
+
+	usb, usbErr := readUSBDevices(root)
+	if usbErr != nil {
+		log.Debug().Err(usbErr).Msg("peripherals.ReportPeripherals() usb")
+		p.USBErr = common.ServeApiError(common.MapFSErrors(usbErr))
+	}
+	p.USB = usb
+
+	tb, tbErr := readThunderbolt(root)
+	if tbErr != nil {
+		log.Debug().Err(tbErr).Msg("peripherals.ReportPeripherals() thunderbolt")
+		p.ThunderboltErr = common.ServeApiError(common.MapFSErrors(tbErr))
+	}
+	p.Thunderbolt = tb
+
+	if usbErr != nil && tbErr != nil {
+		log.Warn().Msg("Failed to list USB and Thunderbolt devices")
+		return usbErr
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package peripherals
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+var sysfs = "/sys"
+
+func readString(path string) (string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+func readHex(path string, bits int) (uint64, error) {
+	str, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(str, "0x"), 16, bits)
+}
+
+// readUSBDevices lists all USB devices including root hubs. Interfaces appear as separate entries named after their
+// device followed by the configuration and interface number.
+func readUSBDevices(root common.Root) ([]api.USBDevice, error) {
+	dir := filepath.Join(root.Path(sysfs), "bus", "usb", "devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	devs := []api.USBDevice{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.Contains(name, ":") {
+			continue
+		}
+		dev, err := readUSBDevice(filepath.Join(dir, name))
+		if err != nil {
+			log.Debug().Err(err).Str("device", name).Msg("peripherals.readUSBDevices()")
+			continue
+		}
+		dev.Path = name
+
+		for _, iface := range entries {
+			if !strings.HasPrefix(iface.Name(), name+":") {
+				continue
+			}
+			if class, err := readHex(filepath.Join(dir, iface.Name(), "bInterfaceClass"), 8); err == nil {
+				dev.InterfaceClasses = append(dev.InterfaceClasses, uint8(class))
+			}
+		}
+		devs = append(devs, *dev)
+	}
+	return devs, nil
+}
+
+func readUSBDevice(dir string) (*api.USBDevice, error) {
+	var dev api.USBDevice
+	vendor, err := readHex(filepath.Join(dir, "idVendor"), 16)
+	if err != nil {
+		return nil, err
+	}
+	product, err := readHex(filepath.Join(dir, "idProduct"), 16)
+	if err != nil {
+		return nil, err
+	}
+	class, err := readHex(filepath.Join(dir, "bDeviceClass"), 8)
+	if err != nil {
+		return nil, err
+	}
+	dev.Vendor, dev.Product, dev.Class = uint16(vendor), uint16(product), uint8(class)
+
+	// string descriptors are optional
+	dev.Manufacturer, _ = readString(filepath.Join(dir, "manufacturer"))
+	dev.ProductName, _ = readString(filepath.Join(dir, "product"))
+	dev.Serial, _ = readString(filepath.Join(dir, "serial"))
+
+	authorized, err := readString(filepath.Join(dir, "authorized"))
+	if err != nil {
+		return nil, err
+	}
+	dev.Authorized = authorized == "1"
+
+	return &dev, nil
+}
+
+// readThunderbolt lists the Thunderbolt and USB4 domains with their security level and the routers connected to
+// them. Routers of domain N are named N-<route>, entries containing a colon are services and retimers.
+func readThunderbolt(root common.Root) ([]api.ThunderboltDomain, error) {
+	dir := filepath.Join(root.Path(sysfs), "bus", "thunderbolt", "devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := []api.ThunderboltDomain{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "domain") {
+			continue
+		}
+		security, err := readString(filepath.Join(dir, name, "security"))
+		if err != nil {
+			log.Debug().Err(err).Str("domain", name).Msg("peripherals.readThunderbolt()")
+			continue
+		}
+		domain := api.ThunderboltDomain{Name: name, Security: security}
+
+		// only present if the firmware supports a pre-boot ACL, empty slots are empty strings
+		if acl, err := readString(filepath.Join(dir, name, "boot_acl")); err == nil {
+			for _, uuid := range strings.Split(acl, ",") {
+				if uuid != "" {
+					domain.BootACL = append(domain.BootACL, uuid)
+				}
+			}
+		}
+		if str, err := readString(filepath.Join(dir, name, "iommu_dma_protection")); err == nil {
+			protected := str == "1"
+			domain.DMAProtection = &protected
+		}
+
+		prefix := strings.TrimPrefix(name, "domain") + "-"
+		for _, router := range entries {
+			if !strings.HasPrefix(router.Name(), prefix) || strings.Contains(router.Name(), ":") {
+				continue
+			}
+			dev, err := readThunderboltDevice(filepath.Join(dir, router.Name()))
+			if err != nil {
+				log.Debug().Err(err).Str("device", router.Name()).Msg("peripherals.readThunderbolt()")
+				continue
+			}
+			dev.Name = router.Name()
+			domain.Devices = append(domain.Devices, *dev)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+func readThunderboltDevice(dir string) (*api.ThunderboltDevice, error) {
+	var dev api.ThunderboltDevice
+	vendor, err := readHex(filepath.Join(dir, "vendor"), 16)
+	if err != nil {
+		return nil, err
+	}
+	device, err := readHex(filepath.Join(dir, "device"), 16)
+	if err != nil {
+		return nil, err
+	}
+	dev.Vendor, dev.Device = uint16(vendor), uint16(device)
+	dev.VendorName, _ = readString(filepath.Join(dir, "vendor_name"))
+	dev.DeviceName, _ = readString(filepath.Join(dir, "device_name"))
+	dev.UniqueID, _ = readString(filepath.Join(dir, "unique_id"))
+
+	// the host router has no authorized attribute
+	if str, err := readString(filepath.Join(dir, "authorized")); err == nil {
+		dev.Authorized, _ = strconv.Atoi(str)
+	}
+	return &dev, nil
+}
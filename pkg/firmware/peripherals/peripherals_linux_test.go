@@ -0,0 +1,86 @@
+//go:build linux
+// +build linux
+
+package peripherals
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportPeripherals(t *testing.T) {
+	sysfs = t.TempDir()
+
+	usb := filepath.Join(sysfs, "bus/usb/devices")
+	commontest.WriteTree(t, filepath.Join(usb, "usb1"), map[string]string{
+		"idVendor": "1d6b\n", "idProduct": "0002\n", "bDeviceClass": "09\n", "authorized": "1\n",
+		"manufacturer": "Linux 6.1.0 xhci-hcd\n", "product": "xHCI Host Controller\n", "serial": "0000:00:14.0\n",
+	})
+	commontest.WriteTree(t, filepath.Join(usb, "1-0:1.0"), map[string]string{"bInterfaceClass": "09\n"})
+	commontest.WriteTree(t, filepath.Join(usb, "1-2"), map[string]string{
+		"idVendor": "046d\n", "idProduct": "c52b\n", "bDeviceClass": "00\n", "authorized": "0\n",
+		"product": "USB Receiver\n",
+	})
+	commontest.WriteTree(t, filepath.Join(usb, "1-2:1.0"), map[string]string{"bInterfaceClass": "03\n"})
+	commontest.WriteTree(t, filepath.Join(usb, "1-2:1.1"), map[string]string{"bInterfaceClass": "03\n"})
+	commontest.WriteTree(t, filepath.Join(usb, "1-3"), map[string]string{"idVendor": "0bda\n"})
+
+	tb := filepath.Join(sysfs, "bus/thunderbolt/devices")
+	commontest.WriteTree(t, filepath.Join(tb, "domain0"), map[string]string{
+		"security": "user\n", "iommu_dma_protection": "1\n",
+		"boot_acl": "ec8d6c6f-3a2e-4a4c-9f3b-0a2c4b6e7d81,,,,,\n",
+	})
+	commontest.WriteTree(t, filepath.Join(tb, "0-0"), map[string]string{
+		"vendor": "0x8086\n", "device": "0x9a1b\n", "vendor_name": "Intel\n", "device_name": "Host router\n",
+	})
+	commontest.WriteTree(t, filepath.Join(tb, "0-1"), map[string]string{
+		"vendor": "0x108\n", "device": "0x1630\n", "vendor_name": "Dell\n", "device_name": "WD19TB\n",
+		"unique_id": "ec8d6c6f-3a2e-4a4c-9f3b-0a2c4b6e7d81\n", "authorized": "1\n",
+	})
+	commontest.WriteTree(t, filepath.Join(tb, "0-0:1.1"), map[string]string{"vendor": "0x8087\n", "device": "0x15ef\n"})
+	commontest.WriteTree(t, filepath.Join(tb, "domain1"), map[string]string{"security": "secure\n"})
+
+	var p api.Peripherals
+	assert.NoError(t, ReportPeripherals(&p, ""))
+	assert.Empty(t, p.USBErr)
+	assert.Empty(t, p.ThunderboltErr)
+
+	assert.Equal(t, []api.USBDevice{
+		{Path: "1-2", Vendor: 0x046d, Product: 0xc52b, InterfaceClasses: []uint8{3, 3}, ProductName: "USB Receiver"},
+		{Path: "usb1", Vendor: 0x1d6b, Product: 0x0002, Class: 9, Manufacturer: "Linux 6.1.0 xhci-hcd",
+			ProductName: "xHCI Host Controller", Serial: "0000:00:14.0", Authorized: true},
+	}, p.USB)
+
+	protected := true
+	assert.Equal(t, []api.ThunderboltDomain{
+		{
+			Name: "domain0", Security: "user", DMAProtection: &protected,
+			BootACL: []string{"ec8d6c6f-3a2e-4a4c-9f3b-0a2c4b6e7d81"},
+			Devices: []api.ThunderboltDevice{
+				{Name: "0-0", Vendor: 0x8086, Device: 0x9a1b, VendorName: "Intel", DeviceName: "Host router"},
+				{Name: "0-1", Vendor: 0x108, Device: 0x1630, VendorName: "Dell", DeviceName: "WD19TB",
+					UniqueID: "ec8d6c6f-3a2e-4a4c-9f3b-0a2c4b6e7d81", Authorized: 1},
+			},
+		},
+		{Name: "domain1", Security: "secure"},
+	}, p.Thunderbolt)
+}
+
+func TestReportPeripheralsNoSysfs(t *testing.T) {
+	sysfs = t.TempDir()
+	commontest.WriteFile(t, filepath.Join(sysfs, "bus/usb/devices/usb1/idVendor"), "1d6b\n")
+
+	var p api.Peripherals
+	assert.NoError(t, ReportPeripherals(&p, ""))
+	assert.Empty(t, p.USB)
+	assert.Empty(t, p.USBErr)
+	assert.Equal(t, api.NoResponse, p.ThunderboltErr)
+
+	sysfs = filepath.Join(t.TempDir(), "missing")
+	assert.Error(t, ReportPeripherals(&p, ""))
+	assert.Equal(t, api.NoResponse, p.USBErr)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package peripherals
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+var errNotImplemented = errors.New("peripherals not implemented on " + runtime.GOOS)
+
+func readUSBDevices(root common.Root) ([]api.USBDevice, error) {
+	return nil, errNotImplemented
+}
+
+func readThunderbolt(root common.Root) ([]api.ThunderboltDomain, error) {
+	return nil, errNotImplemented
+}
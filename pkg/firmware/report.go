@@ -12,20 +12,30 @@ import (
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/acpi"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/biosflash"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/bootapps"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/bootguard"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/cpuid"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/epp"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/fwupd"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/heci"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/ima"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/immunecpu"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/ipmi"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/kernelsec"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/linuxboot"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/msr"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/netif"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/osinfo"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/pci"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/peripherals"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/sev"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/sgx"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/smbios"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/srtmlog"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/storage"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/tdx"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/txt"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefifv"
 	"github.com/immune-gmbh/agent/v3/pkg/firmware/uefivars"
 	"github.com/immune-gmbh/agent/v3/pkg/tcg"
 	"github.com/immune-gmbh/agent/v3/pkg/util"
@@ -35,11 +45,19 @@ import (
 // GatherFirmwareData passes the server-sent configuration leafs to the appropriate report sub-functions.
 // Error handling and logging is mostly left to the leaf functions. If part of the report fails, it is
 // simply omitted. Errors that are meaningful for the SaaS are stored in the error members of the api structs.
-func GatherFirmwareData(tpmConn io.ReadWriteCloser, request *api.Configuration) api.FirmwareProperties {
+// Requested items the local policy denies access to and disabled collectors are reported as denied. Nil options collect everything.
+func GatherFirmwareData(tpmConn io.ReadWriteCloser, request *api.Configuration, opts *Options) api.FirmwareProperties {
 	log.Trace().Msg("start gathering firmware data")
 
 	var fwData api.FirmwareProperties
 	cpuVendor := cpuid.Vendor()
+	pol := opts.policy()
+	root := opts.root()
+
+	// a powered-off machine only has its file system to offer
+	if opts.offline() {
+		return gatherOffline(request, opts, root)
+	}
 
 	// Get ourselves windows security permissions to read UEFI vars
 	err := util.WinAddTokenPrivilege("SeSystemEnvironmentPrivilege")
@@ -68,56 +86,136 @@ func GatherFirmwareData(tpmConn io.ReadWriteCloser, request *api.Configuration)
 	defer immunecpu.StopDriver()
 
 	// Basic Input/Output System flash
-	biosflash.ReportBiosFlash(&fwData.Flash)
+	if opts.Enabled(CollectorFlash) {
+		biosflash.ReportBiosFlash(&fwData.Flash, root)
+	} else {
+		fwData.Flash.Error = api.DeniedByPolicy
+	}
+
+	// UEFI firmware volumes in the flash dump
+	fwData.UEFIFirmware = new(api.UEFIFirmware)
+	if opts.Enabled(CollectorUEFIFV) {
+		uefifv.ReportFirmwareVolumes(fwData.UEFIFirmware, &fwData.Flash)
+	} else {
+		fwData.UEFIFirmware.Error = api.DeniedByPolicy
+	}
 
 	// CPUID leaves
 	fwData.CPUIDLeafs = request.CPUIDLeafs
-	for i := range fwData.CPUIDLeafs {
-		v := &fwData.CPUIDLeafs[i]
-		cpuid.ReportCPUIDLeaf(v)
+	if opts.Enabled(CollectorCPUID) {
+		pol.FilterCPUIDLeafs(fwData.CPUIDLeafs, func(leafs []api.CPUIDLeaf) error {
+			for i := range leafs {
+				v := &leafs[i]
+				cpuid.ReportCPUIDLeaf(v)
+			}
+			return nil
+		})
+	} else {
+		denyAll(fwData.CPUIDLeafs, func(v *api.CPUIDLeaf) *api.FirmwareError { return &v.Error })
 	}
 
 	// Model Specific Registers
 	fwData.MSRs = request.MSRs
-	msr.ReportMSRs(fwData.MSRs)
+	if opts.Enabled(CollectorMSR) {
+		pol.FilterMSRs(fwData.MSRs, msr.ReportMSRs)
+	} else {
+		denyAll(fwData.MSRs, func(v *api.MSR) *api.FirmwareError { return &v.Error })
+	}
 
 	// Medium Access Control addresses
-	netif.ReportMACAddresses(&fwData.MACAddresses)
+	if opts.Enabled(CollectorMAC) {
+		netif.ReportMACAddresses(&fwData.MACAddresses, root)
+	} else {
+		fwData.MACAddresses.Error = api.DeniedByPolicy
+	}
 
 	// Peripheral Component Interconnect config space
 	fwData.PCIConfigSpaces = request.PCIConfigSpaces
-	pci.ReportConfigSpaces(fwData.PCIConfigSpaces)
+	if opts.Enabled(CollectorPCI) {
+		pol.FilterPCIConfigSpaces(fwData.PCIConfigSpaces, func(spaces []api.PCIConfigSpace) error {
+			return pci.ReportConfigSpaces(spaces, root)
+		})
+	} else {
+		denyAll(fwData.PCIConfigSpaces, func(v *api.PCIConfigSpace) *api.FirmwareError { return &v.Error })
+	}
+
+	// Inventory of all PCI devices
+	fwData.PCIDevices = new(api.PCIDevices)
+	if opts.Enabled(CollectorPCIDevs) {
+		pci.ReportDevices(fwData.PCIDevices, root)
+	} else {
+		fwData.PCIDevices.Error = api.DeniedByPolicy
+	}
 
 	// Advanced Micro Devices Secure Encrypted Virtualization
 	if cpuVendor == cpuid.VendorAMD {
 		fwData.SEV = request.SEV
-		sev.ReportSEVCommands(fwData.SEV)
+		if opts.Enabled(CollectorSEV) {
+			pol.FilterSEVCommands(fwData.SEV, sev.ReportSEVCommands)
+		} else {
+			denyAll(fwData.SEV, func(v *api.SEVCommand) *api.FirmwareError { return &v.Error })
+		}
 	}
 
 	// Advanced Configuration and Power Interface tables
-	acpi.ReportACPITables(&fwData.ACPI)
+	if opts.Enabled(CollectorACPI) {
+		acpi.ReportACPITables(&fwData.ACPI, root)
+	} else {
+		fwData.ACPI.Error = api.DeniedByPolicy
+	}
 
 	// System Management BIOS tables
-	smbios.ReportSMBIOS(&fwData.SMBIOS)
+	if opts.Enabled(CollectorSMBIOS) {
+		smbios.ReportSMBIOS(&fwData.SMBIOS, root)
+	} else {
+		fwData.SMBIOS.Error = api.DeniedByPolicy
+	}
+
+	// Baseboard management controller
+	fwData.BMC = new(api.BMC)
+	if opts.Enabled(CollectorBMC) {
+		ipmi.ReportBMC(fwData.BMC, &fwData.SMBIOS)
+	} else {
+		fwData.BMC.DeviceIDErr = api.DeniedByPolicy
+		fwData.BMC.SystemGUIDErr = api.DeniedByPolicy
+		fwData.BMC.SelfTestErr = api.DeniedByPolicy
+		fwData.BMC.SMBIOSErr = api.DeniedByPolicy
+	}
 
 	// Intel Trusted Execution Technology public space
 	if cpuVendor == cpuid.VendorIntel {
-		txt.ReportTXTPublicSpace(&fwData.TXTPublicSpace)
+		if opts.Enabled(CollectorTXT) {
+			txt.ReportTXTPublicSpace(&fwData.TXTPublicSpace, root)
+		} else {
+			fwData.TXTPublicSpace.Error = api.DeniedByPolicy
+		}
 	}
 
 	// UEFI variables
 	fwData.UEFIVariables = request.UEFIVariables
-	uefivars.ReportUEFIVariables(fwData.UEFIVariables)
+	if opts.Enabled(CollectorUEFI) {
+		pol.FilterUEFIVariables(fwData.UEFIVariables, func(vars []api.UEFIVariable) error {
+			return uefivars.ReportUEFIVariables(vars, root)
+		})
+	} else {
+		denyAll(fwData.UEFIVariables, func(v *api.UEFIVariable) *api.FirmwareError { return &v.Error })
+	}
 
 	// Trusted Platform Module event log
-	if tpmConn != nil {
-		srtmlog.ReportTPM2EventLog(&fwData.TPM2EventLogs, tpmConn)
+	if !opts.Enabled(CollectorEventLog) {
+		fwData.TPM2EventLogs = []api.HashBlob{{Error: api.DeniedByPolicy}}
+	} else if tpmConn != nil {
+		srtmlog.ReportTPM2EventLog(&fwData.TPM2EventLogs, tpmConn, root)
 		fwData.PCPQuoteKeys, _ = srtmlog.ReportPCPQuoteKeys()
 	}
 
 	// Trusted Platform Module 2 properties
 	fwData.TPM2Properties = request.TPM2Properties
-	ReportTPM2Properties(fwData.TPM2Properties, tpmConn)
+	if opts.Enabled(CollectorTPM2) {
+		ReportTPM2Properties(fwData.TPM2Properties, tpmConn)
+	} else {
+		denyAll(fwData.TPM2Properties, func(v *api.TPM2Property) *api.FirmwareError { return &v.Error })
+	}
 
 	// Trusted Platform Module 2 Non-Volatile Random Access Memory
 	for _, nvIndex := range request.TPM2NVRAM {
@@ -130,24 +228,88 @@ func GatherFirmwareData(tpmConn io.ReadWriteCloser, request *api.Configuration)
 
 	// Endpoint protection software
 	fwData.EPPInfo = new(api.EPPInfo)
-	epp.ReportEPP(fwData.EPPInfo)
+	if opts.Enabled(CollectorEPP) {
+		epp.ReportEPP(fwData.EPPInfo, root)
+	} else {
+		fwData.EPPInfo.AntimalwareProcessesErr = api.DeniedByPolicy
+		fwData.EPPInfo.EarlyLaunchDriversErr = api.DeniedByPolicy
+	}
+
+	// Intel SGX capabilities
+	if cpuVendor == cpuid.VendorIntel {
+		fwData.SGX = new(api.SGXInfo)
+		if opts.Enabled(CollectorSGX) {
+			sgx.ReportSGX(fwData.SGX)
+		} else {
+			fwData.SGX.Error = api.DeniedByPolicy
+		}
+	}
+
+	// Intel Boot Guard and BIOS Guard
+	if cpuVendor == cpuid.VendorIntel {
+		fwData.BootGuard = new(api.BootGuard)
+		if opts.Enabled(CollectorBootGuard) {
+			bootguard.ReportBootGuard(fwData.BootGuard, root)
+		} else {
+			fwData.BootGuard.Error = api.DeniedByPolicy
+		}
+	}
+
+	// Intel SPI flash descriptor and write protection
+	if cpuVendor == cpuid.VendorIntel {
+		fwData.FlashProtection = new(api.FlashProtection)
+		if opts.Enabled(CollectorSPI) {
+			biosflash.ReportFlashProtection(fwData.FlashProtection, &fwData.Flash, root)
+		} else {
+			fwData.FlashProtection.Error = api.DeniedByPolicy
+		}
+	}
 
 	// Intel Management Engine
 	if cpuVendor == cpuid.VendorIntel {
 		fwData.ME = request.ME
-		heci.ReportMECommands(fwData.ME)
+		if opts.Enabled(CollectorME) {
+			pol.FilterMEClientCommands(fwData.ME, func(clients []api.MEClientCommands) error {
+				return heci.ReportMECommands(clients, root)
+			})
+		} else {
+			denyAll(fwData.ME, func(v *api.MEClientCommands) *api.FirmwareError { return &v.Error })
+		}
+
+		// firmware version and status from the mei driver. Collected regardless of the outcome of the
+		// commands above so the server can fall back to it if they were denied or failed.
+		if runtime.GOOS == "linux" {
+			fwData.MEStatus = new(api.MEStatus)
+			if opts.Enabled(CollectorME) {
+				heci.ReportMEStatus(fwData.MEStatus, root)
+			} else {
+				fwData.MEStatus.Error = api.DeniedByPolicy
+			}
+		}
 	}
 
 	// Operating System information
-	osinfo.ReportOSInfo(&fwData.OS)
+	if opts.Enabled(CollectorOS) {
+		osinfo.ReportOSInfo(&fwData.OS, root)
+	} else {
+		fwData.OS.Error = api.DeniedByPolicy
+	}
 
 	// Agent information
 	fwData.Agent = &api.Agent{}
-	ReportAgentHash(fwData.Agent)
+	if opts.Enabled(CollectorAgent) {
+		ReportAgentHash(fwData.Agent)
+	} else {
+		fwData.Agent.ImageSHA2.Error = api.DeniedByPolicy
+	}
 
 	// Network Interface Cards
 	fwData.NICs = &api.NICList{}
-	netif.ReportNICs(fwData.NICs)
+	if opts.Enabled(CollectorNIC) {
+		netif.ReportNICs(fwData.NICs, root)
+	} else {
+		fwData.NICs.Error = api.DeniedByPolicy
+	}
 
 	// Intel VT-d registers
 	fwData.VTdRegisterSet.Error = api.NotImplemented
@@ -157,16 +319,88 @@ func GatherFirmwareData(tpmConn io.ReadWriteCloser, request *api.Configuration)
 
 	// FWUPD version and device list
 	if runtime.GOOS != "windows" {
-		fwData.Devices = new(api.Devices)
-		err = fwupd.ReportFWUPD(fwData.Devices)
-		if err != nil {
-			fwData.Devices = nil
+		if opts.Enabled(CollectorFWUPD) {
+			fwData.Devices = new(api.Devices)
+			err = fwupd.ReportFWUPD(fwData.Devices)
+			if err != nil {
+				fwData.Devices = nil
+			}
+		} else {
+			fwData.Devices = &api.Devices{Error: api.DeniedByPolicy}
 		}
 	}
 
 	// UEFI Booot Applications
 	fwData.BootApps = &api.BootApps{}
-	bootapps.ReportBootApps(fwData.BootApps)
+	if opts.Enabled(CollectorBootApps) {
+		bootapps.ReportBootApps(fwData.BootApps, opts.mountESP(), root)
+	} else {
+		fwData.BootApps.ImagesErr = api.DeniedByPolicy
+	}
+
+	// Linux kernel, initramfs and command line
+	if runtime.GOOS == "linux" {
+		fwData.LinuxBoot = new(api.LinuxBoot)
+		if opts.Enabled(CollectorLinuxBoot) {
+			linuxboot.ReportLinuxBoot(fwData.LinuxBoot, opts.mountESP(), root)
+		} else {
+			fwData.LinuxBoot.Error = api.DeniedByPolicy
+		}
+	}
+
+	// custom IMA policies loaded at boot, the measurement log itself is read by the callers
+	if runtime.GOOS == "linux" {
+		fwData.IMAPolicy = new(api.IMAPolicy)
+		if opts.Enabled(CollectorIMA) {
+			ima.ReportIMAPolicy(fwData.IMAPolicy, root)
+		} else {
+			fwData.IMAPolicy.Error = api.DeniedByPolicy
+		}
+	}
+
+	// Linux kernel security settings
+	if runtime.GOOS == "linux" {
+		fwData.KernelSecurity = new(api.KernelSecurity)
+		if opts.Enabled(CollectorKernelSec) {
+			kernelsec.ReportKernelSecurity(fwData.KernelSecurity, root)
+		} else {
+			denyKernelSecurity(fwData.KernelSecurity)
+		}
+	}
+
+	// USB devices and Thunderbolt security levels
+	if runtime.GOOS == "linux" {
+		fwData.Peripherals = new(api.Peripherals)
+		if opts.Enabled(CollectorUSB) {
+			peripherals.ReportPeripherals(fwData.Peripherals, root)
+		} else {
+			fwData.Peripherals.USBErr = api.DeniedByPolicy
+			fwData.Peripherals.ThunderboltErr = api.DeniedByPolicy
+		}
+	}
+
+	// Drive firmware and disk encryption
+	if runtime.GOOS == "linux" {
+		fwData.Storage = new(api.Storage)
+		if opts.Enabled(CollectorStorage) {
+			storage.ReportStorage(fwData.Storage, root)
+		} else {
+			fwData.Storage.DrivesErr = api.DeniedByPolicy
+			fwData.Storage.LUKSErr = api.DeniedByPolicy
+		}
+	}
+
+	// Intel TDX confidential computing event log
+	if tdx.IsTDXGuest() {
+		fwData.TDXEventLog = new(api.HashBlob)
+		if opts.Enabled(CollectorTDX) {
+			tdx.ReportEventLog(fwData.TDXEventLog, root)
+		} else {
+			fwData.TDXEventLog.Error = api.DeniedByPolicy
+		}
+	}
+
+	redact(&fwData, opts)
 
 	log.Trace().Msg("done gathering report data")
 	return fwData
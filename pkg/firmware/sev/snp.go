@@ -0,0 +1,202 @@
+package sev
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+const (
+	defaultSEVGuestDevice = "/dev/sev-guest"
+
+	snpReportDataSize = 64
+	snpRespSize       = 4000 // struct snp_report_resp
+	snpMsgReportHdr   = 32   // MSG_REPORT_RSP header preceding the report
+	snpPageSize       = 4096
+	snpMaxCertPages   = 64
+
+	// upper half of exitinfo2 returned by the hypervisor
+	snpVMMErrInvalidLen = 1
+)
+
+// GUIDs of the certificate table returned with the extended report
+var snpCertNames = map[string]string{
+	"63da758d-e664-4564-adc5-f4b93be8accd": "vcek",
+	"a8074bc2-a25a-483e-aae6-39c045a0b8a1": "vlek",
+	"4ab7b379-bbac-4fe4-a02f-05aef327c782": "ask",
+	"c0b406a4-a803-4952-9743-3fb6014cd0ae": "ark",
+}
+
+// snpGuestDevice issues SNP guest requests, exitInfo is the firmware (lower) and hypervisor (upper) error
+type snpGuestDevice interface {
+	// GetReport issues SNP_GET_REPORT, resp must be snpRespSize bytes long
+	GetReport(reportData [snpReportDataSize]byte, resp []byte) (exitInfo uint64, err error)
+	// GetExtReport issues SNP_GET_EXT_REPORT and returns the certificate table length in bytes, certs must be page aligned
+	GetExtReport(reportData [snpReportDataSize]byte, resp []byte, certs []byte) (certsLen uint32, exitInfo uint64, err error)
+	Close() error
+}
+
+var (
+	sevGuestDevice  = defaultSEVGuestDevice
+	openGuestDevice = openSEVGuest
+)
+
+// IsSNPGuest returns true if we run inside a SEV-SNP confidential VM
+func IsSNPGuest() bool {
+	_, err := os.Stat(sevGuestDevice)
+	return err == nil
+}
+
+// ReportSNPGuest requests an attestation report binding reportData from the AMD SecureProcessor along with the
+// certificate chain of the key signing it
+func ReportSNPGuest(snp *api.SEVSNPReport, reportData []byte) error {
+	log.Trace().Msg("ReportSNPGuest()")
+
+	err := reportSNPGuest(snp, reportData)
+	if err != nil {
+		log.Debug().Err(err).Msg("sev.ReportSNPGuest()")
+		log.Warn().Msg("Failed to get AMD SEV-SNP attestation report")
+		snp.Error = common.ServeApiError(common.MapFSErrors(err))
+	}
+	return err
+}
+
+func reportSNPGuest(snp *api.SEVSNPReport, reportData []byte) error {
+	if len(reportData) > snpReportDataSize {
+		return errors.New("report data too large")
+	}
+	var data [snpReportDataSize]byte
+	copy(data[:], reportData)
+
+	dev, err := openGuestDevice(sevGuestDevice)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+
+	resp := make([]byte, snpRespSize)
+	certs, err := getExtReport(dev, data, resp)
+	if err != nil {
+		// the hypervisor may not support extended requests, the certificates can be fetched from AMD's KDS instead
+		log.Debug().Err(err).Msg("sev: SNP_GET_EXT_REPORT failed, falling back to SNP_GET_REPORT")
+		certs = nil
+		exitInfo, err := dev.GetReport(data, resp)
+		if err := snpError(err, exitInfo); err != nil {
+			return err
+		}
+	}
+
+	report, err := parseReportResponse(resp)
+	if err != nil {
+		return err
+	}
+	snp.Report = report
+
+	if len(certs) > 0 {
+		snp.Certificates, err = parseCertTable(certs)
+		if err != nil {
+			log.Debug().Err(err).Msg("sev: invalid certificate table")
+		}
+	}
+
+	return nil
+}
+
+// getExtReport issues SNP_GET_EXT_REPORT, retrying once with a larger buffer if the certificates don't fit
+func getExtReport(dev snpGuestDevice, data [snpReportDataSize]byte, resp []byte) ([]byte, error) {
+	certs := alignedBuffer(4 * snpPageSize)
+
+	certsLen, exitInfo, err := dev.GetExtReport(data, resp, certs)
+	if err != nil && exitInfo>>32 == snpVMMErrInvalidLen && certsLen > uint32(len(certs)) {
+		if certsLen > snpMaxCertPages*snpPageSize {
+			return nil, fmt.Errorf("certificate table too large: %d bytes", certsLen)
+		}
+		certs = alignedBuffer(int(certsLen))
+		certsLen, exitInfo, err = dev.GetExtReport(data, resp, certs)
+	}
+	if err := snpError(err, exitInfo); err != nil {
+		return nil, err
+	}
+	if int(certsLen) < len(certs) {
+		certs = certs[:certsLen]
+	}
+
+	return certs, nil
+}
+
+func snpError(err error, exitInfo uint64) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, syscall.EIO) && exitInfo != 0 {
+		return common.ErrorNoResponse(fmt.Errorf("%w: firmware error %#x, hypervisor error %#x", err, uint32(exitInfo), uint32(exitInfo>>32)))
+	}
+	return err
+}
+
+// alignedBuffer allocates a page aligned buffer of at least n bytes
+func alignedBuffer(n int) []byte {
+	n = (n + snpPageSize - 1) &^ (snpPageSize - 1)
+	buf := make([]byte, n+snpPageSize)
+	off := snpPageSize - int(uintptr(unsafe.Pointer(&buf[0]))%snpPageSize)
+	if off == snpPageSize {
+		off = 0
+	}
+	return buf[off : off+n]
+}
+
+// parseReportResponse extracts the attestation report from a MSG_REPORT_RSP
+func parseReportResponse(resp []byte) ([]byte, error) {
+	if len(resp) < snpMsgReportHdr {
+		return nil, errors.New("short report response")
+	}
+
+	status := binary.LittleEndian.Uint32(resp[0:4])
+	size := binary.LittleEndian.Uint32(resp[4:8])
+	if status != 0 {
+		return nil, fmt.Errorf("report request failed with status %#x", status)
+	}
+	if size == 0 || int(size) > len(resp)-snpMsgReportHdr {
+		return nil, fmt.Errorf("invalid report size %d", size)
+	}
+
+	return append([]byte(nil), resp[snpMsgReportHdr:snpMsgReportHdr+size]...), nil
+}
+
+// parseCertTable decodes the GUID table of the extended report that is terminated by an all zero entry
+func parseCertTable(certs []byte) (map[string]api.Buffer, error) {
+	ret := make(map[string]api.Buffer)
+	for i := 0; ; i += 24 {
+		if i+24 > len(certs) {
+			return ret, errors.New("unterminated certificate table")
+		}
+
+		guid, err := uuid.FromBytes(certs[i : i+16])
+		if err != nil {
+			return ret, err
+		}
+		offset := binary.LittleEndian.Uint32(certs[i+16:])
+		length := binary.LittleEndian.Uint32(certs[i+20:])
+		if guid == uuid.Nil && offset == 0 && length == 0 {
+			return ret, nil
+		}
+		if uint64(offset)+uint64(length) > uint64(len(certs)) {
+			return ret, fmt.Errorf("certificate %s out of bounds", guid)
+		}
+
+		name, ok := snpCertNames[guid.String()]
+		if !ok {
+			name = guid.String()
+		}
+		ret[name] = api.Buffer(append([]byte(nil), certs[offset:offset+length]...))
+	}
+}
@@ -0,0 +1,80 @@
+package sev
+
+import (
+	"encoding/binary"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"syscall"
+	"unsafe"
+)
+
+// _IOWR('S', n, struct snp_guest_request_ioctl), see include/uapi/linux/sev-guest.h
+const (
+	IOCTL_SNP_GET_REPORT     = 0xc0205300
+	IOCTL_SNP_GET_EXT_REPORT = 0xc0205302
+
+	snpMsgVersion       = 1
+	snpGuestRequestSize = 32  // struct snp_guest_request_ioctl
+	snpReportReqSize    = 96  // struct snp_report_req
+	snpExtReportReqSize = 112 // struct snp_ext_report_req
+)
+
+type sevGuest struct {
+	fd *os.File
+}
+
+func openSEVGuest(path string) (snpGuestDevice, error) {
+	fd, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &sevGuest{fd: fd}, nil
+}
+
+func (g *sevGuest) Close() error {
+	return g.fd.Close()
+}
+
+func (g *sevGuest) GetReport(reportData [snpReportDataSize]byte, resp []byte) (uint64, error) {
+	req := make([]byte, snpReportReqSize)
+	copy(req, reportData[:])
+	// VMPL 0
+
+	return g.request(IOCTL_SNP_GET_REPORT, req, resp)
+}
+
+func (g *sevGuest) GetExtReport(reportData [snpReportDataSize]byte, resp []byte, certs []byte) (uint32, uint64, error) {
+	req := make([]byte, snpExtReportReqSize)
+	copy(req, reportData[:])
+	// turn off GC to prevent it from moving our array while we are using a raw pointer value
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	binary.LittleEndian.PutUint64(req[snpReportReqSize:], uint64(uintptr(unsafe.Pointer(&certs[0]))))
+	binary.LittleEndian.PutUint32(req[snpReportReqSize+8:], uint32(len(certs)))
+
+	exitInfo, err := g.request(IOCTL_SNP_GET_EXT_REPORT, req, resp)
+	runtime.KeepAlive(certs)
+
+	// the kernel updates certs_len with the required size if the buffer was too small
+	return binary.LittleEndian.Uint32(req[snpReportReqSize+8:]), exitInfo, err
+}
+
+func (g *sevGuest) request(ioctl uintptr, req, resp []byte) (uint64, error) {
+	guestReq := make([]byte, snpGuestRequestSize)
+	guestReq[0] = snpMsgVersion
+	// turn off GC to prevent it from moving our array while we are using a raw pointer value
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	// pointers are meant for 64bit only
+	binary.LittleEndian.PutUint64(guestReq[8:], uint64(uintptr(unsafe.Pointer(&req[0]))))
+	binary.LittleEndian.PutUint64(guestReq[16:], uint64(uintptr(unsafe.Pointer(&resp[0]))))
+
+	_, _, ep := syscall.Syscall(syscall.SYS_IOCTL, g.fd.Fd(), ioctl, uintptr(unsafe.Pointer(&guestReq[0])))
+	runtime.KeepAlive(req)
+	runtime.KeepAlive(resp)
+
+	exitInfo := binary.LittleEndian.Uint64(guestReq[24:])
+	if ep != 0 {
+		return exitInfo, syscall.Errno(ep)
+	}
+	return exitInfo, nil
+}
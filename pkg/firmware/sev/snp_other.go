@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sev
+
+import (
+	"errors"
+	"runtime"
+)
+
+func openSEVGuest(path string) (snpGuestDevice, error) {
+	return nil, errors.New("sev.openSEVGuest not implemented on " + runtime.GOOS)
+}
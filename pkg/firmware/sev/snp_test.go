@@ -0,0 +1,143 @@
+package sev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+const testReportSize = 1184
+
+// fakeSEVGuest emulates the sev-guest driver and the PSP
+type fakeSEVGuest struct {
+	Certs       []byte // certificate table, nil if the hypervisor doesn't support extended requests
+	ExtRequests int
+}
+
+func (f *fakeSEVGuest) writeReport(reportData [snpReportDataSize]byte, resp []byte) {
+	binary.LittleEndian.PutUint32(resp[0:], 0)
+	binary.LittleEndian.PutUint32(resp[4:], testReportSize)
+	report := resp[snpMsgReportHdr : snpMsgReportHdr+testReportSize]
+	binary.LittleEndian.PutUint32(report[0:], 2) // version
+	copy(report[0x50:], reportData[:])
+}
+
+func (f *fakeSEVGuest) GetReport(reportData [snpReportDataSize]byte, resp []byte) (uint64, error) {
+	f.writeReport(reportData, resp)
+	return 0, nil
+}
+
+func (f *fakeSEVGuest) GetExtReport(reportData [snpReportDataSize]byte, resp []byte, certs []byte) (uint32, uint64, error) {
+	f.ExtRequests++
+	if f.Certs == nil {
+		return 0, 0, syscall.ENOTTY
+	}
+	if len(certs) < len(f.Certs) {
+		return uint32((len(f.Certs) + snpPageSize - 1) &^ (snpPageSize - 1)), snpVMMErrInvalidLen << 32, syscall.EIO
+	}
+
+	copy(certs, f.Certs)
+	f.writeReport(reportData, resp)
+	return uint32(len(certs)), 0, nil
+}
+
+func (f *fakeSEVGuest) Close() error {
+	return nil
+}
+
+func certTable(certs map[string][]byte) []byte {
+	var table, data bytes.Buffer
+	offset := (len(certs) + 1) * 24
+	for guid, cert := range certs {
+		id := uuid.MustParse(guid)
+		table.Write(id[:])
+		binary.Write(&table, binary.LittleEndian, uint32(offset+data.Len()))
+		binary.Write(&table, binary.LittleEndian, uint32(len(cert)))
+		data.Write(cert)
+	}
+	table.Write(make([]byte, 24))
+	return append(table.Bytes(), data.Bytes()...)
+}
+
+func withFakeSEVGuest(t *testing.T, fake *fakeSEVGuest) {
+	saved := openGuestDevice
+	t.Cleanup(func() { openGuestDevice = saved })
+	openGuestDevice = func(path string) (snpGuestDevice, error) {
+		return fake, nil
+	}
+}
+
+func TestReportSNPGuest(t *testing.T) {
+	vcek := bytes.Repeat([]byte{0x30}, 1300)
+	ask := bytes.Repeat([]byte{0x31}, 1600)
+	ark := bytes.Repeat([]byte{0x32}, 1600)
+	unknown := []byte{1, 2, 3}
+	fake := &fakeSEVGuest{Certs: certTable(map[string][]byte{
+		"63da758d-e664-4564-adc5-f4b93be8accd": vcek,
+		"4ab7b379-bbac-4fe4-a02f-05aef327c782": ask,
+		"c0b406a4-a803-4952-9743-3fb6014cd0ae": ark,
+		"11111111-2222-3333-4444-555555555555": unknown,
+	})}
+	withFakeSEVGuest(t, fake)
+
+	hash := bytes.Repeat([]byte{0xaa}, 32)
+	var snp api.SEVSNPReport
+	assert.NoError(t, ReportSNPGuest(&snp, hash))
+	assert.Equal(t, api.NoError, snp.Error)
+	assert.Len(t, snp.Report, testReportSize)
+	assert.Equal(t, hash, []byte(snp.Report[0x50:0x70]))
+	assert.Equal(t, make([]byte, 32), []byte(snp.Report[0x70:0x90]))
+	assert.Equal(t, map[string]api.Buffer{
+		"vcek":                                 vcek,
+		"ask":                                  ask,
+		"ark":                                  ark,
+		"11111111-2222-3333-4444-555555555555": unknown,
+	}, snp.Certificates)
+	assert.Equal(t, 1, fake.ExtRequests)
+}
+
+func TestReportSNPGuestLargeCertTable(t *testing.T) {
+	fake := &fakeSEVGuest{Certs: certTable(map[string][]byte{
+		"63da758d-e664-4564-adc5-f4b93be8accd": bytes.Repeat([]byte{0x30}, 5*snpPageSize),
+	})}
+	withFakeSEVGuest(t, fake)
+
+	var snp api.SEVSNPReport
+	assert.NoError(t, ReportSNPGuest(&snp, []byte("cookie")))
+	assert.Len(t, snp.Certificates["vcek"], 5*snpPageSize)
+	assert.Equal(t, 2, fake.ExtRequests)
+}
+
+func TestReportSNPGuestNoExtReport(t *testing.T) {
+	withFakeSEVGuest(t, &fakeSEVGuest{})
+
+	var snp api.SEVSNPReport
+	assert.NoError(t, ReportSNPGuest(&snp, []byte("cookie")))
+	assert.Len(t, snp.Report, testReportSize)
+	assert.Empty(t, snp.Certificates)
+}
+
+func TestReportSNPGuestNoDevice(t *testing.T) {
+	sevGuestDevice = filepath.Join(t.TempDir(), "sev-guest")
+	defer func() { sevGuestDevice = defaultSEVGuestDevice }()
+
+	assert.False(t, IsSNPGuest())
+	var snp api.SEVSNPReport
+	assert.Error(t, ReportSNPGuest(&snp, nil))
+	assert.NotEqual(t, api.NoError, snp.Error)
+}
+
+func TestAlignedBuffer(t *testing.T) {
+	for _, n := range []int{1, snpPageSize, 3*snpPageSize + 1} {
+		buf := alignedBuffer(n)
+		assert.GreaterOrEqual(t, len(buf), n)
+		assert.Zero(t, len(buf)%snpPageSize)
+	}
+}
@@ -0,0 +1,142 @@
+package sgx
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/cpuid"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/msr"
+)
+
+const (
+	leafExtendedFeatures = 0x7
+	leafSGX              = 0x12
+
+	MSR_IA32_FEATURE_CONTROL   = 0x3a
+	MSR_IA32_SGXLEPUBKEYHASH0  = 0x8c
+	numLEPubKeyHashMSRs        = 4
+	featureControlLocked       = 1 << 0
+	featureControlSGXLCEnabled = 1 << 17
+	featureControlSGXEnabled   = 1 << 18
+	secsAttributeKSS           = 1 << 7
+	epcSectionTypeValid        = 1
+	epcSectionPropertyCIR      = 1
+	maxEPCSections             = 16
+	cpuid7EBXSGX               = 1 << 2
+	cpuid7ECXSGXLC             = 1 << 30
+	cpuid12EAXSGX1             = 1 << 0
+	cpuid12EAXSGX2             = 1 << 1
+)
+
+var (
+	enclaveDevices   = []string{"/dev/sgx_enclave", "/dev/sgx/enclave", "/dev/isgx"}
+	provisionDevices = []string{"/dev/sgx_provision", "/dev/sgx/provision"}
+
+	// replaced in tests
+	readCPUID = func(eax, ecx uint32) (uint32, uint32, uint32, uint32) {
+		leaf := api.CPUIDLeaf{LeafEAX: eax, LeafECX: ecx}
+		cpuid.ReportCPUIDLeaf(&leaf)
+		return *leaf.EAX, *leaf.EBX, *leaf.ECX, *leaf.EDX
+	}
+	readMSR = func(reg uint32) (uint64, error) {
+		msrs := []api.MSR{{MSR: reg}}
+		if err := msr.ReportMSRs(msrs); err != nil {
+			return 0, err
+		}
+		if len(msrs[0].Values) == 0 {
+			return 0, common.ErrorNoResponse(errors.New("no MSR values"))
+		}
+		return msrs[0].Values[0], nil
+	}
+)
+
+// ReportSGX decodes the SGX capabilities of the CPU and whether firmware and OS enabled it
+func ReportSGX(info *api.SGXInfo) error {
+	log.Trace().Msg("ReportSGX()")
+
+	maxLeaf, _, _, _ := readCPUID(0, 0)
+	if maxLeaf < leafSGX {
+		info.Error = api.NotImplemented
+		return nil
+	}
+	_, ebx, ecx, _ := readCPUID(leafExtendedFeatures, 0)
+	if ebx&cpuid7EBXSGX == 0 {
+		log.Debug().Msg("sgx: not supported by CPU")
+		info.Error = api.NotImplemented
+		return nil
+	}
+	flc := ecx&cpuid7ECXSGXLC != 0
+
+	// capabilities
+	eax, _, _, edx := readCPUID(leafSGX, 0)
+	switch {
+	case eax&cpuid12EAXSGX2 != 0:
+		info.Version = 2
+	case eax&cpuid12EAXSGX1 != 0:
+		info.Version = 1
+	}
+	info.MaxEnclaveSize32 = uint(edx & 0xff)
+	info.MaxEnclaveSize64 = uint((edx >> 8) & 0xff)
+
+	// SECS attributes
+	eax, _, _, _ = readCPUID(leafSGX, 1)
+	info.KSS = eax&secsAttributeKSS != 0
+
+	info.EPC = []api.EnclavePageCache{}
+	for i := uint32(2); i < 2+maxEPCSections; i++ {
+		eax, ebx, ecx, edx := readCPUID(leafSGX, i)
+		if eax&0xf != epcSectionTypeValid {
+			break
+		}
+		info.EPC = append(info.EPC, api.EnclavePageCache{
+			Base:          uint64(eax&0xfffff000) | uint64(ebx&0xfffff)<<32,
+			Size:          uint64(ecx&0xfffff000) | uint64(edx&0xfffff)<<32,
+			CIRProtection: ecx&0xf == epcSectionPropertyCIR,
+		})
+	}
+
+	fc, err := readMSR(MSR_IA32_FEATURE_CONTROL)
+	if err != nil {
+		info.FeatureControlErr = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("sgx.ReportSGX(): IA32_FEATURE_CONTROL")
+	} else {
+		info.FeatureControl = &fc
+		info.Enabled = fc&featureControlLocked != 0 && fc&featureControlSGXEnabled != 0
+		// flexible launch control needs the CPU feature and the firmware has to leave the hash MSRs writable
+		info.FLC = flc && fc&featureControlSGXLCEnabled != 0
+	}
+
+	if info.FLC {
+		hash := make([]byte, 8*numLEPubKeyHashMSRs)
+		for i := uint32(0); i < numLEPubKeyHashMSRs; i++ {
+			val, err := readMSR(MSR_IA32_SGXLEPUBKEYHASH0 + i)
+			if err != nil {
+				info.LEPubKeyHashErr = common.ServeApiError(common.MapFSErrors(err))
+				log.Debug().Err(err).Msg("sgx.ReportSGX(): IA32_SGXLEPUBKEYHASH")
+				hash = nil
+				break
+			}
+			binary.LittleEndian.PutUint64(hash[8*i:], val)
+		}
+		info.LEPubKeyHash = hash
+	}
+
+	info.EnclaveDevice = anyExists(enclaveDevices)
+	info.ProvisionDevice = anyExists(provisionDevices)
+
+	return nil
+}
+
+func anyExists(paths []string) bool {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package sgx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+type cpuidKey struct{ eax, ecx uint32 }
+
+type fakeCPU struct {
+	leafs map[cpuidKey][4]uint32
+	msrs  map[uint32]uint64
+}
+
+func (f *fakeCPU) install(t *testing.T) {
+	savedCPUID, savedMSR := readCPUID, readMSR
+	t.Cleanup(func() { readCPUID, readMSR = savedCPUID, savedMSR })
+
+	readCPUID = func(eax, ecx uint32) (uint32, uint32, uint32, uint32) {
+		v := f.leafs[cpuidKey{eax, ecx}]
+		return v[0], v[1], v[2], v[3]
+	}
+	readMSR = func(reg uint32) (uint64, error) {
+		if v, ok := f.msrs[reg]; ok {
+			return v, nil
+		}
+		return 0, errors.New("no such MSR")
+	}
+}
+
+// Ice Lake server with SGX2, FLC and two EPC sections
+var iceLake = fakeCPU{
+	leafs: map[cpuidKey][4]uint32{
+		{0, 0}:    {0x1b, 0x756e6547, 0x6c65746e, 0x49656e69},
+		{7, 0}:    {0, 1 << 2, 1 << 30, 0},
+		{0x12, 0}: {0x3, 0, 0, 0x381f},
+		{0x12, 1}: {0xb6, 0, 0x1f, 0},
+		{0x12, 2}: {0x80000001, 0x0, 0x7e000001, 0x0},
+		{0x12, 3}: {0x00000001, 0x1, 0x80000001, 0x1},
+		{0x12, 4}: {0, 0, 0, 0},
+	},
+	msrs: map[uint32]uint64{
+		MSR_IA32_FEATURE_CONTROL: featureControlLocked | featureControlSGXEnabled | featureControlSGXLCEnabled,
+		0x8c:                     0x1111111111111111,
+		0x8d:                     0x2222222222222222,
+		0x8e:                     0x3333333333333333,
+		0x8f:                     0x4444444444444444,
+	},
+}
+
+func TestReportSGX(t *testing.T) {
+	iceLake.install(t)
+	dev := filepath.Join(t.TempDir(), "sgx_enclave")
+	assert.NoError(t, os.WriteFile(dev, nil, 0600))
+	enclaveDevices = []string{dev}
+	provisionDevices = []string{filepath.Join(t.TempDir(), "sgx_provision")}
+
+	var info api.SGXInfo
+	assert.NoError(t, ReportSGX(&info))
+	assert.Equal(t, api.NoError, info.Error)
+	assert.Equal(t, uint(2), info.Version)
+	assert.True(t, info.Enabled)
+	assert.True(t, info.FLC)
+	assert.True(t, info.KSS)
+	assert.Equal(t, uint(31), info.MaxEnclaveSize32)
+	assert.Equal(t, uint(56), info.MaxEnclaveSize64)
+	assert.Equal(t, []api.EnclavePageCache{
+		{Base: 0x80000000, Size: 0x7e000000, CIRProtection: true},
+		{Base: 0x100000000, Size: 0x180000000, CIRProtection: true},
+	}, info.EPC)
+	assert.Len(t, info.LEPubKeyHash, 32)
+	assert.Equal(t, byte(0x11), info.LEPubKeyHash[0])
+	assert.Equal(t, byte(0x44), info.LEPubKeyHash[31])
+	assert.True(t, info.EnclaveDevice)
+	assert.False(t, info.ProvisionDevice)
+}
+
+func TestReportSGXDisabledByFirmware(t *testing.T) {
+	cpu := fakeCPU{leafs: iceLake.leafs, msrs: map[uint32]uint64{MSR_IA32_FEATURE_CONTROL: featureControlLocked}}
+	cpu.install(t)
+
+	var info api.SGXInfo
+	assert.NoError(t, ReportSGX(&info))
+	assert.Equal(t, uint(2), info.Version)
+	assert.False(t, info.Enabled)
+	assert.False(t, info.FLC)
+	assert.Empty(t, info.LEPubKeyHash)
+}
+
+func TestReportSGXNoMSRAccess(t *testing.T) {
+	cpu := fakeCPU{leafs: iceLake.leafs}
+	cpu.install(t)
+
+	var info api.SGXInfo
+	assert.NoError(t, ReportSGX(&info))
+	assert.Nil(t, info.FeatureControl)
+	assert.Equal(t, api.UnknownError, info.FeatureControlErr)
+	assert.Len(t, info.EPC, 2)
+}
+
+func TestReportSGXUnsupported(t *testing.T) {
+	cpu := fakeCPU{leafs: map[cpuidKey][4]uint32{{0, 0}: {0xd, 0, 0, 0}}}
+	cpu.install(t)
+
+	var info api.SGXInfo
+	assert.NoError(t, ReportSGX(&info))
+	assert.Equal(t, api.NotImplemented, info.Error)
+	assert.Equal(t, uint(0), info.Version)
+
+	// leaf 0x12 exists but CPUID.7 doesn't report SGX
+	cpu = fakeCPU{leafs: map[cpuidKey][4]uint32{{0, 0}: {0x1b, 0, 0, 0}, {7, 0}: {0, 0, 0, 0}}}
+	cpu.install(t)
+
+	info = api.SGXInfo{}
+	assert.NoError(t, ReportSGX(&info))
+	assert.Equal(t, api.NotImplemented, info.Error)
+	assert.Equal(t, uint(0), info.Version)
+}
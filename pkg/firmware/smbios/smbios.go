@@ -6,10 +6,10 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func ReportSMBIOS(table *api.HashBlob) error {
+func ReportSMBIOS(table *api.HashBlob, root common.Root) error {
 	log.Trace().Msg("ReportSMBIOS()")
 
-	buf, err := readSMBIOS()
+	buf, err := readSMBIOS(root)
 	if err != nil {
 		table.Error = common.ServeApiError(common.MapFSErrors(err))
 		log.Debug().Err(err).Msg("smbios.ReportSMBIOS()")
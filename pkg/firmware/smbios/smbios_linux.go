@@ -3,12 +3,35 @@ package smbios
 import (
 	"bytes"
 	"io"
+	"os"
 
 	"github.com/digitalocean/go-smbios/smbios"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+const (
+	sysfsEntryPoint = "/sys/firmware/dmi/tables/smbios_entry_point"
+	sysfsDMI        = "/sys/firmware/dmi/tables/DMI"
 )
 
-func readSMBIOS() ([]byte, error) {
-	// find SMBIOS data in operating system-specific location.
+func readSMBIOS(root common.Root) ([]byte, error) {
+	epf, err := os.Open(root.Path(sysfsEntryPoint))
+	if os.IsNotExist(err) && root.IsHost() {
+		// kernels before 4.2 don't export the tables, go-smbios scans /dev/mem instead
+		return readStream()
+	} else if err != nil {
+		return nil, err
+	}
+	defer epf.Close()
+
+	if _, err := smbios.ParseEntryPoint(epf); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(root.Path(sysfsDMI))
+}
+
+func readStream() ([]byte, error) {
 	rc, _, err := smbios.Stream()
 	if err != nil {
 		return nil, err
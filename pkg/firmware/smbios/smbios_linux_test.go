@@ -0,0 +1,52 @@
+package smbios
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+	"github.com/stretchr/testify/assert"
+)
+
+// entryPoint64 returns an SMBIOS 3.0 entry point for a table of the given size
+func entryPoint64(size uint32) string {
+	ep := make([]byte, 24)
+	copy(ep, "_SM3_")
+	ep[6] = 24
+	ep[7], ep[8] = 3, 3
+	ep[10] = 1
+	binary.LittleEndian.PutUint32(ep[12:], size)
+	binary.LittleEndian.PutUint64(ep[16:], 0x7b8f2000)
+	var sum byte
+	for _, b := range ep {
+		sum += b
+	}
+	ep[5] = -sum
+	return string(ep)
+}
+
+func TestReportSMBIOSRoot(t *testing.T) {
+	root := t.TempDir()
+	dmi := "\x7f\x04\x00\x00\x00\x00"
+	commontest.WriteFile(t, filepath.Join(root, sysfsEntryPoint), entryPoint64(uint32(len(dmi))))
+	commontest.WriteFile(t, filepath.Join(root, sysfsDMI), dmi)
+
+	var table api.HashBlob
+	assert.NoError(t, ReportSMBIOS(&table, common.Root(root)))
+	assert.Equal(t, api.Buffer(dmi), table.Data)
+	assert.Empty(t, table.Error)
+
+	// corrupt entry point
+	commontest.WriteFile(t, filepath.Join(root, sysfsEntryPoint), "_SM3_garbage")
+	table = api.HashBlob{}
+	assert.Error(t, ReportSMBIOS(&table, common.Root(root)))
+	assert.Nil(t, table.Data)
+
+	// no fallback to the host's tables
+	table = api.HashBlob{}
+	assert.Error(t, ReportSMBIOS(&table, common.Root(t.TempDir())))
+	assert.Equal(t, api.NoResponse, table.Error)
+}
@@ -5,8 +5,10 @@ package smbios
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readSMBIOS() ([]byte, error) {
+func readSMBIOS(root common.Root) ([]byte, error) {
 	return nil, errors.New("smbios.ReadSmbios not implemented on " + runtime.GOOS)
 }
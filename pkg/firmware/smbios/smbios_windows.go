@@ -5,9 +5,11 @@ import (
 	"io"
 
 	"github.com/digitalocean/go-smbios/smbios"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readSMBIOS() ([]byte, error) {
+func readSMBIOS(root common.Root) ([]byte, error) {
 	// find SMBIOS data in operating system-specific location.
 	rc, _, err := smbios.Stream()
 	if err != nil {
@@ -11,10 +11,10 @@ import (
 
 var ErrNoEventLog = common.ErrorNoResponse(errors.New("no event log found"))
 
-func ReportTPM2EventLog(eventlog *[]api.HashBlob, conn io.ReadWriteCloser) error {
+func ReportTPM2EventLog(eventlog *[]api.HashBlob, conn io.ReadWriteCloser, root common.Root) error {
 	log.Trace().Msg("ReportTPM2EventLog()")
 
-	logBufs, err := readTPM2EventLog(conn)
+	logBufs, err := readTPM2EventLog(conn, root)
 	if err != nil {
 		log.Debug().Err(err).Msg("srtmlog.ReportTPM2EventLog()")
 		log.Warn().Msg("Failed to read TPM 2.0 event log")
@@ -4,12 +4,14 @@ import (
 	"io"
 	"os"
 	"path"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readTPM2EventLog(conn io.ReadWriteCloser) ([][]byte, error) {
+func readTPM2EventLog(conn io.ReadWriteCloser, root common.Root) ([][]byte, error) {
 	f, ok := conn.(*os.File)
 	if ok {
-		p := path.Join("/sys/kernel/security/", path.Base(f.Name()), "/binary_bios_measurements")
+		p := path.Join(root.Path("/sys/kernel/security/"), path.Base(f.Name()), "/binary_bios_measurements")
 		buf, err := os.ReadFile(p)
 		if len(buf) == 0 {
 			return nil, ErrNoEventLog
@@ -7,9 +7,11 @@ import (
 	"errors"
 	"io"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readTPM2EventLog(conn io.ReadWriteCloser) ([][]byte, error) {
+func readTPM2EventLog(conn io.ReadWriteCloser, root common.Root) ([][]byte, error) {
 	return nil, errors.New("srtmlog.ReadTPM2EventLog not implemented on " + runtime.GOOS)
 }
 
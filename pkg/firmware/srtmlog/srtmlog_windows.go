@@ -282,7 +282,7 @@ func readTPM2EventLogRegistry() ([][]byte, error) {
 	return [][]byte{val}, nil
 }
 
-func readTPM2EventLog(conn io.ReadWriteCloser) ([][]byte, error) {
+func readTPM2EventLog(conn io.ReadWriteCloser, root common.Root) ([][]byte, error) {
 	// try to get all current WBCL logs from on-disk location first
 	// this is more reliable than getAllTCGLogs() and more complete than GetTCGLog()
 	logs, err := getAllWBCLLogsFromDisk()
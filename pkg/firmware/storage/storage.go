@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	nvmeIdentifySize  = 4096
+	nvmeFwSlotLogSize = 512
+
+	// offsets into the Identify Controller data structure
+	nvmeIdentifyOACS = 256
+	nvmeIdentifyFRMW = 260
+
+	nvmeOACSSecurity = 1 << 0
+	nvmeOACSFirmware = 1 << 2
+
+	opalHeaderSize     = 48
+	opalFeatureLocking = 0x0002
+
+	luksMagic       = "LUKS\xba\xbe"
+	luks1HdrSize    = 592
+	luks2BinSize    = 4096
+	luks2MaxSize    = 4 << 20
+	luks1Slots      = 8
+	luks1SlotSize   = 48
+	luks1SlotActive = 0x00ac71f3
+)
+
+var opalSSCs = map[uint16]string{
+	0x0100: "enterprise",
+	0x0200: "opal1",
+	0x0203: "opal2",
+	0x0301: "opalite",
+	0x0302: "pyrite1",
+	0x0303: "pyrite2",
+	0x0304: "ruby",
+}
+
+// ReportStorage reports the firmware of all drives and the LUKS volumes on them. Drives and volumes carry their own
+// errors, the function only fails if the drives could not be listed.
+func ReportStorage(st *api.Storage, root common.Root) error {
+	log.Trace().Msg("ReportStorage()")
+
+	drives, err := readDrives(root)
+	if err != nil {
+		log.Debug().Err(err).Msg("storage.ReportStorage() drives")
+		log.Warn().Msg("Failed to list storage devices")
+		st.DrivesErr = common.ServeApiError(common.MapFSErrors(err))
+		st.LUKSErr = st.DrivesErr
+		return err
+	}
+	st.Drives = drives
+
+	volumes, err := readLUKSVolumes(root)
+	if err != nil {
+		log.Debug().Err(err).Msg("storage.ReportStorage() luks")
+		st.LUKSErr = common.ServeApiError(common.MapFSErrors(err))
+	}
+	st.LUKS = volumes
+
+	return nil
+}
+
+func cString(buf []byte) string {
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// parseIdentifyController decodes the NVMe Identify Controller data structure
+func parseIdentifyController(buf []byte) (*api.NVMeController, error) {
+	if len(buf) < nvmeIdentifySize {
+		return nil, errors.New("truncated identify controller data")
+	}
+	oacs := binary.LittleEndian.Uint16(buf[nvmeIdentifyOACS:])
+	return &api.NVMeController{
+		Vendor:           binary.LittleEndian.Uint16(buf[0:]),
+		SubsystemVendor:  binary.LittleEndian.Uint16(buf[2:]),
+		SecuritySendRecv: oacs&nvmeOACSSecurity != 0,
+		FirmwareDownload: oacs&nvmeOACSFirmware != 0,
+		FirmwareUpdates:  buf[nvmeIdentifyFRMW],
+	}, nil
+}
+
+// parseFirmwareSlots decodes the Firmware Slot Information log page, FRMW bits 3:1 are the number of slots
+func parseFirmwareSlots(ctrl *api.NVMeController, buf []byte) error {
+	if len(buf) < nvmeFwSlotLogSize {
+		return errors.New("truncated firmware slot log")
+	}
+	ctrl.ActiveSlot = buf[0] & 0x7
+	ctrl.NextSlot = buf[0] >> 4 & 0x7
+
+	slots := int(ctrl.FirmwareUpdates>>1) & 0x7
+	ctrl.FirmwareSlots = make([]string, slots)
+	for i := range ctrl.FirmwareSlots {
+		ctrl.FirmwareSlots[i] = cString(buf[8+8*i : 16+8*i])
+	}
+	return nil
+}
+
+// parseOpalDiscovery decodes the TCG Storage Level 0 Discovery response
+func parseOpalDiscovery(buf []byte) (*api.OpalStatus, error) {
+	if len(buf) < opalHeaderSize {
+		return nil, errors.New("truncated level 0 discovery header")
+	}
+	// the length excludes the length field itself
+	end := int(binary.BigEndian.Uint32(buf)) + 4
+	if end > len(buf) || end < opalHeaderSize {
+		return nil, errors.New("invalid level 0 discovery length")
+	}
+
+	var status api.OpalStatus
+	for desc := buf[opalHeaderSize:end]; len(desc) >= 4; {
+		code := binary.BigEndian.Uint16(desc)
+		size := 4 + int(desc[3])
+		if size > len(desc) {
+			return nil, errors.New("truncated level 0 discovery feature")
+		}
+
+		if code == opalFeatureLocking && size > 4 {
+			bits := desc[4]
+			status.LockingSupported = bits&(1<<0) != 0
+			status.LockingEnabled = bits&(1<<1) != 0
+			status.Locked = bits&(1<<2) != 0
+			status.MediaEncryption = bits&(1<<3) != 0
+			status.MBREnabled = bits&(1<<4) != 0
+			status.MBRDone = bits&(1<<5) != 0
+		} else if ssc, ok := opalSSCs[code]; ok && status.SSC == "" {
+			status.SSC = ssc
+		}
+		desc = desc[size:]
+	}
+
+	return &status, nil
+}
+
+type luks2Metadata struct {
+	Keyslots map[string]json.RawMessage `json:"keyslots"`
+	Tokens   map[string]struct {
+		Type        string   `json:"type"`
+		Keyslots    []string `json:"keyslots"`
+		TPM2PCRs    []int    `json:"tpm2-pcrs"`
+		TPM2PCRBank string   `json:"tpm2-pcr-bank"`
+		TPM2PIN     bool     `json:"tpm2-pin"`
+	} `json:"tokens"`
+	Segments map[string]struct {
+		Encryption string `json:"encryption"`
+	} `json:"segments"`
+}
+
+// parseLUKSHeader decodes a LUKS1 header or the binary header and JSON metadata of LUKS2. It returns nil if buf
+// doesn't start with a LUKS header. For LUKS2 buf has to contain the whole JSON area.
+func parseLUKSHeader(buf []byte) (*api.LUKSVolume, error) {
+	if len(buf) < len(luksMagic)+2 || string(buf[:len(luksMagic)]) != luksMagic {
+		return nil, nil
+	}
+
+	vol := api.LUKSVolume{Version: int(binary.BigEndian.Uint16(buf[6:]))}
+	switch vol.Version {
+	case 1:
+		if len(buf) < luks1HdrSize {
+			return nil, errors.New("truncated LUKS1 header")
+		}
+		vol.Cipher = cString(buf[8:40]) + "-" + cString(buf[40:72])
+		vol.UUID = cString(buf[168:208])
+		for i := 0; i < luks1Slots; i++ {
+			if binary.BigEndian.Uint32(buf[208+luks1SlotSize*i:]) == luks1SlotActive {
+				vol.Keyslots++
+			}
+		}
+
+	case 2:
+		if len(buf) < luks2BinSize {
+			return nil, errors.New("truncated LUKS2 header")
+		}
+		vol.Label = cString(buf[24:72])
+		vol.UUID = cString(buf[168:208])
+		size := binary.BigEndian.Uint64(buf[8:])
+		if size <= luks2BinSize || size > uint64(len(buf)) {
+			return nil, errors.New("invalid LUKS2 header size")
+		}
+
+		var meta luks2Metadata
+		if err := json.Unmarshal(bytes.TrimRight(buf[luks2BinSize:size], "\x00"), &meta); err != nil {
+			return nil, err
+		}
+		vol.Keyslots = len(meta.Keyslots)
+		if seg, ok := meta.Segments["0"]; ok {
+			vol.Cipher = seg.Encryption
+		}
+
+		ids := make([]string, 0, len(meta.Tokens))
+		for id := range meta.Tokens {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			tok := meta.Tokens[id]
+			vol.Tokens = append(vol.Tokens, api.LUKSToken{
+				Type:        tok.Type,
+				Keyslots:    tok.Keyslots,
+				TPM2PCRs:    tok.TPM2PCRs,
+				TPM2PCRBank: tok.TPM2PCRBank,
+				TPM2PIN:     tok.TPM2PIN,
+			})
+		}
+
+	default:
+		return nil, errors.New("unknown LUKS version")
+	}
+
+	return &vol, nil
+}
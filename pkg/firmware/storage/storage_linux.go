@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	nvmeIoctlAdminCmd = 0xc0484e41 // _IOWR('N', 0x41, struct nvme_passthru_cmd)
+	iocOpalDiscovery  = 0x401070ef // _IOW('p', 239, struct opal_discovery)
+
+	nvmeAdminGetLogPage = 0x02
+	nvmeAdminIdentify   = 0x06
+	nvmeIdentifyCNSCtrl = 1
+	nvmeLogFwSlot       = 0x03
+
+	opalDiscoverySize = 4096
+)
+
+var (
+	sysfs = "/sys"
+	devfs = "/dev"
+)
+
+// struct nvme_passthru_cmd from linux/nvme_ioctl.h
+type nvmePassthruCmd struct {
+	Opcode      uint8
+	Flags       uint8
+	Rsvd1       uint16
+	NSID        uint32
+	Cdw2, Cdw3  uint32
+	Metadata    uint64
+	Addr        uint64
+	MetadataLen uint32
+	DataLen     uint32
+	Cdw10       uint32
+	Cdw11       uint32
+	Cdw12       uint32
+	Cdw13       uint32
+	Cdw14       uint32
+	Cdw15       uint32
+	TimeoutMs   uint32
+	Result      uint32
+}
+
+// struct opal_discovery from linux/sed-opal.h
+type opalDiscoveryArg struct {
+	Data uint64
+	Size uint64
+}
+
+func ioctl(path string, req uintptr, arg unsafe.Pointer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg)); errno != 0 {
+		if errno == syscall.ENOTTY || errno == syscall.EOPNOTSUPP {
+			return common.Error(api.NotImplemented, errno)
+		}
+		return errno
+	}
+	return nil
+}
+
+func nvmeAdminCommand(path string, cmd *nvmePassthruCmd, buf []byte) error {
+	cmd.Addr = uint64(uintptr(unsafe.Pointer(&buf[0])))
+	cmd.DataLen = uint32(len(buf))
+	err := ioctl(path, nvmeIoctlAdminCmd, unsafe.Pointer(cmd))
+	runtime.KeepAlive(buf)
+	return err
+}
+
+// readNVMe sends Identify Controller and reads the Firmware Slot Information log page, replaced in tests
+var readNVMe = func(ctrl string) ([]byte, []byte, error) {
+	path := filepath.Join(devfs, ctrl)
+	identify := make([]byte, nvmeIdentifySize)
+	cmd := nvmePassthruCmd{Opcode: nvmeAdminIdentify, Cdw10: nvmeIdentifyCNSCtrl}
+	if err := nvmeAdminCommand(path, &cmd, identify); err != nil {
+		return nil, nil, err
+	}
+
+	slots := make([]byte, nvmeFwSlotLogSize)
+	numd := uint32(nvmeFwSlotLogSize/4 - 1)
+	cmd = nvmePassthruCmd{Opcode: nvmeAdminGetLogPage, NSID: 0xffffffff, Cdw10: nvmeLogFwSlot | numd<<16}
+	if err := nvmeAdminCommand(path, &cmd, slots); err != nil {
+		return identify, nil, err
+	}
+	return identify, slots, nil
+}
+
+// readOpalDiscovery lets the kernel run Level 0 Discovery on a self encrypting drive, replaced in tests
+var readOpalDiscovery = func(disk string) ([]byte, error) {
+	buf := make([]byte, opalDiscoverySize)
+	arg := opalDiscoveryArg{Data: uint64(uintptr(unsafe.Pointer(&buf[0]))), Size: uint64(len(buf))}
+	err := ioctl(filepath.Join(devfs, disk), iocOpalDiscovery, unsafe.Pointer(&arg))
+	runtime.KeepAlive(buf)
+	return buf, err
+}
+
+func readString(path string) string {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// readSerial returns the serial of NVMe and ATA drives or the Unit Serial Number VPD page of SCSI disks
+func readSerial(dir string) string {
+	if serial := readString(filepath.Join(dir, "serial")); serial != "" {
+		return serial
+	}
+	vpd, err := os.ReadFile(filepath.Join(dir, "vpd_pg80"))
+	if err != nil || len(vpd) < 4 || 4+int(vpd[3]) > len(vpd) {
+		return ""
+	}
+	return strings.TrimSpace(string(vpd[4 : 4+int(vpd[3])]))
+}
+
+// transport guesses how a SCSI disk is attached from its position in the device tree
+func transport(name string, root common.Root) string {
+	if strings.HasPrefix(name, "nvme") {
+		return "nvme"
+	} else if strings.HasPrefix(name, "mmcblk") {
+		return "mmc"
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root.Path(sysfs), "block", name))
+	if err != nil {
+		return "scsi"
+	}
+	switch {
+	case strings.Contains(path, "/usb"):
+		return "usb"
+	case strings.Contains(path, "/ata"):
+		return "ata"
+	default:
+		return "scsi"
+	}
+}
+
+// readDrives lists all disks backed by hardware. Virtual block devices like loop, dm and md have no device link.
+func readDrives(root common.Root) ([]api.StorageDrive, error) {
+	entries, err := os.ReadDir(filepath.Join(root.Path(sysfs), "block"))
+	if err != nil {
+		return nil, err
+	}
+
+	drives := []api.StorageDrive{}
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join(root.Path(sysfs), "block", name, "device")
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+
+		drive := api.StorageDrive{
+			Name:      name,
+			Transport: transport(name, root),
+			Model:     readString(filepath.Join(dir, "model")),
+			Serial:    readSerial(dir),
+			Firmware:  readString(filepath.Join(dir, "firmware_rev")),
+			Removable: readString(filepath.Join(root.Path(sysfs), "block", name, "removable")) == "1",
+		}
+		if drive.Firmware == "" {
+			// SCSI and ATA disks
+			drive.Firmware = readString(filepath.Join(dir, "rev"))
+		}
+
+		if drive.Transport == "nvme" {
+			reportNVMe(&drive, dir)
+		}
+
+		buf, err := readOpalDiscovery(name)
+		if err == nil {
+			drive.Opal, err = parseOpalDiscovery(buf)
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("drive", name).Msg("storage.readDrives() opal")
+			drive.OpalErr = common.ServeApiError(common.MapFSErrors(err))
+		}
+
+		drives = append(drives, drive)
+	}
+	return drives, nil
+}
+
+// reportNVMe queries the controller of an NVMe namespace, the device link of the namespace points to it
+func reportNVMe(drive *api.StorageDrive, dir string) {
+	ctrl, err := os.Readlink(dir)
+	if err == nil {
+		var identify, slots []byte
+		identify, slots, err = readNVMe(filepath.Base(ctrl))
+		if identify != nil {
+			drive.NVMe, err = parseIdentifyController(identify)
+		}
+		if err == nil && slots != nil {
+			err = parseFirmwareSlots(drive.NVMe, slots)
+		}
+	}
+	if err != nil {
+		log.Debug().Err(err).Str("drive", drive.Name).Msg("storage.reportNVMe()")
+		drive.NVMeErr = common.ServeApiError(common.MapFSErrors(err))
+	}
+}
+
+// readLUKSVolumes checks all block devices including partitions and device mapper targets for LUKS headers
+func readLUKSVolumes(root common.Root) ([]api.LUKSVolume, error) {
+	entries, err := os.ReadDir(filepath.Join(root.Path(sysfs), "class", "block"))
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := []api.LUKSVolume{}
+	var lastErr error
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+
+		vol, err := readLUKSHeader(filepath.Join(devfs, name))
+		if err != nil {
+			log.Debug().Err(err).Str("device", name).Msg("storage.readLUKSVolumes()")
+			lastErr = err
+			continue
+		}
+		if vol != nil {
+			vol.Device = name
+			volumes = append(volumes, *vol)
+		}
+	}
+
+	// reading block devices needs root, report why nothing was found
+	if len(volumes) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return volumes, nil
+}
+
+func readLUKSHeader(path string) (*api.LUKSVolume, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, luks2BinSize)
+	if _, err := io.ReadFull(f, buf); errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if string(buf[:len(luksMagic)]) != luksMagic {
+		return nil, nil
+	}
+
+	// the LUKS2 JSON area follows the binary header
+	if binary.BigEndian.Uint16(buf[6:]) == 2 {
+		size := binary.BigEndian.Uint64(buf[8:])
+		if size > luks2BinSize && size <= luks2MaxSize {
+			buf = append(buf, make([]byte, size-luks2BinSize)...)
+			if _, err := io.ReadFull(f, buf[luks2BinSize:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return parseLUKSHeader(buf)
+}
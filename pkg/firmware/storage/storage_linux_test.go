@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common/commontest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportStorage(t *testing.T) {
+	oldNVMe, oldOpal := readNVMe, readOpalDiscovery
+	t.Cleanup(func() { readNVMe, readOpalDiscovery = oldNVMe, oldOpal })
+	sysfs, devfs = t.TempDir(), t.TempDir()
+
+	readNVMe = func(ctrl string) ([]byte, []byte, error) {
+		assert.Equal(t, "nvme0", ctrl)
+		return testIdentify(), testFirmwareSlots(), nil
+	}
+	readOpalDiscovery = func(disk string) ([]byte, error) {
+		if disk == "nvme0n1" {
+			return testOpalDiscovery(0x09), nil
+		}
+		return nil, common.Error(api.NotImplemented, syscall.ENOTTY)
+	}
+
+	commontest.WriteFile(t, filepath.Join(sysfs, "devices/nvme0/model"), "Samsung SSD 980 PRO 1TB\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "devices/nvme0/serial"), "S4EWNX0R123456\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "devices/nvme0/firmware_rev"), "5B2QGXA7\n")
+	assert.NoError(t, os.MkdirAll(filepath.Join(sysfs, "block/nvme0n1"), 0755))
+	assert.NoError(t, os.Symlink("../../devices/nvme0", filepath.Join(sysfs, "block/nvme0n1/device")))
+	commontest.WriteFile(t, filepath.Join(sysfs, "block/sda/device/model"), "Ultra Fit\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "block/sda/device/rev"), "1.00\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "block/sda/device/vpd_pg80"), "\x00\x80\x00\x0a4C53000123")
+	commontest.WriteFile(t, filepath.Join(sysfs, "block/sda/removable"), "1\n")
+	commontest.WriteFile(t, filepath.Join(sysfs, "block/loop0/size"), "0\n")
+
+	for _, name := range []string{"nvme0n1", "nvme0n1p1", "nvme0n1p2", "sda", "loop0"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(sysfs, "class/block", name), 0755))
+	}
+	commontest.WriteFile(t, filepath.Join(devfs, "nvme0n1"), string(make([]byte, 8192)))
+	commontest.WriteFile(t, filepath.Join(devfs, "nvme0n1p1"), "short")
+	commontest.WriteFile(t, filepath.Join(devfs, "nvme0n1p2"), string(testLUKS2(testLUKS2Metadata)))
+
+	var st api.Storage
+	assert.NoError(t, ReportStorage(&st, ""))
+	assert.Empty(t, st.DrivesErr)
+	assert.Empty(t, st.LUKSErr)
+
+	if assert.Len(t, st.Drives, 2) {
+		nvme := st.Drives[0]
+		assert.Equal(t, "nvme0n1", nvme.Name)
+		assert.Equal(t, "nvme", nvme.Transport)
+		assert.Equal(t, "S4EWNX0R123456", nvme.Serial)
+		assert.Equal(t, "5B2QGXA7", nvme.Firmware)
+		assert.Equal(t, []string{"5B2QGXA7", "5B2QGXA9", ""}, nvme.NVMe.FirmwareSlots)
+		assert.True(t, nvme.Opal.MediaEncryption)
+
+		assert.Equal(t, api.StorageDrive{
+			Name: "sda", Transport: "scsi", Model: "Ultra Fit", Serial: "4C53000123", Firmware: "1.00",
+			Removable: true, OpalErr: api.NotImplemented,
+		}, st.Drives[1])
+	}
+
+	if assert.Len(t, st.LUKS, 1) {
+		assert.Equal(t, "nvme0n1p2", st.LUKS[0].Device)
+		assert.Equal(t, "systemd-tpm2", st.LUKS[0].Tokens[0].Type)
+	}
+}
+
+func TestReportStorageNoPermission(t *testing.T) {
+	oldNVMe, oldOpal := readNVMe, readOpalDiscovery
+	t.Cleanup(func() { readNVMe, readOpalDiscovery = oldNVMe, oldOpal })
+	sysfs, devfs = t.TempDir(), t.TempDir()
+
+	readNVMe = func(ctrl string) ([]byte, []byte, error) { return nil, nil, syscall.EACCES }
+	readOpalDiscovery = func(disk string) ([]byte, error) { return nil, syscall.EACCES }
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(sysfs, "devices/nvme0"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(sysfs, "block/nvme0n1"), 0755))
+	assert.NoError(t, os.Symlink("../../devices/nvme0", filepath.Join(sysfs, "block/nvme0n1/device")))
+	assert.NoError(t, os.MkdirAll(filepath.Join(sysfs, "class/block/nvme0n1"), 0755))
+
+	var st api.Storage
+	assert.NoError(t, ReportStorage(&st, ""))
+	if assert.Len(t, st.Drives, 1) {
+		assert.Equal(t, api.NoPermission, st.Drives[0].NVMeErr)
+		assert.Equal(t, api.NoPermission, st.Drives[0].OpalErr)
+		assert.Nil(t, st.Drives[0].NVMe)
+	}
+	// the device node is missing
+	assert.Equal(t, api.NoResponse, st.LUKSErr)
+
+	sysfs = filepath.Join(t.TempDir(), "missing")
+	st = api.Storage{}
+	err := ReportStorage(&st, "")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Equal(t, api.NoResponse, st.DrivesErr)
+}
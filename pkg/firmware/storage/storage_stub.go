@@ -0,0 +1,21 @@
+//go:build !linux
+
+package storage
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+var errNotImplemented = errors.New("storage not implemented on " + runtime.GOOS)
+
+func readDrives(root common.Root) ([]api.StorageDrive, error) {
+	return nil, errNotImplemented
+}
+
+func readLUKSVolumes(root common.Root) ([]api.LUKSVolume, error) {
+	return nil, errNotImplemented
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testIdentify() []byte {
+	buf := make([]byte, nvmeIdentifySize)
+	binary.LittleEndian.PutUint16(buf[0:], 0x144d)
+	binary.LittleEndian.PutUint16(buf[2:], 0x144d)
+	copy(buf[4:], "S4EWNX0R123456      ")
+	copy(buf[24:], "Samsung SSD 980 PRO 1TB                 ")
+	copy(buf[64:], "5B2QGXA7")
+	binary.LittleEndian.PutUint16(buf[nvmeIdentifyOACS:], 0x17)
+	buf[nvmeIdentifyFRMW] = 0x16 // 3 slots, activation without reset
+	return buf
+}
+
+func testFirmwareSlots() []byte {
+	buf := make([]byte, nvmeFwSlotLogSize)
+	buf[0] = 0x21 // slot 1 active, slot 2 on next reset
+	copy(buf[8:], "5B2QGXA7")
+	copy(buf[16:], "5B2QGXA9")
+	return buf
+}
+
+func TestParseNVMe(t *testing.T) {
+	ctrl, err := parseIdentifyController(testIdentify())
+	assert.NoError(t, err)
+	assert.NoError(t, parseFirmwareSlots(ctrl, testFirmwareSlots()))
+	assert.Equal(t, &api.NVMeController{
+		Vendor:           0x144d,
+		SubsystemVendor:  0x144d,
+		FirmwareDownload: true,
+		SecuritySendRecv: true,
+		FirmwareUpdates:  0x16,
+		FirmwareSlots:    []string{"5B2QGXA7", "5B2QGXA9", ""},
+		ActiveSlot:       1,
+		NextSlot:         2,
+	}, ctrl)
+
+	_, err = parseIdentifyController(make([]byte, 512))
+	assert.Error(t, err)
+}
+
+func testOpalDiscovery(locking byte) []byte {
+	buf := make([]byte, opalHeaderSize)
+	// TPer feature, Locking feature, Opal SSC V2
+	buf = append(buf, 0x00, 0x01, 0x10, 0x0c, 0x11, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	buf = append(buf, 0x00, 0x02, 0x10, 0x0c, locking, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	buf = append(buf, 0x02, 0x03, 0x10, 0x10, 0x10, 0x01, 0, 0x02, 0, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf, uint32(len(buf)-4))
+	// the kernel hands out a fixed size buffer
+	return append(buf, make([]byte, 64)...)
+}
+
+func TestParseOpalDiscovery(t *testing.T) {
+	status, err := parseOpalDiscovery(testOpalDiscovery(0x1f))
+	assert.NoError(t, err)
+	assert.Equal(t, &api.OpalStatus{
+		SSC:              "opal2",
+		LockingSupported: true,
+		LockingEnabled:   true,
+		Locked:           true,
+		MediaEncryption:  true,
+		MBREnabled:       true,
+	}, status)
+
+	status, err = parseOpalDiscovery(testOpalDiscovery(0x09))
+	assert.NoError(t, err)
+	assert.True(t, status.MediaEncryption)
+	assert.False(t, status.LockingEnabled)
+
+	_, err = parseOpalDiscovery(testOpalDiscovery(0)[:60])
+	assert.Error(t, err)
+}
+
+func testLUKS1() []byte {
+	buf := make([]byte, luks1HdrSize)
+	copy(buf, luksMagic)
+	binary.BigEndian.PutUint16(buf[6:], 1)
+	copy(buf[8:], "aes")
+	copy(buf[40:], "xts-plain64")
+	copy(buf[168:], "4e2b2b6c-5b7e-4a8e-9a36-0b3b0e0f1a11")
+	binary.BigEndian.PutUint32(buf[208:], luks1SlotActive)
+	binary.BigEndian.PutUint32(buf[208+luks1SlotSize:], 0xdead)
+	binary.BigEndian.PutUint32(buf[208+2*luks1SlotSize:], luks1SlotActive)
+	return buf
+}
+
+const testLUKS2Metadata = `{
+  "keyslots": {"0": {"type": "luks2"}, "1": {"type": "luks2"}},
+  "tokens": {
+    "0": {"type": "systemd-tpm2", "keyslots": ["1"], "tpm2-pcrs": [7], "tpm2-pcr-bank": "sha256", "tpm2-pin": false}
+  },
+  "segments": {"0": {"type": "crypt", "encryption": "aes-xts-plain64"}},
+  "digests": {}, "config": {}
+}`
+
+func testLUKS2(metadata string) []byte {
+	buf := make([]byte, 0x4000)
+	copy(buf, luksMagic)
+	binary.BigEndian.PutUint16(buf[6:], 2)
+	binary.BigEndian.PutUint64(buf[8:], uint64(len(buf)))
+	copy(buf[24:], "root")
+	copy(buf[168:], "9f1c5f3a-2d6e-4b7a-8c1d-5e4f3a2b1c0d")
+	copy(buf[luks2BinSize:], metadata)
+	return buf
+}
+
+func TestParseLUKSHeader(t *testing.T) {
+	vol, err := parseLUKSHeader(testLUKS1())
+	assert.NoError(t, err)
+	assert.Equal(t, &api.LUKSVolume{
+		Version: 1, UUID: "4e2b2b6c-5b7e-4a8e-9a36-0b3b0e0f1a11", Cipher: "aes-xts-plain64", Keyslots: 2,
+	}, vol)
+
+	vol, err = parseLUKSHeader(testLUKS2(testLUKS2Metadata))
+	assert.NoError(t, err)
+	assert.Equal(t, &api.LUKSVolume{
+		Version: 2, UUID: "9f1c5f3a-2d6e-4b7a-8c1d-5e4f3a2b1c0d", Label: "root", Cipher: "aes-xts-plain64",
+		Keyslots: 2,
+		Tokens:   []api.LUKSToken{{Type: "systemd-tpm2", Keyslots: []string{"1"}, TPM2PCRs: []int{7}, TPM2PCRBank: "sha256"}},
+	}, vol)
+
+	_, err = parseLUKSHeader(testLUKS2("{broken"))
+	assert.Error(t, err)
+
+	vol, err = parseLUKSHeader(make([]byte, 4096))
+	assert.NoError(t, err)
+	assert.Nil(t, vol)
+}
@@ -0,0 +1,97 @@
+package tdx
+
+import (
+	"errors"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+const (
+	defaultTDXGuestDevice = "/dev/tdx_guest"
+	defaultCCELPath       = "/sys/firmware/acpi/tables/data/CCEL"
+
+	reportDataSize = 64
+	tdReportSize   = 1024
+	measurementLen = 48 // SHA-384
+
+	// offsets into TDREPORT_STRUCT, TDINFO_STRUCT follows REPORTMACSTRUCT and TEE_TCB_INFO
+	tdInfoOffset = 512
+	mrtdOffset   = tdInfoOffset + 16
+	rtmrOffset   = tdInfoOffset + 16 + 4*measurementLen
+	numRTMRs     = 4
+)
+
+var (
+	tdxGuestDevice = defaultTDXGuestDevice
+	ccelPath       = defaultCCELPath
+
+	// replaced in tests
+	getTDReport = readTDReport
+	getTDQuote  = readTDQuote
+)
+
+// IsTDXGuest returns true if we run inside an Intel TDX trust domain
+func IsTDXGuest() bool {
+	_, err := os.Stat(tdxGuestDevice)
+	return err == nil
+}
+
+// ReportTDX requests a TDREPORT binding reportData from the TDX module and, if a quoting service is available, a quote
+// over the same data
+func ReportTDX(report *api.TDXReport, reportData []byte, root common.Root) error {
+	log.Trace().Msg("ReportTDX()")
+
+	if len(reportData) > reportDataSize {
+		return errors.New("report data too large")
+	}
+	var data [reportDataSize]byte
+	copy(data[:], reportData)
+
+	tdReport, err := getTDReport(data)
+	if err == nil && len(tdReport) != tdReportSize {
+		err = errors.New("invalid TDREPORT size")
+	}
+	if err != nil {
+		report.Error = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("tdx.ReportTDX()")
+		log.Warn().Msg("Failed to get Intel TDX report")
+		return err
+	}
+	report.Report = tdReport
+	report.MRTD = api.Buffer(tdReport[mrtdOffset : mrtdOffset+measurementLen])
+	for i := 0; i < numRTMRs; i++ {
+		off := rtmrOffset + i*measurementLen
+		report.RTMRs = append(report.RTMRs, api.Buffer(tdReport[off:off+measurementLen]))
+	}
+
+	quote, err := getTDQuote(data, root)
+	if err != nil {
+		// most hosts don't run a quote generation service
+		report.QuoteErr = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("tdx.ReportTDX(): quote")
+	} else {
+		report.Quote = quote
+	}
+
+	return nil
+}
+
+// ReportEventLog reads the confidential computing event log whose events are measured into the RTMRs
+func ReportEventLog(eventLog *api.HashBlob, root common.Root) error {
+	log.Trace().Msg("ReportEventLog()")
+
+	buf, err := os.ReadFile(root.Path(ccelPath))
+	if err != nil {
+		eventLog.Error = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("tdx.ReportEventLog()")
+		log.Warn().Msg("Failed to read Intel TDX event log")
+		return err
+	}
+	eventLog.Data = buf
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package tdx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+// _IOWR('T', 1, struct tdx_report_req), see include/uapi/linux/tdx-guest.h
+const IOCTL_TDX_CMD_GET_REPORT0 = 0xc4405401
+
+var tsmReportDir = "/sys/kernel/config/tsm/report"
+
+func readTDReport(reportData [reportDataSize]byte) ([]byte, error) {
+	fd, err := os.OpenFile(tdxGuestDevice, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	// struct tdx_report_req holds both buffers inline
+	var req [reportDataSize + tdReportSize]byte
+	copy(req[:], reportData[:])
+	_, _, ep := syscall.Syscall(syscall.SYS_IOCTL, fd.Fd(), IOCTL_TDX_CMD_GET_REPORT0, uintptr(unsafe.Pointer(&req[0])))
+	if ep != 0 {
+		return nil, syscall.Errno(ep)
+	}
+
+	return append([]byte(nil), req[reportDataSize:]...), nil
+}
+
+// readTDQuote gets a quote via the configfs-tsm report interface, this requires a quote generation service on the host
+func readTDQuote(reportData [reportDataSize]byte, root common.Root) ([]byte, error) {
+	dir, err := os.MkdirTemp(root.Path(tsmReportDir), "guard-")
+	if err != nil {
+		return nil, err
+	}
+	// configfs entries are removed with rmdir
+	defer syscall.Rmdir(dir)
+
+	provider, err := os.ReadFile(filepath.Join(dir, "provider"))
+	if err != nil {
+		return nil, err
+	}
+	if p := strings.TrimSpace(string(provider)); p != "tdx_guest" {
+		return nil, fmt.Errorf("unexpected TSM provider %s", p)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "inblob"), reportData[:], 0); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(filepath.Join(dir, "outblob"))
+}
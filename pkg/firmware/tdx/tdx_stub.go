@@ -0,0 +1,18 @@
+//go:build !linux
+
+package tdx
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+func readTDReport(reportData [reportDataSize]byte) ([]byte, error) {
+	return nil, errors.New("tdx.readTDReport not implemented on " + runtime.GOOS)
+}
+
+func readTDQuote(reportData [reportDataSize]byte, root common.Root) ([]byte, error) {
+	return nil, errors.New("tdx.readTDQuote not implemented on " + runtime.GOOS)
+}
@@ -0,0 +1,88 @@
+package tdx
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+func fakeTDReport(reportData [reportDataSize]byte) ([]byte, error) {
+	report := make([]byte, tdReportSize)
+	// REPORTMACSTRUCT.REPORTDATA
+	copy(report[128:], reportData[:])
+	copy(report[mrtdOffset:], bytes.Repeat([]byte{0xdd}, measurementLen))
+	for i := 0; i < numRTMRs; i++ {
+		copy(report[rtmrOffset+i*measurementLen:], bytes.Repeat([]byte{byte(i + 1)}, measurementLen))
+	}
+	return report, nil
+}
+
+func withFakes(t *testing.T, report func([reportDataSize]byte) ([]byte, error), quote func([reportDataSize]byte, common.Root) ([]byte, error)) {
+	savedReport, savedQuote := getTDReport, getTDQuote
+	t.Cleanup(func() { getTDReport, getTDQuote = savedReport, savedQuote })
+	getTDReport, getTDQuote = report, quote
+}
+
+func TestReportTDX(t *testing.T) {
+	withFakes(t, fakeTDReport, func(reportData [reportDataSize]byte, _ common.Root) ([]byte, error) {
+		return append([]byte("quote"), reportData[:]...), nil
+	})
+
+	hash := bytes.Repeat([]byte{0xaa}, 32)
+	var report api.TDXReport
+	assert.NoError(t, ReportTDX(&report, hash, ""))
+	assert.Equal(t, api.NoError, report.Error)
+	assert.Len(t, report.Report, tdReportSize)
+	assert.Equal(t, hash, []byte(report.Report[128:160]))
+	assert.Equal(t, api.Buffer(bytes.Repeat([]byte{0xdd}, measurementLen)), report.MRTD)
+	assert.Len(t, report.RTMRs, numRTMRs)
+	for i, rtmr := range report.RTMRs {
+		assert.Equal(t, api.Buffer(bytes.Repeat([]byte{byte(i + 1)}, measurementLen)), rtmr)
+	}
+	assert.Equal(t, []byte("quote"), []byte(report.Quote[:5]))
+	assert.Equal(t, hash, []byte(report.Quote[5:37]))
+}
+
+func TestReportTDXNoQuote(t *testing.T) {
+	withFakes(t, fakeTDReport, func([reportDataSize]byte, common.Root) ([]byte, error) {
+		return nil, os.ErrNotExist
+	})
+
+	var report api.TDXReport
+	assert.NoError(t, ReportTDX(&report, nil, ""))
+	assert.Len(t, report.RTMRs, numRTMRs)
+	assert.Empty(t, report.Quote)
+	assert.Equal(t, api.NoResponse, report.QuoteErr)
+}
+
+func TestReportTDXFailure(t *testing.T) {
+	withFakes(t, func([reportDataSize]byte) ([]byte, error) {
+		return nil, errors.New("ioctl failed")
+	}, nil)
+
+	var report api.TDXReport
+	assert.Error(t, ReportTDX(&report, nil, ""))
+	assert.Equal(t, api.UnknownError, report.Error)
+	assert.Empty(t, report.RTMRs)
+}
+
+func TestReportEventLog(t *testing.T) {
+	ccelPath = filepath.Join(t.TempDir(), "CCEL")
+	defer func() { ccelPath = defaultCCELPath }()
+
+	var eventLog api.HashBlob
+	assert.Error(t, ReportEventLog(&eventLog, ""))
+	assert.Equal(t, api.NoResponse, eventLog.Error)
+
+	assert.NoError(t, os.WriteFile(ccelPath, []byte("event log"), 0644))
+	eventLog = api.HashBlob{}
+	assert.NoError(t, ReportEventLog(&eventLog, ""))
+	assert.Equal(t, api.Buffer("event log"), eventLog.Data)
+}
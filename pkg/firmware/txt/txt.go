@@ -6,10 +6,10 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func ReportTXTPublicSpace(pubSpace *api.HashBlob) error {
+func ReportTXTPublicSpace(pubSpace *api.HashBlob, root common.Root) error {
 	log.Trace().Msg("ReportTXTPublicSpace()")
 
-	buf, err := readTXTPublicSpace()
+	buf, err := readTXTPublicSpace(root)
 	if err != nil {
 		pubSpace.Error = common.ServeApiError(common.MapFSErrors(err))
 		log.Debug().Err(err).Msg("txt.ReportTXTPublicSpace()")
@@ -12,8 +12,8 @@ const (
 	txtPublicRegionMmap = 0xFED30000
 )
 
-func readTXTPublicSpace() ([]byte, error) {
-	f, err := os.Open(txtPubSpaceFilePath)
+func readTXTPublicSpace(root common.Root) ([]byte, error) {
+	f, err := os.Open(root.Path(txtPubSpaceFilePath))
 	if os.IsNotExist(err) {
 		// we tried mmap() but that only returns 0xff
 		f, err = os.Open(common.DefaultDevMemPath)
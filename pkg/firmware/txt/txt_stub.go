@@ -5,8 +5,10 @@ package txt
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readTXTPublicSpace() ([]byte, error) {
+func readTXTPublicSpace(root common.Root) ([]byte, error) {
 	return nil, errors.New("txt.ReadTXTPublicSpace not implemented on " + runtime.GOOS)
 }
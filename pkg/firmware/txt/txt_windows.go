@@ -1,7 +1,10 @@
 package txt
 
-import "github.com/immune-gmbh/agent/v3/pkg/firmware/immunecpu"
+import (
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/immunecpu"
+)
 
-func readTXTPublicSpace() ([]byte, error) {
+func readTXTPublicSpace(root common.Root) ([]byte, error) {
 	return immunecpu.ReadTxtPublicSpace()
 }
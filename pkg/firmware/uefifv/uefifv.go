@@ -0,0 +1,327 @@
+package uefifv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/ulikunitz/xz/lzma"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+const (
+	fvHeaderSize          = 56 // EFI_FIRMWARE_VOLUME_HEADER without the block map
+	fvSignatureOffset     = 40
+	fvAttribErasePolarity = 0x800
+
+	ffsHeaderSize      = 24
+	ffsLargeHeaderSize = 32
+	ffsAttribLargeFile = 0x01
+	ffsStateDataValid  = 0x04
+	ffsStateDeleted    = 0x10
+	ffsTypeRaw         = 0x01
+	ffsTypePad         = 0xf0
+
+	sectionHeaderSize     = 4
+	sectionCompression    = 0x01
+	sectionGUIDDefined    = 0x02
+	sectionUI             = 0x15
+	sectionFVImage        = 0x17
+	guidedProcessRequired = 0x01
+	compressionNone       = 0
+	lzmaHeaderSize        = 13 // properties and uncompressed size
+
+	// nesting limit for volumes inside files inside volumes
+	maxDepth = 8
+	// limit for the uncompressed size of a single LZMA section
+	maxDecompressed = 64 << 20
+)
+
+var (
+	fvSignature = []byte("_FVH")
+
+	fileSystemFFS2 = uuid.MustParse("8c8ce578-8a3d-4f1c-9935-896185c32dd3")
+	fileSystemFFS3 = uuid.MustParse("5473c07a-3dcb-4dca-bd6f-1e9689e7349a")
+
+	// EDK2 LzmaCustomDecompress, the variant with the x86 branch filter isn't supported
+	lzmaCompress = uuid.MustParse("ee4e5898-3914-4259-9d6e-dc7bd79403cf")
+
+	fileTypes = map[byte]string{
+		0x01: "raw",
+		0x02: "freeform",
+		0x03: "sec_core",
+		0x04: "pei_core",
+		0x05: "dxe_core",
+		0x06: "peim",
+		0x07: "driver",
+		0x08: "combined_peim_driver",
+		0x09: "application",
+		0x0a: "smm",
+		0x0b: "firmware_volume_image",
+		0x0c: "combined_smm_dxe",
+		0x0d: "smm_core",
+		0x0e: "mm_standalone",
+		0x0f: "mm_core_standalone",
+	}
+
+	ErrNoVolumes = errors.New("no firmware volumes")
+)
+
+// ReportFirmwareVolumes builds an inventory of all firmware volumes and FFS files in the flash dump. LZMA sections are
+// unpacked, files inside Tiano compressed sections are not listed.
+func ReportFirmwareVolumes(fw *api.UEFIFirmware, flash *api.HashBlob) error {
+	log.Trace().Msg("ReportFirmwareVolumes()")
+
+	var err error
+	if len(flash.Data) == 0 {
+		err = common.ErrorNoResponse(errors.New("no flash dump"))
+		if flash.Error != api.NoError {
+			err = common.Error(flash.Error, errors.New("no flash dump"))
+		}
+	} else {
+		fw.Volumes, err = ParseImage(flash.Data)
+	}
+	if err != nil {
+		fw.Error = common.ServeApiError(common.MapFSErrors(err))
+		log.Debug().Err(err).Msg("uefifv.ReportFirmwareVolumes()")
+		log.Warn().Msg("Failed to parse UEFI firmware volumes")
+		return err
+	}
+
+	return nil
+}
+
+// ParseImage finds all firmware volumes in image and returns them and the volumes nested inside them
+func ParseImage(image []byte) ([]api.UEFIFirmwareVolume, error) {
+	var volumes []api.UEFIFirmwareVolume
+	for off := 0; off < len(image); {
+		i := bytes.Index(image[off:], fvSignature)
+		if i < 0 {
+			break
+		}
+		start := off + i - fvSignatureOffset
+		if start < 0 {
+			off += i + len(fvSignature)
+			continue
+		}
+		size, ok := volumeSize(image[start:])
+		if !ok {
+			off += i + len(fvSignature)
+			continue
+		}
+		volumes = parseVolume(volumes, image[start:start+size], uint64(start), "", 0)
+		off = start + size
+	}
+	if len(volumes) == 0 {
+		return nil, common.ErrorNoResponse(ErrNoVolumes)
+	}
+	return volumes, nil
+}
+
+// guid decodes a mixed endian EFI_GUID
+func guid(b []byte) string {
+	var id uuid.UUID
+	copy(id[:], b[:16])
+	id[0], id[1], id[2], id[3] = id[3], id[2], id[1], id[0]
+	id[4], id[5] = id[5], id[4]
+	id[6], id[7] = id[7], id[6]
+	return id.String()
+}
+
+func uint24(b []byte) uint64 {
+	return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16
+}
+
+func align(off, n int) int {
+	return (off + n - 1) &^ (n - 1)
+}
+
+// volumeSize validates the volume header and returns the size of the volume
+func volumeSize(buf []byte) (int, bool) {
+	if len(buf) < fvHeaderSize || !bytes.Equal(buf[fvSignatureOffset:fvSignatureOffset+4], fvSignature) {
+		return 0, false
+	}
+	length := binary.LittleEndian.Uint64(buf[32:])
+	hdrLen := int(binary.LittleEndian.Uint16(buf[48:]))
+	if hdrLen < fvHeaderSize || hdrLen%2 != 0 || length < uint64(hdrLen) || length > uint64(len(buf)) {
+		return 0, false
+	}
+	var sum uint16
+	for i := 0; i < hdrLen; i += 2 {
+		sum += binary.LittleEndian.Uint16(buf[i:])
+	}
+	return int(length), sum == 0
+}
+
+func parseVolume(volumes []api.UEFIFirmwareVolume, fv []byte, offset uint64, parent string, depth int) []api.UEFIFirmwareVolume {
+	fsGUID := guid(fv[16:])
+	vol := api.UEFIFirmwareVolume{
+		FileSystem: fsGUID,
+		Offset:     offset,
+		Size:       uint64(len(fv)),
+		Parent:     parent,
+	}
+	filesOff := int(binary.LittleEndian.Uint16(fv[48:]))
+	if extOff := int(binary.LittleEndian.Uint16(fv[52:])); extOff != 0 && extOff+20 <= len(fv) {
+		vol.Name = guid(fv[extOff:])
+		filesOff = extOff + int(binary.LittleEndian.Uint32(fv[extOff+16:]))
+	}
+	idx := len(volumes)
+	volumes = append(volumes, vol)
+
+	ffs3 := fsGUID == fileSystemFFS3.String()
+	if !ffs3 && fsGUID != fileSystemFFS2.String() {
+		// NVRAM and vendor specific volumes
+		return volumes
+	}
+	empty := byte(0)
+	if binary.LittleEndian.Uint32(fv[44:])&fvAttribErasePolarity != 0 {
+		empty = 0xff
+	}
+
+	files := []api.UEFIFirmwareFile{}
+	for off := align(filesOff, 8); off+ffsHeaderSize <= len(fv); {
+		hdr := fv[off : off+ffsHeaderSize]
+		if bytes.Count(hdr, []byte{empty}) == ffsHeaderSize {
+			break
+		}
+		typ, attr, state := hdr[18], hdr[19], hdr[23]
+		if empty == 0xff {
+			state = ^state
+		}
+		size, hdrLen := uint24(hdr[20:]), ffsHeaderSize
+		if ffs3 && attr&ffsAttribLargeFile != 0 && off+ffsLargeHeaderSize <= len(fv) {
+			size, hdrLen = binary.LittleEndian.Uint64(fv[off+24:]), ffsLargeHeaderSize
+		}
+		if size < uint64(hdrLen) || size > uint64(len(fv)-off) {
+			log.Debug().Msgf("uefifv: corrupt file header at %#x", offset+uint64(off))
+			break
+		}
+		file := fv[off : off+int(size)]
+
+		if typ != ffsTypePad && state&ffsStateDataValid != 0 && state&ffsStateDeleted == 0 {
+			name := guid(hdr)
+			sum := sha256.Sum256(file)
+			f := api.UEFIFirmwareFile{
+				GUID:   name,
+				Type:   fileType(typ),
+				Size:   size,
+				Sha256: sum[:],
+			}
+			if typ != ffsTypeRaw {
+				volumes = parseSections(volumes, &f, file[hdrLen:], name, depth)
+			}
+			files = append(files, f)
+		}
+		off = align(off+int(size), 8)
+	}
+	volumes[idx].Files = files
+
+	return volumes
+}
+
+func fileType(typ byte) string {
+	if name, ok := fileTypes[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("%#02x", typ)
+}
+
+// parseSections extracts the file name and nested volumes from a section stream
+func parseSections(volumes []api.UEFIFirmwareVolume, file *api.UEFIFirmwareFile, buf []byte, parent string, depth int) []api.UEFIFirmwareVolume {
+	if depth >= maxDepth {
+		return volumes
+	}
+	for off := 0; off+sectionHeaderSize <= len(buf); {
+		size, hdrLen := uint24(buf[off:]), sectionHeaderSize
+		if size == 0xffffff && off+8 <= len(buf) {
+			size, hdrLen = uint64(binary.LittleEndian.Uint32(buf[off+4:])), 8
+		}
+		if size < uint64(hdrLen) || size > uint64(len(buf)-off) {
+			break
+		}
+		typ, body := buf[off+3], buf[off+hdrLen:off+int(size)]
+
+		switch typ {
+		case sectionUI:
+			file.Name = ucs2(body)
+		case sectionFVImage:
+			if n, ok := volumeSize(body); ok {
+				volumes = parseVolume(volumes, body[:n], 0, parent, depth+1)
+			}
+		case sectionCompression:
+			// EFI_COMPRESSION_SECTION: uncompressed length, compression type
+			if len(body) >= 5 && body[4] == compressionNone {
+				volumes = parseSections(volumes, file, body[5:], parent, depth+1)
+			} else {
+				file.Compressed = true
+			}
+		case sectionGUIDDefined:
+			// EFI_GUID_DEFINED_SECTION: section definition GUID, data offset, attributes
+			if len(body) < 20 {
+				break
+			}
+			dataOff := int(binary.LittleEndian.Uint16(body[16:])) - hdrLen
+			attr := binary.LittleEndian.Uint16(body[18:])
+			if dataOff < 20 || dataOff > len(body) {
+				file.Compressed = true
+			} else if attr&guidedProcessRequired == 0 {
+				volumes = parseSections(volumes, file, body[dataOff:], parent, depth+1)
+			} else if guid(body) != lzmaCompress.String() {
+				// Tiano, LZMA with x86 filter or signed sections
+				file.Compressed = true
+			} else if data, err := decompressLZMA(body[dataOff:]); err != nil {
+				log.Debug().Err(err).Msgf("uefifv.parseSections(): file %s", file.GUID)
+				file.Compressed = true
+			} else {
+				volumes = parseSections(volumes, file, data, parent, depth+1)
+			}
+		}
+		off = align(off+int(size), 4)
+	}
+	return volumes
+}
+
+// decompressLZMA unpacks a section compressed by the EDK2 LzmaCompress tool, it uses the LZMA alone format
+func decompressLZMA(buf []byte) ([]byte, error) {
+	if len(buf) < lzmaHeaderSize {
+		return nil, errors.New("short LZMA header")
+	}
+	size := binary.LittleEndian.Uint64(buf[5:])
+	if size > maxDecompressed && size != math.MaxUint64 {
+		return nil, fmt.Errorf("LZMA section too large: %d bytes", size)
+	}
+	r, err := lzma.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxDecompressed+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDecompressed {
+		return nil, errors.New("LZMA section too large")
+	}
+	return data, nil
+}
+
+func ucs2(buf []byte) string {
+	u := make([]uint16, 0, len(buf)/2)
+	for i := 0; i+1 < len(buf); i += 2 {
+		c := binary.LittleEndian.Uint16(buf[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
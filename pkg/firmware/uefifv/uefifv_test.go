@@ -0,0 +1,201 @@
+package uefifv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz/lzma"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+const (
+	testDriver = "2f1b6d8c-9a1f-4e3b-8b8f-7c2a35a1d001"
+	testPEIM   = "2f1b6d8c-9a1f-4e3b-8b8f-7c2a35a1d002"
+	testFVFile = "2f1b6d8c-9a1f-4e3b-8b8f-7c2a35a1d003"
+	testLZMA   = "2f1b6d8c-9a1f-4e3b-8b8f-7c2a35a1d004"
+	testFVName = "2f1b6d8c-9a1f-4e3b-8b8f-7c2a35a1d0ff"
+	lzmaGUID   = "ee4e5898-3914-4259-9d6e-dc7bd79403cf"
+)
+
+func efiGUID(s string) []byte {
+	id := uuid.MustParse(s)
+	b := append([]byte{}, id[:]...)
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+	return b
+}
+
+func section(typ byte, body []byte) []byte {
+	size := 4 + len(body)
+	buf := []byte{byte(size), byte(size >> 8), byte(size >> 16), typ}
+	buf = append(buf, body...)
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func uiSection(name string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, append(utf16.Encode([]rune(name)), 0))
+	return section(sectionUI, buf.Bytes())
+}
+
+// ffsFile builds a file with erase polarity 1
+func ffsFile(name string, typ byte, sections ...[]byte) []byte {
+	body := bytes.Join(sections, nil)
+	size := ffsHeaderSize + len(body)
+	hdr := append(efiGUID(name), 0, 0, typ, 0, byte(size), byte(size>>8), byte(size>>16), ^byte(0x07))
+	return append(hdr, body...)
+}
+
+func volume(name string, files ...[]byte) []byte {
+	var body []byte
+	for _, f := range files {
+		for len(body)%8 != 0 {
+			body = append(body, 0xff)
+		}
+		body = append(body, f...)
+	}
+	// header, block map, extended header
+	hdr := make([]byte, fvHeaderSize+16)
+	copy(hdr[16:], efiGUID(fileSystemFFS2.String()))
+	copy(hdr[40:], fvSignature)
+	binary.LittleEndian.PutUint32(hdr[44:], fvAttribErasePolarity)
+	binary.LittleEndian.PutUint16(hdr[48:], uint16(len(hdr)))
+	binary.LittleEndian.PutUint16(hdr[52:], uint16(len(hdr)))
+	hdr = append(hdr, efiGUID(name)...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, 20)
+	for len(hdr)%8 != 0 {
+		hdr = append(hdr, 0xff)
+	}
+
+	fv := append(hdr, body...)
+	for len(fv)%0x100 != 0 {
+		fv = append(fv, 0xff)
+	}
+	binary.LittleEndian.PutUint64(fv[32:], uint64(len(fv)))
+	var sum uint16
+	for i := 0; i < fvHeaderSize+16; i += 2 {
+		sum += binary.LittleEndian.Uint16(fv[i:])
+	}
+	binary.LittleEndian.PutUint16(fv[50:], -sum)
+	return fv
+}
+
+func TestParseImage(t *testing.T) {
+	driver := ffsFile(testDriver, 0x07, section(0x10, []byte("MZ driver")), uiSection("FakeDxe"))
+	peim := ffsFile(testPEIM, 0x06, section(0x10, []byte("MZ peim")))
+	nested := volume(testFVName, peim)
+	lzma := append(efiGUID(lzmaGUID), 24, 0, guidedProcessRequired, 0)
+	fv := volume(testFVName,
+		driver,
+		ffsFile(uuid.Nil.String(), ffsTypePad, make([]byte, 16)),
+		ffsFile(testFVFile, 0x0b, section(sectionFVImage, nested)),
+		ffsFile(testLZMA, 0x0b, section(sectionGUIDDefined, append(lzma, 0x5d, 0, 0))),
+	)
+	image := append(bytes.Repeat([]byte{0xff}, 0x1000), fv...)
+	image = append(image, bytes.Repeat([]byte{0xff}, 0x1000)...)
+
+	volumes, err := ParseImage(image)
+	assert.NoError(t, err)
+	assert.Len(t, volumes, 2)
+
+	top := volumes[0]
+	assert.Equal(t, testFVName, top.Name)
+	assert.Equal(t, fileSystemFFS2.String(), top.FileSystem)
+	assert.Equal(t, uint64(0x1000), top.Offset)
+	assert.Equal(t, uint64(len(fv)), top.Size)
+	assert.Len(t, top.Files, 3)
+
+	sum := sha256.Sum256(driver)
+	assert.Equal(t, api.UEFIFirmwareFile{
+		GUID:   testDriver,
+		Type:   "driver",
+		Name:   "FakeDxe",
+		Size:   uint64(len(driver)),
+		Sha256: sum[:],
+	}, top.Files[0])
+	assert.Equal(t, "firmware_volume_image", top.Files[1].Type)
+	assert.False(t, top.Files[1].Compressed)
+	assert.Equal(t, testLZMA, top.Files[2].GUID)
+	assert.True(t, top.Files[2].Compressed)
+
+	inner := volumes[1]
+	assert.Equal(t, testFVFile, inner.Parent)
+	assert.Len(t, inner.Files, 1)
+	assert.Equal(t, testPEIM, inner.Files[0].GUID)
+	assert.Equal(t, "peim", inner.Files[0].Type)
+}
+
+// lzmaSection wraps sections into a GUID-defined section compressed like the EDK2 LzmaCompress tool does
+func lzmaSection(t *testing.T, sections ...[]byte) []byte {
+	data := bytes.Join(sections, nil)
+	var buf bytes.Buffer
+	w, err := lzma.WriterConfig{Size: int64(len(data))}.NewWriter(&buf)
+	assert.NoError(t, err)
+	_, err = w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	hdr := append(efiGUID(lzmaGUID), 24, 0, guidedProcessRequired, 0)
+	return section(sectionGUIDDefined, append(hdr, buf.Bytes()...))
+}
+
+func TestParseImageLZMA(t *testing.T) {
+	peim := ffsFile(testPEIM, 0x06, section(0x10, []byte("MZ peim")), uiSection("FakePei"))
+	nested := volume(testFVName, peim)
+	fv := volume(testFVName, ffsFile(testLZMA, 0x0b, lzmaSection(t, section(sectionFVImage, nested), uiSection("Compressed"))))
+
+	volumes, err := ParseImage(fv)
+	assert.NoError(t, err)
+	assert.Len(t, volumes, 2)
+
+	top := volumes[0]
+	assert.Len(t, top.Files, 1)
+	assert.Equal(t, testLZMA, top.Files[0].GUID)
+	assert.Equal(t, "Compressed", top.Files[0].Name)
+	assert.False(t, top.Files[0].Compressed)
+
+	inner := volumes[1]
+	assert.Equal(t, testLZMA, inner.Parent)
+	assert.Len(t, inner.Files, 1)
+	assert.Equal(t, testPEIM, inner.Files[0].GUID)
+	assert.Equal(t, "FakePei", inner.Files[0].Name)
+
+	// truncated streams are reported as compressed
+	trunc := lzmaSection(t, section(sectionFVImage, nested))
+	fv = volume(testFVName, ffsFile(testLZMA, 0x0b, section(sectionGUIDDefined, trunc[4:len(trunc)/2])))
+	volumes, err = ParseImage(fv)
+	assert.NoError(t, err)
+	assert.Len(t, volumes, 1)
+	assert.True(t, volumes[0].Files[0].Compressed)
+}
+
+func TestParseImageCorrupt(t *testing.T) {
+	fv := volume(testFVName, ffsFile(testDriver, 0x07))
+	fv[50]++
+	_, err := ParseImage(fv)
+	assert.ErrorIs(t, err, ErrNoVolumes)
+
+	_, err = ParseImage([]byte("_FVH"))
+	assert.ErrorIs(t, err, ErrNoVolumes)
+}
+
+func TestReportFirmwareVolumes(t *testing.T) {
+	var fw api.UEFIFirmware
+	assert.Error(t, ReportFirmwareVolumes(&fw, &api.HashBlob{Error: api.NoPermission}))
+	assert.Equal(t, api.NoPermission, fw.Error)
+
+	fw = api.UEFIFirmware{}
+	assert.NoError(t, ReportFirmwareVolumes(&fw, &api.HashBlob{Data: volume(testFVName)}))
+	assert.Len(t, fw.Volumes, 1)
+	assert.Empty(t, fw.Volumes[0].Files)
+}
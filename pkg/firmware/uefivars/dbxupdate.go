@@ -0,0 +1,75 @@
+package uefivars
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+// places distributions install the UEFI forum's dbx updates to
+var dbxUpdateGlobs = []string{
+	"/usr/share/dbxtool/DBXUpdate-*.bin",
+	"/usr/share/secureboot/updates/dbx/*.bin",
+}
+
+// dbxUpdateArch is the architecture suffix used in the names of dbx update files
+func dbxUpdateArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "386":
+		return "ia32"
+	case "arm64":
+		return "aa64"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// matchesArch returns false for dbx updates built for other architectures
+func matchesArch(name string) bool {
+	for _, arch := range []string{"x64", "ia32", "aa64", "arm"} {
+		if strings.Contains(name, arch) {
+			return arch == dbxUpdateArch()
+		}
+	}
+	return true
+}
+
+// FindDbxUpdate returns the newest dbx update for this architecture installed by the distribution or an empty
+// string. The files are named after their release date.
+func FindDbxUpdate(root common.Root) string {
+	var candidates []string
+	for _, glob := range dbxUpdateGlobs {
+		matches, _ := filepath.Glob(root.Path(glob))
+		for _, m := range matches {
+			if matchesArch(strings.ToLower(filepath.Base(m))) {
+				candidates = append(candidates, m)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Slice(candidates, func(i, j int) bool { return filepath.Base(candidates[i]) < filepath.Base(candidates[j]) })
+	return candidates[len(candidates)-1]
+}
+
+// ReadDbxUpdate parses the revocations in a signed dbx update
+func ReadDbxUpdate(path string) ([]api.EFISignature, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := ParseAuthenticatedVariable(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sigs, nil
+}
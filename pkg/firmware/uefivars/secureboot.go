@@ -0,0 +1,110 @@
+package uefivars
+
+import (
+	"errors"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
+)
+
+const (
+	GlobalVariable        = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+	ImageSecurityDatabase = "d719b2cb-3d3a-4596-a3bc-dad00e67656f"
+	ShimLock              = "605dab50-e046-4300-abb6-3dd810dd8b23"
+
+	// ERROR_ENVVAR_NOT_FOUND, returned by GetFirmwareEnvironmentVariableEx
+	errEnvVarNotFound = syscall.Errno(203)
+)
+
+// newer kernels expose shim's runtime copies here if they are too large for a variable
+var mokVariables = "/sys/firmware/efi/mok-variables"
+
+// SecureBoot is the Secure Boot configuration as seen by the OS. Databases that don't exist are nil.
+type SecureBoot struct {
+	api.UEFI
+	MokList   *[]api.EFISignature `json:"mok_list,omitempty"`   // shim's additional db, Linux only
+	MokListX  *[]api.EFISignature `json:"mok_list_x,omitempty"` // shim's additional dbx, Linux only
+	SbatLevel string              `json:"sbat_level,omitempty"` // CSV with the minimum generation of each component, Linux only
+	Errors    map[string]string   `json:"errors,omitempty"`     // variable name -> read or parse error
+
+	root common.Root
+}
+
+// ReadSecureBoot reads the Secure Boot state and parses the signature databases
+func ReadSecureBoot(root common.Root) (*SecureBoot, error) {
+	log.Trace().Msg("ReadSecureBoot()")
+
+	if !hasUEFIVariables(root) {
+		return nil, errors.New("UEFI variables not accessible")
+	}
+
+	sb := SecureBoot{Errors: map[string]string{}, root: root}
+	sb.SecureBoot = sb.flag("SecureBoot")
+	switch {
+	case sb.flag("AuditMode"):
+		sb.Mode = api.ModeAudit
+	case sb.flag("SetupMode"):
+		sb.Mode = api.ModeSetup
+	case sb.flag("DeployedMode"):
+		sb.Mode = api.ModeDeployed
+	default:
+		sb.Mode = api.ModeUser
+	}
+
+	sb.PlatformKeys = sb.signatures("PK", GlobalVariable)
+	sb.ExchangeKeys = sb.signatures("KEK", GlobalVariable)
+	sb.PermittedKeys = sb.signatures("db", ImageSecurityDatabase)
+	sb.ForbiddenKeys = sb.signatures("dbx", ImageSecurityDatabase)
+
+	if runtime.GOOS == "linux" {
+		sb.MokList = sb.signatures("MokListRT", ShimLock)
+		sb.MokListX = sb.signatures("MokListXRT", ShimLock)
+		if buf, err := sb.read("SbatLevelRT", ShimLock); err == nil {
+			sb.SbatLevel = strings.TrimRight(string(buf), "\x00")
+		}
+	}
+
+	return &sb, nil
+}
+
+func (sb *SecureBoot) read(name, guid string) ([]byte, error) {
+	buf, err := readUEFIVariable(name, guid, sb.root)
+	if notFound(err) && guid == ShimLock {
+		buf, err = os.ReadFile(path.Join(sb.root.Path(mokVariables), name))
+	}
+	if err != nil && !notFound(err) {
+		sb.Errors[name] = err.Error()
+		log.Debug().Err(err).Msgf("uefivars.ReadSecureBoot(): %s", name)
+	}
+	return buf, err
+}
+
+func (sb *SecureBoot) flag(name string) bool {
+	buf, err := sb.read(name, GlobalVariable)
+	return err == nil && len(buf) == 1 && buf[0] == 1
+}
+
+func (sb *SecureBoot) signatures(name, guid string) *[]api.EFISignature {
+	buf, err := sb.read(name, guid)
+	if err != nil {
+		return nil
+	}
+	sigs, err := ParseSignatureLists(buf)
+	if err != nil {
+		sb.Errors[name] = err.Error()
+		log.Debug().Err(err).Msgf("uefivars.ReadSecureBoot(): %s", name)
+		return nil
+	}
+	return &sigs
+}
+
+func notFound(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, errEnvVarNotFound)
+}
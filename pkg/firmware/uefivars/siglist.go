@@ -0,0 +1,127 @@
+package uefivars
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+const (
+	signatureListHeaderSize = 28
+	signatureOwnerSize      = 16
+	efiTimeSize             = 16
+)
+
+var (
+	certSHA256 = uuid.MustParse("c1c41626-504c-4092-aca9-41f936934328")
+	certX509   = uuid.MustParse("a5c059a1-94e4-4aa7-87b5-ab155c2bf072")
+)
+
+// efiGUID decodes a mixed endian EFI_GUID
+func efiGUID(b []byte) uuid.UUID {
+	var id uuid.UUID
+	copy(id[:], b[:16])
+	id[0], id[1], id[2], id[3] = id[3], id[2], id[1], id[0]
+	id[4], id[5] = id[5], id[4]
+	id[6], id[7] = id[7], id[6]
+	return id
+}
+
+// ParseSignatureLists decodes the EFI_SIGNATURE_LISTs of a Secure Boot database like db or dbx. Certificates are
+// identified by the SHA-256 of their DER encoding, all other entries by their contents.
+func ParseSignatureLists(buf []byte) ([]api.EFISignature, error) {
+	sigs := []api.EFISignature{}
+	for len(buf) > 0 {
+		if len(buf) < signatureListHeaderSize {
+			return nil, errors.New("truncated signature list header")
+		}
+		typ := efiGUID(buf)
+		listSize := int(binary.LittleEndian.Uint32(buf[16:]))
+		hdrSize := int(binary.LittleEndian.Uint32(buf[20:]))
+		sigSize := int(binary.LittleEndian.Uint32(buf[24:]))
+		if listSize > len(buf) || sigSize <= signatureOwnerSize || signatureListHeaderSize+hdrSize > listSize {
+			return nil, errors.New("invalid signature list")
+		}
+
+		entries := buf[signatureListHeaderSize+hdrSize : listSize]
+		if len(entries)%sigSize != 0 {
+			return nil, errors.New("signature list size is not a multiple of the signature size")
+		}
+		for ; len(entries) > 0; entries = entries[sigSize:] {
+			sig, err := parseSignature(typ, entries[signatureOwnerSize:sigSize])
+			if err != nil {
+				return nil, err
+			}
+			sigs = append(sigs, sig)
+		}
+		buf = buf[listSize:]
+	}
+	return sigs, nil
+}
+
+func parseSignature(typ uuid.UUID, data []byte) (api.EFISignature, error) {
+	if typ != certX509 {
+		if typ == certSHA256 && len(data) != sha256.Size {
+			return api.EFISignature{}, fmt.Errorf("invalid SHA-256 signature of %d bytes", len(data))
+		}
+		return api.EFISignature{Type: api.EFIFingerprint, Fingerprint: hex.EncodeToString(data)}, nil
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return api.EFISignature{}, err
+	}
+	return CertificateSignature(cert), nil
+}
+
+// CertificateSignature describes a certificate the same way as certificates in the signature databases
+func CertificateSignature(cert *x509.Certificate) api.EFISignature {
+	sum := sha256.Sum256(cert.Raw)
+	subject, issuer, algo := cert.Subject.String(), cert.Issuer.String(), cert.SignatureAlgorithm.String()
+	notBefore, notAfter := cert.NotBefore, cert.NotAfter
+	return api.EFISignature{
+		Type:        api.EFICertificate,
+		Subject:     &subject,
+		Issuer:      &issuer,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		NotBefore:   &notBefore,
+		NotAfter:    &notAfter,
+		Algorithm:   &algo,
+	}
+}
+
+// ParseAuthenticatedVariable decodes a signed variable update like the DBXUpdate.bin files published by the UEFI
+// forum. The signature itself is not verified.
+func ParseAuthenticatedVariable(buf []byte) ([]api.EFISignature, error) {
+	// EFI_VARIABLE_AUTHENTICATION_2: EFI_TIME followed by a WIN_CERTIFICATE_UEFI_GUID
+	if len(buf) < efiTimeSize+4 {
+		return nil, errors.New("truncated authentication header")
+	}
+	certLen := int(binary.LittleEndian.Uint32(buf[efiTimeSize:]))
+	if certLen < 8 || efiTimeSize+certLen > len(buf) {
+		return nil, errors.New("invalid authentication header")
+	}
+	return ParseSignatureLists(buf[efiTimeSize+certLen:])
+}
+
+// MissingSignatures returns the entries of update that are not in db
+func MissingSignatures(db, update []api.EFISignature) []api.EFISignature {
+	have := make(map[string]bool, len(db))
+	for _, sig := range db {
+		have[sig.Fingerprint] = true
+	}
+	missing := []api.EFISignature{}
+	for _, sig := range update {
+		if !have[sig.Fingerprint] {
+			missing = append(missing, sig)
+		}
+	}
+	return missing
+}
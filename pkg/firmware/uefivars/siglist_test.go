@@ -0,0 +1,125 @@
+package uefivars
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+var testOwner = uuid.MustParse("77fa9abd-0359-4d32-bd60-28f4e78f784b")
+
+func efiGUIDBytes(id uuid.UUID) []byte {
+	b := append([]byte{}, id[:]...)
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+	return b
+}
+
+func signatureList(typ uuid.UUID, entries ...[]byte) []byte {
+	sigSize := signatureOwnerSize + len(entries[0])
+	var buf bytes.Buffer
+	buf.Write(efiGUIDBytes(typ))
+	binary.Write(&buf, binary.LittleEndian, uint32(signatureListHeaderSize+len(entries)*sigSize))
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	binary.Write(&buf, binary.LittleEndian, uint32(sigSize))
+	for _, e := range entries {
+		buf.Write(efiGUIDBytes(testOwner))
+		buf.Write(e)
+	}
+	return buf.Bytes()
+}
+
+func testCertificate(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Platform Key"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return der
+}
+
+func TestParseSignatureLists(t *testing.T) {
+	der := testCertificate(t)
+	hash1, hash2 := sha256.Sum256([]byte("revoked 1")), sha256.Sum256([]byte("revoked 2"))
+	buf := append(signatureList(certX509, der), signatureList(certSHA256, hash1[:], hash2[:])...)
+
+	sigs, err := ParseSignatureLists(buf)
+	assert.NoError(t, err)
+	assert.Len(t, sigs, 3)
+
+	certHash := sha256.Sum256(der)
+	assert.Equal(t, api.EFICertificate, sigs[0].Type)
+	assert.Equal(t, hex.EncodeToString(certHash[:]), sigs[0].Fingerprint)
+	assert.Equal(t, "CN=Test Platform Key", *sigs[0].Subject)
+	assert.Equal(t, "CN=Test Platform Key", *sigs[0].Issuer)
+	assert.Equal(t, 2030, sigs[0].NotAfter.Year())
+	assert.Equal(t, "ECDSA-SHA256", *sigs[0].Algorithm)
+
+	assert.Equal(t, api.EFISignature{Type: api.EFIFingerprint, Fingerprint: hex.EncodeToString(hash1[:])}, sigs[1])
+	assert.Equal(t, hex.EncodeToString(hash2[:]), sigs[2].Fingerprint)
+
+	// empty db
+	sigs, err = ParseSignatureLists(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, sigs)
+}
+
+func TestParseSignatureListsInvalid(t *testing.T) {
+	hash := sha256.Sum256([]byte("revoked"))
+	list := signatureList(certSHA256, hash[:])
+
+	_, err := ParseSignatureLists(list[:len(list)-1])
+	assert.Error(t, err)
+	_, err = ParseSignatureLists(list[:10])
+	assert.Error(t, err)
+	_, err = ParseSignatureLists(signatureList(certSHA256, hash[:16]))
+	assert.Error(t, err)
+	_, err = ParseSignatureLists(signatureList(certX509, []byte("not a certificate")))
+	assert.Error(t, err)
+}
+
+func TestParseAuthenticatedVariable(t *testing.T) {
+	hash1, hash2 := sha256.Sum256([]byte("revoked 1")), sha256.Sum256([]byte("revoked 2"))
+	list := signatureList(certSHA256, hash1[:], hash2[:])
+
+	// EFI_TIME, WIN_CERTIFICATE_UEFI_GUID with a dummy PKCS#7 blob
+	update := make([]byte, efiTimeSize)
+	update = binary.LittleEndian.AppendUint32(update, 24+5)
+	update = append(update, make([]byte, 20)...)
+	update = append(update, []byte("pkcs7")...)
+	update = append(update, list...)
+
+	sigs, err := ParseAuthenticatedVariable(update)
+	assert.NoError(t, err)
+	assert.Len(t, sigs, 2)
+
+	dbx, err := ParseSignatureLists(signatureList(certSHA256, hash1[:]))
+	assert.NoError(t, err)
+	missing := MissingSignatures(dbx, sigs)
+	assert.Len(t, missing, 1)
+	assert.Equal(t, hex.EncodeToString(hash2[:]), missing[0].Fingerprint)
+	assert.Empty(t, MissingSignatures(sigs, dbx))
+
+	_, err = ParseAuthenticatedVariable(update[:efiTimeSize+10])
+	assert.Error(t, err)
+}
@@ -6,14 +6,14 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func hasUEFIVariables() bool {
+func hasUEFIVariables(root common.Root) bool {
 	// We (wrongly) assume that every UEFI system has console output.
-	_, err := readUEFIVariable("ConOut", "8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	_, err := readUEFIVariable("ConOut", "8be4df61-93ca-11d2-aa0d-00e098032b8c", root)
 	return err == nil
 }
 
-func reportUEFIVariable(variable *api.UEFIVariable) error {
-	val, err := readUEFIVariable(variable.Name, variable.Vendor)
+func reportUEFIVariable(variable *api.UEFIVariable, root common.Root) error {
+	val, err := readUEFIVariable(variable.Name, variable.Vendor, root)
 	if err != nil {
 		variable.Error = common.ServeApiError(common.MapFSErrors(err))
 		log.Debug().Err(err).Msg("uefivars.ReportUEFIVariable()")
@@ -25,10 +25,10 @@ func reportUEFIVariable(variable *api.UEFIVariable) error {
 	return nil
 }
 
-func ReportUEFIVariables(variables []api.UEFIVariable) (err error) {
+func ReportUEFIVariables(variables []api.UEFIVariable, root common.Root) (err error) {
 	log.Trace().Msg("ReportUEFIVariables()")
 
-	if !hasUEFIVariables() {
+	if !hasUEFIVariables(root) {
 		log.Warn().Msg("UEFI variables not accessible")
 		for i := range variables {
 			v := &variables[i]
@@ -40,7 +40,7 @@ func ReportUEFIVariables(variables []api.UEFIVariable) (err error) {
 	allFailed := true
 	for i := range variables {
 		v := &variables[i]
-		err = reportUEFIVariable(v)
+		err = reportUEFIVariable(v, root)
 		allFailed = allFailed && err != nil
 	}
 	if allFailed && len(variables) > 0 {
@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
 var efivars = "/sys/firmware/efi/efivars"
 
-func readUEFIVariable(name, guid string) ([]byte, error) {
-
-	buf, err := os.ReadFile(path.Join(efivars, fmt.Sprintf("%s-%s", name, guid)))
+func readUEFIVariable(name, guid string, root common.Root) ([]byte, error) {
+	buf, err := os.ReadFile(path.Join(root.Path(efivars), fmt.Sprintf("%s-%s", name, guid)))
 	if err != nil {
 		return nil, err
 	}
@@ -5,8 +5,10 @@ package uefivars
 import (
 	"errors"
 	"runtime"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
-func readUEFIVariable(name, guid string) ([]byte, error) {
+func readUEFIVariable(name, guid string, root common.Root) ([]byte, error) {
 	return nil, errors.New("uefivars.ReadUEFIVariable not implemented on " + runtime.GOOS)
 }
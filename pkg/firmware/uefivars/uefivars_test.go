@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -107,7 +108,7 @@ func TestReadVar(t *testing.T) {
 
 	// test
 	uefiVars := []api.UEFIVariable{uefiVar}
-	err := ReportUEFIVariables(uefiVars)
+	err := ReportUEFIVariables(uefiVars, "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -131,7 +132,7 @@ func TestReadNonExistent(t *testing.T) {
 		Name:   "FooVar",
 	}
 	uefiVars := []api.UEFIVariable{uefiVar1, uefiVar2}
-	err := ReportUEFIVariables(uefiVars)
+	err := ReportUEFIVariables(uefiVars, "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -145,7 +146,7 @@ func TestReadNonExistent(t *testing.T) {
 
 func TestHasVar(t *testing.T) {
 	_ = setupUefiVariables(t)
-	if !hasUEFIVariables() {
+	if !hasUEFIVariables("") {
 		t.Error("expected true")
 	}
 }
@@ -158,7 +159,7 @@ func TestHasNoVar(t *testing.T) {
 
 	// test
 	uefiVars := []api.UEFIVariable{uefiVar}
-	err := ReportUEFIVariables(uefiVars)
+	err := ReportUEFIVariables(uefiVars, "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -166,3 +167,46 @@ func TestHasNoVar(t *testing.T) {
 		t.Errorf("expected not-impl got '%s'", uefiVars[0].Error)
 	}
 }
+
+func TestReadSecureBoot(t *testing.T) {
+	sc := setupUefiVariables(t)
+	mokVariables = path.Join(sc.Tempdir, "mok-variables")
+	defer func() { mokVariables = "/sys/firmware/efi/mok-variables" }()
+
+	write := func(name, guid string, value []byte) {
+		err := os.WriteFile(path.Join(sc.Tempdir, fmt.Sprintf("%s-%s", name, guid)), append([]byte{7, 0, 0, 0}, value...), 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := bytes.Repeat([]byte{0xaa}, 32)
+	write("SecureBoot", GlobalVariable, []byte{1})
+	write("SetupMode", GlobalVariable, []byte{0})
+	write("db", ImageSecurityDatabase, nil)
+	write("dbx", ImageSecurityDatabase, signatureList(certSHA256, hash))
+	write("MokListXRT", ShimLock, []byte("garbage"))
+	write("SbatLevelRT", ShimLock, []byte("sbat,1,2022111500\nshim,2\ngrub,3\n"))
+
+	sb, err := ReadSecureBoot("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sb.SecureBoot || sb.Mode != api.ModeUser {
+		t.Errorf("wrong state: %v %s", sb.SecureBoot, sb.Mode)
+	}
+	if sb.PlatformKeys != nil || sb.PermittedKeys == nil || len(*sb.PermittedKeys) != 0 {
+		t.Error("expected no PK and an empty db")
+	}
+	if sb.ForbiddenKeys == nil || len(*sb.ForbiddenKeys) != 1 || (*sb.ForbiddenKeys)[0].Fingerprint != strings.Repeat("aa", 32) {
+		t.Errorf("wrong dbx: %v", sb.ForbiddenKeys)
+	}
+	if sb.MokListX != nil || sb.Errors["MokListXRT"] == "" {
+		t.Error("expected MokListXRT parse error")
+	}
+	if sb.SbatLevel != "sbat,1,2022111500\nshim,2\ngrub,3\n" {
+		t.Errorf("wrong SbatLevel: %q", sb.SbatLevel)
+	}
+	if len(sb.Errors) != 1 {
+		t.Errorf("unexpected errors: %v", sb.Errors)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"syscall"
 	"unsafe"
+
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 )
 
 const (
@@ -21,7 +23,7 @@ var (
 	procGetFirmwareEnvironmentVariableExA = libKernel32.NewProc("GetFirmwareEnvironmentVariableExA")
 )
 
-func readUEFIVariable(name, guid string) ([]byte, error) {
+func readUEFIVariable(name, guid string, root common.Root) ([]byte, error) {
 	buf, _, err := readUEFIVariableGUID(name, fmt.Sprintf("{%s}", strings.ToUpper(guid)))
 	return buf, err
 }
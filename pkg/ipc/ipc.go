@@ -91,10 +91,14 @@ type SharedAgentResource struct {
 	serveExclusiveLock sync.Mutex
 	agent              *core.AttestationClient
 	status             AgentServiceStatus
+	tpmPath            string
+	server             *url.URL
 }
 
-func NewSharedAgent(agent *core.AttestationClient) *SharedAgentResource {
-	s := SharedAgentResource{agent: agent}
+// NewSharedAgent wraps agent for exclusive use by the service and its clients
+// tpmPath and server are used for enrollment when the client doesn't specify them, server may be nil
+func NewSharedAgent(agent *core.AttestationClient, tpmPath string, server *url.URL) *SharedAgentResource {
+	s := SharedAgentResource{agent: agent, tpmPath: tpmPath, server: server}
 	s.status.Enrolled = agent.State.IsEnrolled()
 	return &s
 }
@@ -145,7 +149,20 @@ func (a *SharedAgentResource) TryEnroll(ctx context.Context, logger *zerolog.Log
 		}()
 	}
 
-	err = a.agent.Enroll(ctx, arguments.Token, arguments.DummyTPM, arguments.TPMPath)
+	// the server URL is stored in the state and used by all later attestations
+	server := arguments.Server
+	if server == nil {
+		server = a.server
+	}
+	if server != nil {
+		a.agent.OverrideServerUrl(server)
+	}
+
+	tpmPath := arguments.TPMPath
+	if tpmPath == "" {
+		tpmPath = a.tpmPath
+	}
+	err = a.agent.Enroll(ctx, arguments.Token, arguments.DummyTPM, tpmPath)
 	return true, err
 }
 
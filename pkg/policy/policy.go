@@ -0,0 +1,291 @@
+// Package policy implements an operator controlled allow/deny list for the hardware
+// interfaces the server configuration asks the agent to access.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+const DefaultFileName = "policy.json"
+
+var ErrInvalid = errors.New("invalid policy")
+
+// Rule restricts one category of items. If Allow is non-empty only the listed items are accessed,
+// items in Deny are never accessed.
+type Rule struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Policy is the on-disk policy file format. Entries are written as follows:
+//   - msrs, sev: register or command number, f.e. "0x13a" or "314"
+//   - cpuid: leaf or leaf and subleaf, f.e. "0x12" (all subleaves) or "0x12:0x1"
+//   - pci: bus, device and function, f.e. "00:16.0"
+//   - me: ME client GUID or HECI client address, f.e. "0x7"
+//   - uefi: efivarfs style name, f.e. "db-d719b2cb-3d3a-4596-a3bc-dad00e67656f", may contain glob patterns
+type Policy struct {
+	MSRs            *Rule `json:"msrs,omitempty"`
+	CPUIDLeafs      *Rule `json:"cpuid,omitempty"`
+	PCIConfigSpaces *Rule `json:"pci,omitempty"`
+	ME              *Rule `json:"me,omitempty"`
+	SEV             *Rule `json:"sev,omitempty"`
+	UEFIVariables   *Rule `json:"uefi,omitempty"`
+}
+
+// Load reads and validates a policy file. A non-existent file yields a nil policy that allows everything.
+func Load(file string) (*Policy, error) {
+	buf, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		log.Trace().Msgf("no policy file at %s", file)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return Parse(buf)
+}
+
+// Parse decodes and validates a policy
+func Parse(buf []byte) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if err := p.normalize(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	return &p, nil
+}
+
+func (p *Policy) normalize() error {
+	rules := []struct {
+		rule *Rule
+		norm func(string) (string, error)
+	}{
+		{p.MSRs, normalizeNumber},
+		{p.CPUIDLeafs, normalizeCPUIDLeaf},
+		{p.PCIConfigSpaces, normalizeBDF},
+		{p.ME, normalizeMEClient},
+		{p.SEV, normalizeNumber},
+		{p.UEFIVariables, normalizeUEFIVariable},
+	}
+
+	for _, r := range rules {
+		if r.rule == nil {
+			continue
+		}
+		for _, list := range [][]string{r.rule.Allow, r.rule.Deny} {
+			for i := range list {
+				str, err := r.norm(list[i])
+				if err != nil {
+					return err
+				}
+				list[i] = str
+			}
+		}
+	}
+
+	return nil
+}
+
+func normalizeNumber(str string) (string, error) {
+	num, err := strconv.ParseUint(strings.TrimSpace(str), 0, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid number '%s'", str)
+	}
+	return formatNumber(uint32(num)), nil
+}
+
+func formatNumber(num uint32) string {
+	return fmt.Sprintf("%#x", num)
+}
+
+func normalizeCPUIDLeaf(str string) (string, error) {
+	leaf, subleaf, ok := strings.Cut(str, ":")
+	leaf, err := normalizeNumber(leaf)
+	if err != nil || !ok {
+		return leaf, err
+	}
+	subleaf, err = normalizeNumber(subleaf)
+	if err != nil {
+		return "", err
+	}
+	return leaf + ":" + subleaf, nil
+}
+
+func normalizeBDF(str string) (string, error) {
+	var bus, dev, fn uint32
+	if _, err := fmt.Sscanf(strings.ToLower(str), "%x:%x.%x", &bus, &dev, &fn); err != nil || bus > 0xff || dev > 0x1f || fn > 7 {
+		return "", fmt.Errorf("invalid PCI address '%s'", str)
+	}
+	return formatBDF(bus, dev, fn), nil
+}
+
+func formatBDF(bus, dev, fn uint32) string {
+	return fmt.Sprintf("%02x:%02x.%x", bus, dev, fn)
+}
+
+func normalizeMEClient(str string) (string, error) {
+	if guid, err := uuid.Parse(str); err == nil {
+		return guid.String(), nil
+	}
+	addr, err := strconv.ParseUint(strings.TrimSpace(str), 0, 8)
+	if err != nil {
+		return "", fmt.Errorf("invalid ME client '%s'", str)
+	}
+	return formatNumber(uint32(addr)), nil
+}
+
+func normalizeUEFIVariable(str string) (string, error) {
+	// the vendor GUID is the last 36 characters of the name
+	if len(str) < 38 || str[len(str)-37] != '-' {
+		return "", fmt.Errorf("invalid UEFI variable '%s'", str)
+	}
+	if _, err := path.Match(str, ""); err != nil {
+		return "", fmt.Errorf("invalid UEFI variable pattern '%s'", str)
+	}
+	return str[:len(str)-36] + strings.ToLower(str[len(str)-36:]), nil
+}
+
+// permits checks all keys against the rule, any key matching a deny entry or none matching
+// a non-empty allow list denies access
+func (r *Rule) permits(match func(entry, key string) bool, keys ...string) bool {
+	if r == nil {
+		return true
+	}
+
+	for _, entry := range r.Deny {
+		for _, key := range keys {
+			if match(entry, key) {
+				return false
+			}
+		}
+	}
+
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, entry := range r.Allow {
+		for _, key := range keys {
+			if match(entry, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchExact(entry, key string) bool {
+	return entry == key
+}
+
+func matchGlob(entry, key string) bool {
+	ok, _ := path.Match(entry, key)
+	return ok
+}
+
+func (p *Policy) AllowMSR(msr uint32) bool {
+	return p == nil || p.MSRs.permits(matchExact, formatNumber(msr))
+}
+
+func (p *Policy) AllowCPUIDLeaf(eax, ecx uint32) bool {
+	leaf := formatNumber(eax)
+	return p == nil || p.CPUIDLeafs.permits(matchExact, leaf, leaf+":"+formatNumber(ecx))
+}
+
+func (p *Policy) AllowPCIConfigSpace(bus, dev, fn uint32) bool {
+	return p == nil || p.PCIConfigSpaces.permits(matchExact, formatBDF(bus, dev, fn))
+}
+
+// AllowMEClient checks the client GUID and its HECI address, the address is a decimal number as sent by the server
+func (p *Policy) AllowMEClient(guid *uuid.UUID, address string) bool {
+	var keys []string
+	if guid != nil {
+		keys = append(keys, guid.String())
+	}
+	if addr, err := strconv.ParseUint(address, 10, 8); err == nil {
+		keys = append(keys, formatNumber(uint32(addr)))
+	}
+	return p == nil || p.ME.permits(matchExact, keys...)
+}
+
+func (p *Policy) AllowSEVCommand(cmd uint32) bool {
+	return p == nil || p.SEV.permits(matchExact, formatNumber(cmd))
+}
+
+func (p *Policy) AllowUEFIVariable(name, vendor string) bool {
+	return p == nil || p.UEFIVariables.permits(matchGlob, name+"-"+strings.ToLower(vendor))
+}
+
+// filter marks the denied items in place and runs report on the permitted ones. The items keep the order the
+// server requested them in.
+func filter[T any](items []T, permitted func(*T) bool, deny func(*T), report func([]T) error) error {
+	var allowed []T
+	var index []int
+	for i := range items {
+		if permitted(&items[i]) {
+			allowed = append(allowed, items[i])
+			index = append(index, i)
+		} else {
+			deny(&items[i])
+		}
+	}
+	if len(allowed) == len(items) {
+		return report(items)
+	}
+	log.Debug().Msgf("policy denied access to %d of %d items", len(items)-len(allowed), len(items))
+
+	err := report(allowed)
+	for j, i := range index {
+		items[i] = allowed[j]
+	}
+	return err
+}
+
+// FilterMSRs marks denied MSRs and reports the permitted ones
+func (p *Policy) FilterMSRs(msrs []api.MSR, report func([]api.MSR) error) error {
+	return filter(msrs, func(v *api.MSR) bool { return p.AllowMSR(v.MSR) }, func(v *api.MSR) { v.Error = api.DeniedByPolicy }, report)
+}
+
+// FilterCPUIDLeafs marks denied CPUID leaves and reports the permitted ones
+func (p *Policy) FilterCPUIDLeafs(leafs []api.CPUIDLeaf, report func([]api.CPUIDLeaf) error) error {
+	return filter(leafs, func(v *api.CPUIDLeaf) bool { return p.AllowCPUIDLeaf(v.LeafEAX, v.LeafECX) }, func(v *api.CPUIDLeaf) { v.Error = api.DeniedByPolicy }, report)
+}
+
+// FilterPCIConfigSpaces marks denied PCI functions and reports the permitted ones
+func (p *Policy) FilterPCIConfigSpaces(spaces []api.PCIConfigSpace, report func([]api.PCIConfigSpace) error) error {
+	return filter(spaces, func(v *api.PCIConfigSpace) bool {
+		return p.AllowPCIConfigSpace(uint32(v.Bus), uint32(v.Device), uint32(v.Function))
+	}, func(v *api.PCIConfigSpace) { v.Error = api.DeniedByPolicy }, report)
+}
+
+// FilterMEClientCommands marks denied ME clients and reports the permitted ones
+func (p *Policy) FilterMEClientCommands(clients []api.MEClientCommands, report func([]api.MEClientCommands) error) error {
+	return filter(clients, func(v *api.MEClientCommands) bool { return p.AllowMEClient(v.GUID, v.Address) }, func(v *api.MEClientCommands) { v.Error = api.DeniedByPolicy }, report)
+}
+
+// FilterSEVCommands marks denied SEV commands and reports the permitted ones
+func (p *Policy) FilterSEVCommands(cmds []api.SEVCommand, report func([]api.SEVCommand) error) error {
+	return filter(cmds, func(v *api.SEVCommand) bool { return p.AllowSEVCommand(v.Command) }, func(v *api.SEVCommand) { v.Error = api.DeniedByPolicy }, report)
+}
+
+// FilterUEFIVariables marks denied UEFI variables and reports the permitted ones
+func (p *Policy) FilterUEFIVariables(vars []api.UEFIVariable, report func([]api.UEFIVariable) error) error {
+	return filter(vars, func(v *api.UEFIVariable) bool { return p.AllowUEFIVariable(v.Name, v.Vendor) }, func(v *api.UEFIVariable) { v.Error = api.DeniedByPolicy }, report)
+}
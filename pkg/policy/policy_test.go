@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/immune-gmbh/agent/v3/pkg/api"
+)
+
+const testPolicy = `{
+	"msrs": {"allow": ["0x13a", "0x3a"], "deny": ["58"]},
+	"cpuid": {"allow": ["0x12", "0x7:0"]},
+	"pci": {"deny": ["00:1F.5"]},
+	"me": {"allow": ["8e6a6715-9abc-4043-88ef-9e39c6f63e0f", "0x7"]},
+	"sev": {"deny": ["0x4"]},
+	"uefi": {"deny": ["db*-d719b2cb-3d3a-4596-a3bc-dad00e67656f"]}
+}`
+
+func TestNilPolicy(t *testing.T) {
+	var p *Policy
+	assert.True(t, p.AllowMSR(0x13a))
+	assert.True(t, p.AllowMEClient(nil, ""))
+
+	msrs := []api.MSR{{MSR: 1}, {MSR: 2}}
+	assert.NoError(t, p.FilterMSRs(msrs, func(allowed []api.MSR) error {
+		assert.Len(t, allowed, 2)
+		return nil
+	}))
+}
+
+func TestLoad(t *testing.T) {
+	p, err := Load(filepath.Join(t.TempDir(), "nonexistent.json"))
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	assert.NoError(t, os.WriteFile(path, []byte(testPolicy), 0600))
+	p, err = Load(path)
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, str := range []string{
+		`{"msr": {}}`,
+		`{"msrs": {"allow": ["foo"]}}`,
+		`{"cpuid": {"allow": ["0x7:bar"]}}`,
+		`{"pci": {"allow": ["00:20.0"]}}`,
+		`{"me": {"allow": ["not-a-guid"]}}`,
+		`{"uefi": {"allow": ["db"]}}`,
+	} {
+		_, err := Parse([]byte(str))
+		assert.True(t, errors.Is(err, ErrInvalid), str)
+	}
+}
+
+func TestRules(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	// deny wins over allow
+	assert.True(t, p.AllowMSR(0x13a))
+	assert.False(t, p.AllowMSR(0x3a))
+	assert.False(t, p.AllowMSR(0x10))
+
+	assert.True(t, p.AllowCPUIDLeaf(0x12, 0))
+	assert.True(t, p.AllowCPUIDLeaf(0x12, 2))
+	assert.True(t, p.AllowCPUIDLeaf(0x7, 0))
+	assert.False(t, p.AllowCPUIDLeaf(0x7, 1))
+
+	assert.True(t, p.AllowPCIConfigSpace(0, 0x16, 0))
+	assert.False(t, p.AllowPCIConfigSpace(0, 0x1f, 5))
+
+	guid := uuid.MustParse("8E6A6715-9ABC-4043-88EF-9E39C6F63E0F")
+	other := uuid.New()
+	assert.True(t, p.AllowMEClient(&guid, ""))
+	assert.False(t, p.AllowMEClient(&other, ""))
+	assert.False(t, p.AllowMEClient(nil, ""))
+	assert.True(t, p.AllowMEClient(nil, "7"))
+	assert.False(t, p.AllowMEClient(nil, "8"))
+
+	assert.True(t, p.AllowSEVCommand(0x2))
+	assert.False(t, p.AllowSEVCommand(0x4))
+
+	assert.True(t, p.AllowUEFIVariable("PK", "8be4df61-93ca-11d2-aa0d-00e098032b8c"))
+	assert.False(t, p.AllowUEFIVariable("dbx", "D719B2CB-3D3A-4596-A3BC-DAD00E67656F"))
+}
+
+func TestFilter(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	assert.NoError(t, err)
+
+	msrs := []api.MSR{{MSR: 0x10}, {MSR: 0x13a}, {MSR: 0x3a}}
+	err = p.FilterMSRs(msrs, func(allowed []api.MSR) error {
+		assert.Equal(t, []api.MSR{{MSR: 0x13a}}, allowed)
+		allowed[0].Values = []uint64{42}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// denied items are marked in the order of the request, reported results end up in the original slice
+	assert.Equal(t, []api.MSR{
+		{MSR: 0x10, Error: api.DeniedByPolicy},
+		{MSR: 0x13a, Values: []uint64{42}},
+		{MSR: 0x3a, Error: api.DeniedByPolicy},
+	}, msrs)
+}
@@ -0,0 +1,97 @@
+// Package settings defines the agent settings shared by the command line tool and the Windows service.
+// The command line tool embeds Settings as its global flags and loads the configuration file as a kong
+// resolver, so keys are the flag names with hyphens replaced by underscores.
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/immune-gmbh/agent/v3/pkg/state"
+)
+
+const DefaultFileName = "agent.conf"
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Settings is the single definition of the agent configuration. Fields without a global flag are
+// excluded from kong; the resolver still applies server and tpm to the enroll flags of the same name.
+// Both only take effect when enrolling, on every platform. The server URL is then kept in the agent
+// state, so changing it requires enrolling again.
+type Settings struct {
+	Server         string   `kong:"-" json:"server,omitempty"`
+	Proxy          string   `name:"proxy" placeholder:"URL" help:"HTTP proxy used to contact the immune SaaS API" json:"proxy,omitempty"`
+	LogLevel       string   `name:"log-level" enum:",trace,debug,info,warn,error" default:"" help:"Log level (trace, debug, info, warn, error)" json:"log_level,omitempty"`
+	LogFormat      string   `name:"log-format" enum:"text,json" default:"text" help:"Log output format (text, json), json implies log" json:"log_format,omitempty"` // LogFormat*
+	TPM            string   `kong:"-" json:"tpm,omitempty"`
+	AttestInterval string   `kong:"-" json:"attest_interval,omitempty"`                                                                                    // windows service only, linux uses a systemd timer
+	Disable        []string `name:"disable" help:"Collectors to skip (${collectors})" json:"disable,omitempty"`                                            // firmware.Collector*
+	Redact         []string `name:"redact" help:"Data to strip from reports (${redaction_rules})" json:"redact,omitempty"`                                 // firmware.Redact*
+	MountESP       bool     `name:"mount-esp" help:"Mount the EFI system partition read-only if it isn't mounted (Linux only)" json:"mount_esp,omitempty"` // linux only
+	Root           string   `name:"root" placeholder:"PATH" type:"existingdir" help:"Directory the collectors treat as the root file system, f.e. the host's mounted into a container, collect treats it as offline unless --live is set (Linux only)" json:"root,omitempty"`
+}
+
+// DefaultPath returns the OS-specific location of the agent configuration file
+func DefaultPath() string {
+	return filepath.Join(state.DefaultConfigDir(), DefaultFileName)
+}
+
+// Load reads the agent configuration file. A non-existent file yields empty settings.
+func Load(file string) (*Settings, error) {
+	var s Settings
+
+	buf, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		log.Trace().Msgf("no agent configuration at %s", file)
+		return &s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	return &s, nil
+}
+
+// ServerURL returns the parsed server URL or nil if unset
+func (s *Settings) ServerURL() (*url.URL, error) {
+	return parseURL(s.Server)
+}
+
+// ProxyURL returns the parsed proxy URL or nil if unset
+func (s *Settings) ProxyURL() (*url.URL, error) {
+	return parseURL(s.Proxy)
+}
+
+// Interval returns the attestation interval or def if unset
+func (s *Settings) Interval(def time.Duration) (time.Duration, error) {
+	if s.AttestInterval == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s.AttestInterval)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("attestation interval %v too short", d)
+	}
+	return d, nil
+}
+
+func parseURL(str string) (*url.URL, error) {
+	if str == "" {
+		return nil, nil
+	}
+	return url.Parse(str)
+}
@@ -0,0 +1,70 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "nonexistent"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Settings{}, s)
+
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	conf := `{"server": "https://example.com/v2", "attest_interval": "30m", "disable": ["flash"], "state_dir": "/tmp"}`
+	assert.NoError(t, os.WriteFile(path, []byte(conf), 0600))
+	s, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"flash"}, s.Disable)
+
+	u, err := s.ServerURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", u.Host)
+
+	u, err = s.ProxyURL()
+	assert.NoError(t, err)
+	assert.Nil(t, u)
+
+	d, err := s.Interval(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, d)
+}
+
+func TestInterval(t *testing.T) {
+	d, err := (&Settings{}).Interval(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+
+	_, err = (&Settings{AttestInterval: "10s"}).Interval(time.Hour)
+	assert.Error(t, err)
+
+	_, err = (&Settings{AttestInterval: "often"}).Interval(time.Hour)
+	assert.Error(t, err)
+}
+
+func TestFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	conf := `{"server": "https://example.com/v2", "proxy": "http://proxy:3128", "log_format": "json", "disable": ["flash"]}`
+	assert.NoError(t, os.WriteFile(path, []byte(conf), 0600))
+
+	var cli struct {
+		Settings `embed:""`
+	}
+	parser, err := kong.New(&cli, kong.Configuration(kong.JSON, path), kong.Vars{"collectors": "", "redaction_rules": ""})
+	assert.NoError(t, err)
+	root := t.TempDir()
+	_, err = parser.Parse([]string{"--redact", "ip", "--root", root})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "http://proxy:3128", cli.Proxy)
+	assert.Equal(t, LogFormatJSON, cli.LogFormat)
+	assert.Equal(t, []string{"flash"}, cli.Disable)
+	assert.Equal(t, []string{"ip"}, cli.Redact)
+	assert.Equal(t, root, cli.Root)
+	assert.Empty(t, cli.Server)
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"os"
@@ -41,11 +42,18 @@ type DeviceKey DeviceKeyV3
 // state versions, as the config structure is from the public API and thus
 // has its own versioning and there should be separate code handling
 // different API versions.
-func (s *State) EnsureFresh(cl *api.Client) (bool, error) {
+// if key is not nil then configs that are not signed by it are rejected.
+func (s *State) EnsureFresh(cl *api.Client, key ed25519.PublicKey) (bool, error) {
 	ctx := context.Background()
 	now := time.Now()
 
-	cfg, err := cl.Configuration(ctx, &s.LastUpdate)
+	// don't let the server tell us to re-use a cached config we can't verify
+	lastUpdate := &s.LastUpdate
+	if key != nil && len(s.ConfigSignature) == 0 {
+		lastUpdate = nil
+	}
+
+	cfg, err := cl.SignedConfiguration(ctx, lastUpdate)
 	if err != nil {
 		// if the server is not reachable we can try to re-use an old config if there was any
 		// the firmware reporting functionality must be able to run with empty
@@ -58,7 +66,15 @@ func (s *State) EnsureFresh(cl *api.Client) (bool, error) {
 
 	// if cfg is nil then there is no new config and we should use a cached version
 	if cfg != nil {
-		s.Config = *cfg
+		if key != nil {
+			if err := api.VerifyConfiguration(key, cfg.Canonical, cfg.Signature); err != nil {
+				return false, err
+			}
+		}
+
+		s.Config = cfg.Configuration
+		s.ConfigCanonical = cfg.Canonical
+		s.ConfigSignature = cfg.Signature
 		update := s.LastUpdate != time.Time{}
 		s.LastUpdate = now
 
@@ -68,6 +84,27 @@ func (s *State) EnsureFresh(cl *api.Client) (bool, error) {
 	return false, nil
 }
 
+// VerifyConfig checks the signature of the stored config and replaces it with the verified canonical version,
+// so the config used afterwards is exactly the one that was signed. It is a no-op if key is nil.
+func (s *State) VerifyConfig(key ed25519.PublicKey) error {
+	if key == nil {
+		return nil
+	}
+
+	if err := api.VerifyConfiguration(key, s.ConfigCanonical, s.ConfigSignature); err != nil {
+		return err
+	}
+
+	var cfg api.Configuration
+	if err := json.Unmarshal(s.ConfigCanonical, &cfg); err != nil {
+		log.Debug().Err(err).Msg("signed config is not valid JSON")
+		return ErrInvalid
+	}
+	s.Config = cfg
+
+	return nil
+}
+
 // LoadState returns a loaded state and a bool if it has been updated or error
 func LoadState(keysPath string) (*State, bool, error) {
 	log.Trace().Msg("load on-disk state")
@@ -9,6 +9,8 @@ import "path/filepath"
 const (
 	// gloablProgramStateDir stores programatically generated state
 	globalProgramStateDir string = "/var/lib"
+	// globalConfigDir stores operator provided configuration
+	globalConfigDir string = "/etc"
 	defaultTPMDevice      string = "/dev/tpm0"
 )
 
@@ -19,3 +21,7 @@ func DefaultTPMDevice() string {
 func DefaultStateDir() string {
 	return filepath.Clean(filepath.Join(globalProgramStateDir, DefaultVendorSubdir))
 }
+
+func DefaultConfigDir() string {
+	return filepath.Clean(filepath.Join(globalConfigDir, DefaultVendorSubdir))
+}
@@ -16,3 +16,9 @@ func DefaultTPMDevice() string {
 func DefaultStateDir() string {
 	return filepath.Clean(filepath.Join(os.Getenv("ProgramData"), DefaultVendorSubdir))
 }
+
+// DefaultConfigDir returns the directory holding operator provided configuration,
+// on windows this is the same as the state dir.
+func DefaultConfigDir() string {
+	return DefaultStateDir()
+}
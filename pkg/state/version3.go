@@ -44,8 +44,10 @@ type StateV3 struct {
 	ServerURL              *url.URL               `json:"serverurl,omitempty"` // v3.4
 
 	// /v2/configuration
-	LastUpdate time.Time         `json:"last_update,string"`
-	Config     api.Configuration `json:"config"`
+	LastUpdate      time.Time         `json:"last_update,string"`
+	Config          api.Configuration `json:"config"`
+	ConfigCanonical api.Buffer        `json:"config_jcs,omitempty"` // v3.5
+	ConfigSignature api.Buffer        `json:"config_sig,omitempty"` // v3.5
 }
 
 func (s *StateV3) IsEnrolled() bool {
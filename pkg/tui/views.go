@@ -181,3 +181,12 @@ func ShowAppraisalLink(link string) {
 		printf("\nSee detailed results here:\n%s\n", LinkStyle(link))
 	}
 }
+
+func ShowBootAppRevocation(path, reason string, revoked bool) {
+	completeLastStep(true)
+	status := "vulnerable"
+	if revoked {
+		status = "revoked"
+	}
+	printf("[%s] Boot application %s %s: %s\n", FailureStyle(Cross), path, status, reason)
+}
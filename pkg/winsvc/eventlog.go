@@ -11,6 +11,7 @@ import (
 type eventLogWriter struct {
 	elog *eventlog.Log
 	cw   *zerolog.ConsoleWriter
+	json bool // pass structured log lines through unformatted
 }
 
 // kudos to this guy for the pretty wild trick:
@@ -25,7 +26,7 @@ func (ew *eventLogWriter) Write(p []byte) (n int, err error) {
 	ew.cw.Out = writerFunc(func(p []byte) (n int, err error) {
 		return len(p), ew.elog.Info(4, string(p))
 	})
-	return ew.cw.Write(p)
+	return ew.write(p)
 }
 
 func (ew *eventLogWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
@@ -68,5 +69,12 @@ func (ew *eventLogWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err
 		})
 	}
 
+	return ew.write(p)
+}
+
+func (ew *eventLogWriter) write(p []byte) (n int, err error) {
+	if ew.json {
+		return ew.cw.Out.Write(p)
+	}
 	return ew.cw.Write(p)
 }
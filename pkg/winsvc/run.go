@@ -4,11 +4,15 @@ package winsvc
 
 import (
 	"context"
+	"fmt"
+	"net/url"
 	"runtime"
 	"time"
 
 	"github.com/immune-gmbh/agent/v3/pkg/core"
+	"github.com/immune-gmbh/agent/v3/pkg/firmware/common"
 	"github.com/immune-gmbh/agent/v3/pkg/ipc"
+	"github.com/immune-gmbh/agent/v3/pkg/settings"
 	"github.com/immune-gmbh/agent/v3/pkg/state"
 	"github.com/immune-gmbh/agent/v3/pkg/util"
 	"github.com/rs/zerolog"
@@ -50,6 +54,7 @@ func (e *Exponential) Increase() time.Duration {
 }
 
 type agentService struct {
+	interval         time.Duration
 	backoff          *Exponential
 	agent            *ipc.SharedAgentResource
 	cancelPipeServer context.CancelFunc
@@ -60,7 +65,7 @@ func (m *agentService) Execute(args []string, r <-chan svc.ChangeRequest, change
 	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
 	changes <- svc.Status{State: svc.StartPending}
 	scheduleInterval := time.Millisecond
-	m.backoff = &Exponential{Min: time.Minute, Max: defaultAttestInterval}
+	m.backoff = &Exponential{Min: time.Minute, Max: m.interval}
 	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
 	log.Info().Msgf("immune Guard agent service %s (%s) started", *m.svcReleaseId, runtime.GOARCH)
 
@@ -97,14 +102,14 @@ loop:
 func (m *agentService) runAttest() time.Duration {
 	status := m.agent.Status()
 	if !status.Enrolled {
-		return defaultAttestInterval
+		return m.interval
 	}
 
 	// if the last operation is recent then reschedule accordingly
 	if status.LastRun != nil && status.LastOperation != "" {
 		d := time.Since(*status.LastRun)
-		if d < defaultAttestInterval {
-			return defaultAttestInterval - d
+		if d < m.interval {
+			return m.interval - d
 		}
 	}
 
@@ -117,7 +122,44 @@ func (m *agentService) runAttest() time.Duration {
 	}
 	m.backoff.Reset()
 
-	return defaultAttestInterval
+	return m.interval
+}
+
+// applySettings configures logging and collection options from the agent configuration file
+func applySettings(agent *core.AttestationClient, ew *eventLogWriter, conf *settings.Settings) (interval time.Duration, server, proxy *url.URL, err error) {
+	if conf.LogLevel != "" {
+		var lvl zerolog.Level
+		if lvl, err = zerolog.ParseLevel(conf.LogLevel); err != nil {
+			return
+		}
+		log.Logger = log.Level(lvl)
+	}
+
+	if interval, err = conf.Interval(defaultAttestInterval); err != nil {
+		return
+	}
+	if server, err = conf.ServerURL(); err != nil {
+		return
+	}
+	if proxy, err = conf.ProxyURL(); err != nil {
+		return
+	}
+
+	switch conf.LogFormat {
+	case "", settings.LogFormatText:
+	case settings.LogFormatJSON:
+		ew.json = true
+	default:
+		err = fmt.Errorf("unknown log format %q", conf.LogFormat)
+		return
+	}
+
+	agent.Options.Disabled = conf.Disable
+	agent.Options.Redact = conf.Redact
+	agent.Options.MountESP = conf.MountESP
+	agent.Options.Root = common.Root(conf.Root)
+	err = agent.Options.Validate()
+	return
 }
 
 func RunService() int {
@@ -150,15 +192,33 @@ func RunService() int {
 		return 1
 	}
 
-	// init agent core
+	// apply local agent configuration
 	agent := core.NewCore()
-	if err := agent.Init(state.DefaultStateDir(), &log.Logger); err != nil {
-		core.LogInitErrors(&log.Logger, err)
+	conf, err := settings.Load(settings.DefaultPath())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load agent configuration")
+		return 1
+	}
+	interval, server, proxy, err := applySettings(agent, ew, conf)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid agent configuration")
 		return 1
 	}
 
+	// init agent core
+	if err := agent.Init(state.DefaultStateDir(), core.DefaultPolicyPath(), &log.Logger); err != nil {
+		core.LogInitErrors(&log.Logger, err)
+		return 1
+	}
+	if proxy != nil {
+		agent.SetProxy(proxy)
+	}
 	// start a shared agent service on a named pipe
-	sharedAgent := ipc.NewSharedAgent(agent)
+	tpmPath := conf.TPM
+	if tpmPath == "" {
+		tpmPath = state.DefaultTPMDevice()
+	}
+	sharedAgent := ipc.NewSharedAgent(agent, tpmPath, server)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	err = ipc.StartNamedPipe(ctx, sew, sharedAgent, agent.ReleaseId)
@@ -168,7 +228,7 @@ func RunService() int {
 	}
 
 	// when all went well proceed to execute as a windows service
-	err = svc.Run(SVC_NAME, &agentService{agent: sharedAgent, cancelPipeServer: cancel, svcReleaseId: agent.ReleaseId})
+	err = svc.Run(SVC_NAME, &agentService{interval: interval, agent: sharedAgent, cancelPipeServer: cancel, svcReleaseId: agent.ReleaseId})
 	if err != nil {
 		log.Error().Msgf("%s service failed: %v", SVC_NAME, err)
 		return 1